@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+)
+
+// validateVariantAliases checks that aliases map standard variant tokens,
+// such as 700 or italic, to the keys a provider uses instead
+func validateVariantAliases(aliases map[string]string) error {
+	for variant, key := range aliases {
+		if !variantToken.MatchString(variant) {
+			return fmt.Errorf("variant_aliases: %q is not a standard variant such as regular, italic, 700 or 700italic", variant)
+		}
+		if key == "" {
+			return fmt.Errorf("variant_aliases: %s has an empty provider key", variant)
+		}
+	}
+	return nil
+}
+
+// aliasVariants renames the provider's variant keys in item to the standard
+// tokens they alias, so the rest of the install, including file names and
+// the stylesheet, only sees standard tokens. item is copied, as it may be
+// shared with the catalog snapshot.
+func aliasVariants(item FontItem, aliases map[string]string) FontItem {
+	if len(aliases) == 0 {
+		return item
+	}
+	files := make(map[string]string, len(item.Files))
+	for key, url := range item.Files {
+		files[key] = url
+	}
+	variants := slices.Clone(item.Variants)
+	for variant, key := range aliases {
+		url, ok := files[key]
+		if !ok {
+			continue
+		}
+		delete(files, key)
+		files[variant] = url
+		if i := slices.Index(variants, key); i >= 0 {
+			variants[i] = variant
+		}
+	}
+	item.Files, item.Variants = files, variants
+	return item
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// axisTag matches variable font axis tags: registered axes are lowercase,
+// custom axes such as GRAD uppercase
+var axisTag = regexp.MustCompile(`^([a-z]{4}|[A-Z]{4})$`)
+
+// axisRange is a requested span of a variable font axis
+type axisRange struct {
+	min, max float64
+}
+
+// parseAxisRange reads an axis value such as 400 or a range such as 100..900
+func parseAxisRange(spec string) (axisRange, error) {
+	lo, hi, isRange := strings.Cut(spec, "..")
+	min, err := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+	if err != nil {
+		return axisRange{}, fmt.Errorf("invalid axis value %q (expected a number or a range such as 100..900)", spec)
+	}
+	max := min
+	if isRange {
+		if max, err = strconv.ParseFloat(strings.TrimSpace(hi), 64); err != nil {
+			return axisRange{}, fmt.Errorf("invalid axis value %q (expected a number or a range such as 100..900)", spec)
+		}
+	}
+	if min > max {
+		return axisRange{}, fmt.Errorf("axis range %q runs backwards", spec)
+	}
+	return axisRange{min, max}, nil
+}
+
+func (r axisRange) String() string {
+	if r.min == r.max {
+		return formatAxisValue(r.min)
+	}
+	return formatAxisValue(r.min) + ".." + formatAxisValue(r.max)
+}
+
+// cssRange renders the range as a CSS descriptor value in unit, e.g. "75% 100%"
+func (r axisRange) cssRange(unit string) string {
+	if r.min == r.max {
+		return formatAxisValue(r.min) + unit
+	}
+	return formatAxisValue(r.min) + unit + " " + formatAxisValue(r.max) + unit
+}
+
+func formatAxisValue(v float64) string {
+	if v == 0 {
+		// no -0
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// validateAxes checks the tags and ranges of a font's axes
+func validateAxes(axes map[string]string) error {
+	for tag, spec := range axes {
+		if !axisTag.MatchString(tag) {
+			return fmt.Errorf("invalid axis tag %q (expected four letters, e.g. wght or GRAD)", tag)
+		}
+		if tag == "ital" {
+			return fmt.Errorf("the ital axis is chosen by listing the italic variant, not in `axes`")
+		}
+		if _, err := parseAxisRange(spec); err != nil {
+			return fmt.Errorf("axis %s: %v", tag, err)
+		}
+	}
+	return nil
+}
+
+// supportedAxes parses the requested axes, dropping those the family does
+// not have and narrowing ranges to what it supports, with a warning for each
+func supportedAxes(item FontItem, axes map[string]string) map[string]axisRange {
+	ranges := map[string]axisRange{}
+	if len(item.Axes) == 0 {
+		printWarning("%s is not a variable font, ignoring its axes", item.Family)
+		return ranges
+	}
+	for tag, spec := range axes {
+		// validated with the config
+		r, _ := parseAxisRange(spec)
+		var axis *Axes
+		for _, a := range item.Axes {
+			if a.Tag == tag {
+				axis = a
+			}
+		}
+		if axis == nil {
+			printWarning("%s has no %s axis, ignoring it", item.Family, tag)
+			continue
+		}
+		supported := axisRange{axis.Start, axis.End}
+		if r.min < axis.Start || r.max > axis.End {
+			narrowed := axisRange{max(r.min, axis.Start), min(r.max, axis.End)}
+			if narrowed.min > narrowed.max {
+				printWarning("%s axis %s only spans %s, ignoring %s", item.Family, tag, supported, r)
+				continue
+			}
+			printWarning("%s axis %s only spans %s, using %s", item.Family, tag, supported, narrowed)
+			r = narrowed
+		}
+		ranges[tag] = r
+	}
+	return ranges
+}
+
+// css2AxesURL builds the CSS API URL for a variable font limited to axes,
+// e.g. family=Roboto+Flex:ital,slnt,wght@0,-10..0,100..900. The API wants
+// lowercase tags first, each group in alphabetical order.
+func css2AxesURL(family string, axes map[string]axisRange, italics []bool) string {
+	names := []string{}
+	for tag := range axes {
+		names = append(names, tag)
+	}
+	hasItalic := false
+	for _, italic := range italics {
+		hasItalic = hasItalic || italic
+	}
+	if hasItalic {
+		names = append(names, "ital")
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if upper := names[i][0] < 'a'; upper != (names[j][0] < 'a') {
+			return !upper
+		}
+		return names[i] < names[j]
+	})
+	u := css2API + "?family=" + strings.ReplaceAll(family, " ", "+")
+	if len(names) == 0 {
+		return u
+	}
+	tuples := []string{}
+	for _, ital := range []string{"0", "1"} {
+		wanted := false
+		for _, italic := range italics {
+			wanted = wanted || italic == (ital == "1")
+		}
+		if !wanted {
+			continue
+		}
+		values := make([]string, len(names))
+		for i, name := range names {
+			if name == "ital" {
+				values[i] = ital
+			} else {
+				values[i] = axes[name].String()
+			}
+		}
+		tuples = append(tuples, strings.Join(values, ","))
+		if !hasItalic {
+			break
+		}
+	}
+	return u + ":" + strings.Join(names, ",") + "@" + strings.Join(tuples, ";")
+}
+
+// axisDescriptors sets the range descriptors of a rule body from the
+// requested axes: wght to font-weight, wdth to font-stretch and slnt to an
+// oblique font-style, which slants the opposite way to the axis
+func axisDescriptors(body string, axes map[string]axisRange, italic bool) string {
+	if r, ok := axes["wght"]; ok {
+		body = setDescriptor(body, "font-weight", r.cssRange(""))
+	}
+	if r, ok := axes["wdth"]; ok {
+		body = setDescriptor(body, "font-stretch", r.cssRange("%"))
+	}
+	if r, ok := axes["slnt"]; ok && !italic {
+		body = setDescriptor(body, "font-style", "oblique "+axisRange{-r.max, -r.min}.cssRange("deg"))
+	}
+	return body
+}
+
+// setDescriptor replaces a descriptor of a rule body, or adds it when missing
+func setDescriptor(body, name, value string) string {
+	re := regexp.MustCompile(`(?m)^(\s*)` + regexp.QuoteMeta(name) + `\s*:[^;]*;`)
+	if loc := re.FindStringSubmatchIndex(body); loc != nil {
+		indent := body[loc[2]:loc[3]]
+		return body[:loc[0]] + indent + name + ": " + value + ";" + body[loc[1]:]
+	}
+	return strings.TrimRight(body, " \t\n") + "\n  " + name + ": " + value + ";\n"
+}
+
+// installAxes installs a variable font limited to the entry's axis ranges.
+// The Developer API only serves full-range files, so the files come from
+// the CSS API, which serves one per subset.
+func (in *installer) installAxes(entry FontEntry, item FontItem, prev, locked *LockedFont) entryResult {
+	axes := supportedAxes(item, entry.Axes)
+	variants := entry.Variants
+	if len(variants) == 0 {
+		variants = []string{"regular"}
+	}
+	italics := make([]bool, len(variants))
+	for i, variant := range variants {
+		italics[i] = strings.HasSuffix(variant, "italic")
+	}
+	css, err := fetchCSS(css2AxesURL(item.Family, axes, italics))
+	if err != nil {
+		printError("failed to fetch the variable font of %s: %v", entry.Family, err)
+		exit(1)
+	}
+	faces := parseFontFaces(string(css))
+	if len(faces) == 0 {
+		familyWarning(entry.name(), "no @font-face rules found for %s", entry.Family)
+		return entryResult{}
+	}
+	return in.localizeFaces(entry, entry.Family, faces, prev, locked, func(face cssFontFace) string {
+		return axisDescriptors(face.Body, axes, face.Style == "italic")
+	})
+}
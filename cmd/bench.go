@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var BenchVariant string
+var BenchRuns int
+var BenchWorkers []int
+
+// benchFamily is the font bench downloads by default, a typical text face
+const benchFamily = "Roboto"
+
+var benchCmd = &cobra.Command{
+	Use:   "bench [font]",
+	Short: "Measure download latency and throughput from the provider",
+	Long: `Downloads one font file from the provider several times and reports the
+time to the first byte, the time and throughput of each download, and how
+throughput scales with the number of concurrent downloads. A throughput
+that grows with the workers points at the provider's per-connection speed,
+one that doesn't at your link; pick --parallel-files for install from the
+table. Files are written to a temp directory, which is removed.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		family := benchFamily
+		if len(args) == 1 {
+			family = args[0]
+		}
+		if BenchRuns < 1 {
+			printError("--runs must be at least 1")
+			exit(1)
+		}
+		for _, n := range BenchWorkers {
+			if n < 1 {
+				printError("--workers must be at least 1, got %d", n)
+				exit(1)
+			}
+		}
+		item, err := googleResolver{}.Resolve(family)
+		if errors.Is(err, errFontNotFound) {
+			printError("no font found for %s", family)
+			exit(1)
+		}
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		url, ok := item.Files[BenchVariant]
+		if !ok {
+			printError("%s has no %s variant (available: %s)", item.Family, BenchVariant, strings.Join(item.Variants, ", "))
+			exit(1)
+		}
+		tmp, err := os.MkdirTemp("", "hermes-bench-")
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		defer os.RemoveAll(tmp)
+
+		fmt.Printf("Downloading %s (%s) from %s\n\n", item.Family, BenchVariant, url)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUN\tFIRST BYTE\tTIME\tSIZE\tTHROUGHPUT")
+		for i := 1; i <= BenchRuns; i++ {
+			r, err := benchDownload(url, tmp)
+			if err != nil {
+				w.Flush()
+				printError("download failed: %v", err)
+				exit(1)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i, formatBenchDuration(r.firstByte), formatBenchDuration(r.total), formatBytes(r.bytes), formatThroughput(r.bytes, r.total))
+		}
+		w.Flush()
+		if len(BenchWorkers) == 0 {
+			return
+		}
+
+		// each level downloads the same number of files, so their times compare
+		downloads := BenchRuns * slices.Max(BenchWorkers)
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "WORKERS\tDOWNLOADS\tTIME\tTHROUGHPUT\tSPEEDUP")
+		var first float64
+		best, bestWorkers := 0.0, 0
+		for _, n := range BenchWorkers {
+			bytes, elapsed, err := benchConcurrent(url, tmp, n, downloads)
+			if err != nil {
+				w.Flush()
+				printError("download failed with %d workers: %v", n, err)
+				exit(1)
+			}
+			rate := float64(bytes) / elapsed.Seconds()
+			if first == 0 {
+				first = rate
+			}
+			// more workers are only worth it for a tenth more throughput
+			if rate > best*1.1 {
+				bestWorkers = n
+			}
+			best = max(best, rate)
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%.1fx\n", n, downloads, formatBenchDuration(elapsed), formatThroughput(bytes, elapsed), rate/first)
+		}
+		w.Flush()
+		fmt.Println()
+		if best >= first*1.5 {
+			fmt.Printf("Throughput grows with concurrent downloads, so each connection is limited by the provider; try --parallel-files %d\n", bestWorkers)
+		} else {
+			fmt.Println("Throughput barely grows with concurrent downloads, so your link is likely the bottleneck")
+		}
+	},
+}
+
+// benchResult is the timing of one download
+type benchResult struct {
+	firstByte, total time.Duration
+	bytes            int64
+}
+
+// benchDownload downloads url into a temp file in dir, timing the
+// response headers and the whole download
+func benchDownload(url, dir string) (benchResult, error) {
+	var r benchResult
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	out, err := os.CreateTemp(dir, "font-")
+	if err != nil {
+		return r, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	start := time.Now()
+	resp, err := downloadGet(req)
+	if err != nil {
+		return r, redactError(err, req.URL)
+	}
+	defer resp.Body.Close()
+	r.firstByte = time.Since(start)
+	if resp.StatusCode != 200 {
+		return r, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	if r.bytes, err = io.Copy(out, resp.Body); err != nil {
+		return r, err
+	}
+	r.total = time.Since(start)
+	return r, nil
+}
+
+// benchConcurrent downloads url count times with workers at once, and
+// returns the bytes downloaded and the time it took
+func benchConcurrent(url, dir string, workers, count int) (int64, time.Duration, error) {
+	jobs := make(chan struct{}, count)
+	for i := 0; i < count; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	var mu sync.Mutex
+	var total int64
+	var errs []error
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				r, err := benchDownload(url, dir)
+				mu.Lock()
+				total += r.bytes
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return total, time.Since(start), errors.Join(errs...)
+}
+
+// formatBenchDuration rounds d for the bench tables
+func formatBenchDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(100 * time.Microsecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+// formatThroughput is the rate of n bytes in d, e.g. 1.2 MB/s
+func formatThroughput(n int64, d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return formatBytes(int64(float64(n)/d.Seconds())) + "/s"
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().StringVar(&BenchVariant, "variant", "regular", "Variant to download, e.g. 700 or italic")
+	benchCmd.Flags().IntVar(&BenchRuns, "runs", 3, "Number of sequential downloads; each concurrency level downloads this many files per worker of the largest level")
+	benchCmd.Flags().IntSliceVar(&BenchWorkers, "workers", []int{1, 2, 4, 8}, "Numbers of concurrent downloads to measure; empty to skip")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var CacheDir string
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache directory",
+	Long: `Manage the directory hermes keeps local state in, such as the catalog
+snapshot. It defaults to the user cache directory and can be moved with --cache-dir.`,
+}
+
+var cachePathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the cache directory",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(cacheDir())
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete everything in the cache directory",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := cacheDir()
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			printSuccess("Cleared", "%s (already empty)", dir)
+			return
+		}
+		if err != nil {
+			printError("could not read cache directory: %v", err)
+			exit(1)
+		}
+		for _, e := range entries {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				printError("could not clear cache: %v", err)
+				exit(1)
+			}
+		}
+		printSuccess("Cleared", "%d entries from %s", len(entries), dir)
+	},
+}
+
+// cacheDir is where all local state lives: --cache-dir, or hermes in the
+// user cache directory
+func cacheDir() string {
+	if CacheDir != "" {
+		return CacheDir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "hermes")
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cachePathCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+
+	rootCmd.PersistentFlags().StringVar(&CacheDir, "cache-dir", "", "Directory for local state such as the catalog snapshot (default the user cache directory)")
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var Offline bool
+
+// CatalogSnapshot is a local copy of the provider's font catalog. With
+// --offline, families are resolved from it instead of the API.
+type CatalogSnapshot struct {
+	Provider  string     `json:"provider"`
+	FetchedAt time.Time  `json:"fetched_at"`
+	Items     []FontItem `json:"items"`
+}
+
+// snapshot is loaded once per run by loadCatalogSnapshot
+var snapshot *CatalogSnapshot
+var snapshotMu sync.Mutex
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Manage the local snapshot of the Google Fonts catalog",
+	Long: `Manage the local snapshot of the Google Fonts catalog.
+Commands run with --offline resolve font families from this snapshot.`,
+}
+
+var catalogFetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Download the full font catalog for offline use",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fontResponse := queryWebfonts("&sort=trending&capability=WOFF2&capability=VF", "could not fetch the font catalog")
+		snap := &CatalogSnapshot{
+			Provider:  "google",
+			FetchedAt: time.Now().UTC(),
+			Items:     fontResponse.Items,
+		}
+		path := catalogPath()
+		if err := writeCatalogSnapshot(path, snap); err != nil {
+			printError("failed to write catalog snapshot: %v", err)
+			exit(1)
+		}
+		printSuccess("Saved", "%d families to %s", len(snap.Items), path)
+	},
+}
+
+func catalogPath() string {
+	return filepath.Join(cacheDir(), "catalog.json")
+}
+
+func writeCatalogSnapshot(path string, snap *CatalogSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadCatalogSnapshot exits when no snapshot has been fetched yet
+func loadCatalogSnapshot() *CatalogSnapshot {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	if snapshot != nil {
+		return snapshot
+	}
+	path := catalogPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		printError("no catalog snapshot found at %s. Run: hermes catalog fetch", path)
+		exit(1)
+	}
+	if err != nil {
+		printError("could not read catalog snapshot: %v", err)
+		exit(1)
+	}
+	snapshot = &CatalogSnapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		printError("could not parse catalog snapshot %s: %v", path, err)
+		exit(1)
+	}
+	return snapshot
+}
+
+// snapshotFontMetadata resolves a parsed family name from the catalog snapshot
+func snapshotFontMetadata(fontFamily, capabilities string) (fontResponse Font) {
+	fontResponse, err := snapshotFont(fontFamily, capabilities)
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	return fontResponse
+}
+
+// snapshotFont is snapshotFontMetadata returning errFontNotFound instead of exiting
+func snapshotFont(fontFamily, capabilities string) (fontResponse Font, err error) {
+	snap := loadCatalogSnapshot()
+	// the snapshot holds the variable font files only
+	if !strings.Contains(capabilities, "capability=VF") {
+		printWarning("static font files are not available offline for %s", fontFamily)
+		return fontResponse, nil
+	}
+	for _, item := range snap.Items {
+		if strings.EqualFold(strings.ReplaceAll(item.Family, " ", "+"), fontFamily) {
+			fontResponse.Items = append(fontResponse.Items, item)
+			return fontResponse, nil
+		}
+	}
+	return fontResponse, fmt.Errorf("%w in catalog snapshot (fetched %s): %s", errFontNotFound, snap.FetchedAt.Format(time.RFC3339), fontFamily)
+}
+
+func init() {
+	rootCmd.AddCommand(catalogCmd)
+	catalogCmd.AddCommand(catalogFetchCmd)
+
+	rootCmd.PersistentFlags().BoolVar(&Offline, "offline", false, "Resolve font families from the catalog snapshot instead of the API")
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var CheckParallel int
+var CheckJSON bool
+
+var checkCmd = &cobra.Command{
+	Use:   "check [config]",
+	Short: "Check that every font in the config exists, without downloading",
+	Long: `Resolves every font of the config against the provider and reports the
+families, variants, subsets and axes it doesn't have, without downloading
+font files or writing anything. css_url stylesheets are fetched and must
+hold @font-face rules. Lookups run concurrently, so check is quick enough
+for a pre-commit hook; it exits with status 1 when anything is unresolved.
+--json prints the problems as JSON for scripts.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if CheckJSON {
+			startJSON()
+			// a run ended by an error still prints a result
+			exitHook = func() {
+				printResult("check", &checkResult{Problems: []string{}, Errors: errorMessages()})
+			}
+		}
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if CheckParallel < 1 {
+			printError("--parallel must be at least 1")
+			exit(1)
+		}
+		if cfg.Fonts, err = mergeDuplicateFonts(cfg.Fonts, "merge"); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		cfg.Fonts = applyOnlyVariants(cfg.Fonts, cfg.OnlyVariants)
+		problems := checkFonts(cfg, googleResolver{}, CheckParallel)
+		if CheckJSON {
+			exitHook = nil
+			printResult("check", &checkResult{OK: len(problems) == 0, Fonts: len(cfg.Fonts), Problems: problems})
+			if len(problems) > 0 {
+				exit(1)
+			}
+			return
+		}
+		for _, p := range problems {
+			fmt.Printf("%s %s\n", colorize(os.Stdout, colorRed, "[x]"), p)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("\n%d problem(s) found\n", len(problems))
+			exit(1)
+		}
+		fmt.Printf("All %d fonts resolved\n", len(cfg.Fonts))
+	},
+}
+
+// checkFonts looks up up to parallel entries at once and returns their
+// problems in the config's order
+func checkFonts(cfg *FontsYAML, resolver Resolver, parallel int) []string {
+	results := make([][]string, len(cfg.Fonts))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, entry := range cfg.Fonts {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, entry FontEntry) {
+			defer wg.Done()
+			results[i] = checkFont(cfg, resolver, entry)
+			<-slots
+		}(i, entry)
+	}
+	wg.Wait()
+	problems := []string{}
+	for _, r := range results {
+		problems = append(problems, r...)
+	}
+	return problems
+}
+
+// checkFont reports what of entry the provider doesn't have. Errors other
+// than a missing family, such as an unreachable API, end the run.
+func checkFont(cfg *FontsYAML, resolver Resolver, entry FontEntry) []string {
+	if entry.CSSURL != "" {
+		css, err := fetchCSS(entry.CSSURL)
+		if err != nil {
+			return []string{fmt.Sprintf("css_url %s: %v", entry.CSSURL, err)}
+		}
+		if len(parseFontFaces(string(css))) == 0 {
+			return []string{fmt.Sprintf("css_url %s has no @font-face rules", entry.CSSURL)}
+		}
+		return nil
+	}
+	resolved, err := resolver.Resolve(entry.Family)
+	if errors.Is(err, errFontNotFound) {
+		return []string{fmt.Sprintf("no font found for %s", entry.Family)}
+	}
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	item := aliasVariants(*resolved, cfg.VariantAliases)
+	problems := []string{}
+	for _, variant := range entry.Variants {
+		if _, ok := item.Files[variant]; ok {
+			continue
+		}
+		if _, ok := nearestVariant(variant, item.Files); ok && entry.NearestWeight {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s has no %s variant (available: %s)", entry.Family, variant, strings.Join(item.Variants, ", ")))
+	}
+	for _, subset := range entry.Subsets {
+		if subset != "all" && !slices.Contains(item.Subsets, subset) {
+			problems = append(problems, fmt.Sprintf("%s has no %s subset (available: %s)", entry.Family, subset, strings.Join(item.Subsets, ", ")))
+		}
+	}
+	if len(entry.Axes) > 0 && len(item.Axes) == 0 {
+		problems = append(problems, fmt.Sprintf("%s is not a variable font, so it has no axes", entry.Family))
+	} else if len(entry.Axes) > 0 {
+		tags := make([]string, 0, len(entry.Axes))
+		for tag := range entry.Axes {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			if !slices.ContainsFunc(item.Axes, func(a *Axes) bool { return a.Tag == tag }) {
+				problems = append(problems, fmt.Sprintf("%s has no %s axis", entry.Family, tag))
+			}
+		}
+	}
+	return problems
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	checkCmd.Flags().IntVar(&CheckParallel, "parallel", 8, "Number of fonts looked up at once")
+	checkCmd.Flags().BoolVar(&CheckJSON, "json", false, "Print the result as JSON, described by hermes schema --result check")
+}
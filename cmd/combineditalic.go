@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+)
+
+// uprightVariant is the upright counterpart of an italic variant, e.g.
+// regular for italic and 700 for 700italic
+func uprightVariant(variant string) (string, bool) {
+	switch {
+	case variant == "italic":
+		return "regular", true
+	case strings.HasSuffix(variant, "italic"):
+		return strings.TrimSuffix(variant, "italic"), true
+	}
+	return "", false
+}
+
+// combinedItalics pairs the italic variants of a combined_italic entry with
+// the upright variant whose variable file also holds them, and returns the
+// oblique range its slnt axis spans, e.g. "0deg 10deg". An italic the
+// provider serves as a file of its own keeps its rule, as does every
+// variant of a family without a slnt axis.
+func combinedItalics(entry FontEntry, item FontItem) (map[string]string, string) {
+	if !entry.CombinedItalic {
+		return nil, ""
+	}
+	var slnt *Axes
+	for _, a := range item.Axes {
+		if a.Tag == "slnt" {
+			slnt = a
+		}
+	}
+	if slnt == nil {
+		familyWarning(entry.name(), "%s has no slnt axis, keeping separate upright and italic rules", entry.Family)
+		return nil, ""
+	}
+	pairs := map[string]string{}
+	for _, variant := range entry.Variants {
+		upright, ok := uprightVariant(variant)
+		if !ok || !slices.Contains(entry.Variants, upright) {
+			continue
+		}
+		if url, ok := item.Files[variant]; ok && url != item.Files[upright] {
+			familyWarning(entry.name(), "%s serves %s as a file of its own, keeping its rule", entry.Family, variant)
+			continue
+		}
+		pairs[variant] = upright
+	}
+	// the axis slants the opposite way to the oblique angle
+	return pairs, axisRange{-slnt.End, -slnt.Start}.cssRange("deg")
+}
+
+// combinesItalic reports whether combined_italic pairs variant with its
+// upright or italic counterpart among the entry's variants
+func (e FontEntry) combinesItalic(variant string) bool {
+	if !e.CombinedItalic {
+		return false
+	}
+	if upright, ok := uprightVariant(variant); ok {
+		return slices.Contains(e.Variants, upright)
+	}
+	italic := "italic"
+	if variant != "regular" {
+		italic = variant + "italic"
+	}
+	return slices.Contains(e.Variants, italic)
+}
+
+// combinedItalic reports whether v is an italic variant drawn by the rule
+// of its upright variant's variable file
+func combinedItalic(variant string, v *LockedVariant) bool {
+	_, italic := uprightVariant(variant)
+	return italic && v.Slant != ""
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// familyCacheMaxAge is how old the family name cache may get before
+// completions note that it is stale
+const familyCacheMaxAge = 30 * 24 * time.Hour
+
+// FamilyCache holds the catalog's family names for shell completion, so
+// completing a family name needs no network request
+type FamilyCache struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Families  []string  `json:"families"`
+}
+
+var completionRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Cache the catalog's family names for shell completion",
+	Long: `Fetches the font catalog and caches its family names, which the shell
+completion of commands such as get and list reads instead of calling the API
+on every Tab. Rerun it now and then to pick up new families.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fontResponse := queryWebfonts("&sort=popularity", "could not fetch the font catalog")
+		cache := FamilyCache{FetchedAt: time.Now().UTC(), Families: make([]string, len(fontResponse.Items))}
+		for i, item := range fontResponse.Items {
+			cache.Families[i] = item.Family
+		}
+		path := familyCachePath()
+		if err := writeFamilyCache(path, cache); err != nil {
+			printError("failed to write the family name cache: %v", err)
+			exit(1)
+		}
+		printSuccess("Saved", "%d family names to %s", len(cache.Families), path)
+	},
+}
+
+func familyCachePath() string {
+	return filepath.Join(cacheDir(), "families.json")
+}
+
+func writeFamilyCache(path string, cache FamilyCache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// completeFamily completes a family name argument from the family name
+// cache, in popularity order. A missing or stale cache is noted as active
+// help rather than fetched, so Tab never waits on the network.
+func completeFamily(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	data, err := os.ReadFile(familyCachePath())
+	if err != nil {
+		return cobra.AppendActiveHelp(nil, "No family names cached yet, run: hermes completion refresh"), cobra.ShellCompDirectiveNoFileComp
+	}
+	var cache FamilyCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return cobra.AppendActiveHelp(nil, fmt.Sprintf("The family name cache is unreadable (%v), run: hermes completion refresh", err)), cobra.ShellCompDirectiveNoFileComp
+	}
+	comps := []string{}
+	prefix := strings.ToLower(toComplete)
+	for _, family := range cache.Families {
+		if strings.HasPrefix(strings.ToLower(family), prefix) {
+			comps = append(comps, family)
+		}
+	}
+	if age := time.Since(cache.FetchedAt); age > familyCacheMaxAge {
+		comps = cobra.AppendActiveHelp(comps, fmt.Sprintf("Family names were cached %d days ago, run hermes completion refresh to update them", int(age.Hours()/24)))
+	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	// Execute would add the default completion command later; adding it
+	// now lets refresh sit beside the shell script subcommands
+	rootCmd.InitDefaultCompletionCmd()
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == "completion" {
+			c.AddCommand(completionRefreshCmd)
+		}
+	}
+
+	getCmd.ValidArgsFunction = completeFamily
+	listCmd.ValidArgsFunction = completeFamily
+}
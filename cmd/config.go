@@ -0,0 +1,753 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// flag variables
+var ConfigFlag string
+
+// cssLayerName matches a cascade layer name, dotted for sublayers
+var cssLayerName = regexp.MustCompile(`^-?[_a-zA-Z][_a-zA-Z0-9-]*(\.-?[_a-zA-Z][_a-zA-Z0-9-]*)*$`)
+
+// defaultConfigFile is used when no config path is given
+const defaultConfigFile = "fonts.yaml"
+
+// configFile picks the config path from --config, then the positional
+// argument, then HERMES_CONFIG, then fonts.yaml
+func configFile(args []string) string {
+	switch {
+	case ConfigFlag != "":
+		return ConfigFlag
+	case len(args) > 0:
+		return args[0]
+	case viper.GetString("HERMES_CONFIG") != "":
+		return viper.GetString("HERMES_CONFIG")
+	}
+	return defaultConfigFile
+}
+
+// FontsYAML represents the schema of fonts.yaml
+// Example:
+// fonts:
+//   - family: "Roboto"
+//     variants: ["regular", "700italic"]
+//
+// dir: "./webfonts"
+// stylesheet: "./fonts.css"
+//
+// Relative paths are resolved against the directory containing the config file.
+type FontsYAML struct {
+	// Version is the schema version the config is written against. Configs
+	// without one predate versioning; hermes migrate fills it in
+	Version int         `yaml:"version,omitempty"`
+	Fonts   []FontEntry `yaml:"fonts"`
+	Dir     string      `yaml:"dir"`
+	// Stylesheet is the CSS file to write, or - to write the CSS to stdout
+	// with all other output on stderr
+	Stylesheet string `yaml:"stylesheet"`
+	// Precompress writes a compressed sidecar next to each font file, "gzip"
+	// for Roboto_regular.woff2.gz or "brotli" for Roboto_regular.woff2.br.
+	// woff2 is already brotli-compressed internally, so the savings are small.
+	Precompress string `yaml:"precompress,omitempty"`
+	// BaseURL is prepended to file names in the stylesheet's src urls,
+	// for when the stylesheet is served from a different path than dir
+	BaseURL string `yaml:"base_url,omitempty"`
+	// CacheBust appends a hash of each file's contents to its src url, e.g.
+	// Roboto_regular.woff2?v=ab12cd34, so browsers refetch changed files
+	CacheBust bool `yaml:"cache_bust,omitempty"`
+	// Clean removes files in dir that are no longer referenced by the config.
+	// It defaults to true; set it to false when dir holds other assets, or
+	// list the files to keep with gitignore patterns in dir/.hermesignore
+	Clean *bool `yaml:"clean,omitempty"`
+	// Gitignore writes a .gitignore in dir listing the generated files
+	Gitignore bool `yaml:"gitignore,omitempty"`
+	// SplitByFormat puts each file in a subdirectory of dir named by its
+	// format, e.g. woff2/Roboto_regular.woff2 and woff/Roboto_regular.woff
+	SplitByFormat bool `yaml:"split_by_format,omitempty"`
+	// LocalPostscript reads the full and PostScript names from the name
+	// table of each downloaded file and lists them as local() srcs ahead of
+	// the file, so browsers use a copy of the font installed on the system
+	LocalPostscript bool `yaml:"local_postscript,omitempty"`
+	// StrictDir refuses to install into a dir holding anything but the
+	// files hermes writes there, guarding against dir pointing at a source
+	// tree or a shared asset directory by mistake. The files .hermesignore
+	// matches are allowed, as it declares them part of dir
+	StrictDir bool `yaml:"strict_dir,omitempty"`
+	// Targets are extra builds of the fonts, each with its own dir and
+	// stylesheet or with the files inlined, see InstallTarget
+	Targets []InstallTarget `yaml:"targets,omitempty"`
+	// Environments override paths for the environment named by HERMES_ENV
+	Environments map[string]EnvironmentOverride `yaml:"environments,omitempty"`
+	// KeepOriginalName names each file after the last path segment of its
+	// url, which for Google Fonts is a content hash, instead of using Naming
+	KeepOriginalName bool `yaml:"keep_original_name,omitempty"`
+	// Layout is flat, the default, for files directly in dir, or hashed for
+	// content-addressed paths like ab/cd/abcd1234ef567890.woff2 that can be
+	// served with long cache lifetimes
+	Layout string `yaml:"layout,omitempty"`
+	// Naming is a text/template for font file names over .Family, .Variant,
+	// .Weight, .Style, .Subset, the subset of a subsets file, and .Ext, e.g.
+	// "{{kebab .Family}}-{{.Weight}}.{{.Ext}}"
+	Naming string `yaml:"naming,omitempty"`
+
+	// Mirrors are base urls tried in order when downloading a font file
+	// from the provider fails
+	Mirrors []string `yaml:"mirrors,omitempty"`
+	// URLSigning signs the download urls of mirrors behind a signed-url
+	// CDN, see URLSigningOptions
+	URLSigning *URLSigningOptions `yaml:"url_signing,omitempty"`
+	// ContentTypes are the Content-Types accepted for downloaded font files,
+	// by default the font/ type of each format, their older application/
+	// types and application/octet-stream. Others are warned about, or rejected with --strict
+	ContentTypes []string `yaml:"content_types,omitempty"`
+	// Licenses downloads each family's license text into dir/licenses
+	Licenses bool `yaml:"licenses,omitempty"`
+	// CSSLayer wraps every rule of the stylesheet in a cascade layer of
+	// this name, e.g. "fonts" for @layer fonts { ... }
+	CSSLayer string `yaml:"css_layer,omitempty"`
+	// Header replaces the banner comment at the top of the stylesheet. It is
+	// a text/template over .Version and .Time, the time of the install
+	Header string `yaml:"header,omitempty"`
+	// NoHeader leaves the banner out, as --no-header does
+	NoHeader bool `yaml:"no_header,omitempty"`
+	// Charset declares the stylesheet's encoding in an @charset rule on its
+	// first line, for toolchains that need one: true for UTF-8, or the
+	// name of another encoding
+	Charset string `yaml:"charset,omitempty"`
+	// Formats customizes the format() and tech() src descriptors per file
+	// extension, see FormatOptions
+	Formats map[string]FormatOptions `yaml:"formats,omitempty"`
+	// FormatOrder orders the src list of a rule with several files by
+	// extension, e.g. ["ttf", "woff2"] for a legacy audience. It defaults to
+	// woff2, woff, ttf, otf; unlisted formats go last.
+	FormatOrder []string `yaml:"format_order,omitempty"`
+	// ConvertWOFF writes a woff converted from each installed woff2 file
+	// and lists it after the woff2 in the rule's src, for browsers that
+	// support woff but not woff2. css_url and axes rules keep their src.
+	ConvertWOFF bool `yaml:"convert_woff,omitempty"`
+	// TSOutput is an optional path for a generated TypeScript module
+	// exporting the installed family names and weights
+	TSOutput string `yaml:"ts_output,omitempty"`
+	// Fontsource is an optional directory receiving a copy of the fonts
+	// laid out as Fontsource npm packages, see writeFontsource
+	Fontsource string `yaml:"fontsource,omitempty"`
+	// Manifest is an optional path for a JSON manifest of the installed
+	// files with their source urls and checksums
+	Manifest string `yaml:"manifest,omitempty"`
+	// ManifestFormat sets the manifest's layout and fields, see ManifestFormat
+	ManifestFormat *ManifestFormat `yaml:"manifest_format,omitempty"`
+	// History is the file update, and install with --record-history,
+	// append their font changes to, fonts.history.md for fonts.yaml by
+	// default
+	History string `yaml:"history,omitempty"`
+	// SRI computes a SHA-384 integrity value of each font file, added to
+	// the preload <link> of fonts that aren't self-hosted and to the
+	// manifest, so browsers can verify files served from another origin.
+	// It doesn't apply to @font-face srcs
+	SRI bool `yaml:"sri,omitempty"`
+	// MetricPrecision is the number of decimals the metric overrides of
+	// metrics and --compute-metrics are rounded to, 2 by default
+	MetricPrecision *int `yaml:"metric_precision,omitempty"`
+	// ResourceHints adds preconnect and dns-prefetch <link> tags for the
+	// origins of the fonts that aren't self-hosted, in a comment at the top
+	// of the stylesheet and as the manifest's preconnect list
+	ResourceHints bool `yaml:"resource_hints,omitempty"`
+	// GoEmbed optionally generates a Go file embedding the font files and
+	// stylesheet, see GoEmbedOptions
+	GoEmbed *GoEmbedOptions `yaml:"go_embed,omitempty"`
+	// ServerConfig optionally generates a web server snippet with the MIME
+	// types and cache headers of the font files, see ServerConfigOptions
+	ServerConfig *ServerConfigOptions `yaml:"server_config,omitempty"`
+	// Menu configures where hermes menu writes each family's menu font,
+	// see MenuOptions
+	Menu *MenuOptions `yaml:"menu,omitempty"`
+	// FamilyPrefix and FamilySuffix are added, set off by a space, to the
+	// font-family of every rule and CSS variable, e.g. "ACME" for
+	// 'ACME Roboto', keeping self-hosted fonts apart from installed ones.
+	// Fonts are still resolved and files named by their upstream family.
+	FamilyPrefix string `yaml:"family_prefix,omitempty"`
+	FamilySuffix string `yaml:"family_suffix,omitempty"`
+	// VariantAliases maps standard variant tokens to the keys a provider
+	// uses for them in its files, e.g. {"700": "bold", "italic": "regular-italic"}
+	VariantAliases map[string]string `yaml:"variant_aliases,omitempty"`
+	// OnlyVariants limits every font to these variants, e.g. ["regular", "700"]
+	OnlyVariants []string `yaml:"only_variants,omitempty"`
+	// CriticalStylesheet receives the rules of the variants marked critical,
+	// to inline in the page while the stylesheet loads deferred. The
+	// css_variables rule goes here too when set
+	CriticalStylesheet string `yaml:"critical_stylesheet,omitempty"`
+	// OnEmpty is what install does when no @font-face rules were generated
+	// for a config that lists fonts, as when the provider is down: keep,
+	// the default, leaves the stylesheet, font files and lock file as they
+	// are, write empties the stylesheet, and error fails the run
+	OnEmpty string `yaml:"on_empty,omitempty"`
+	// StylesheetRegion writes the rules between /* hermes:start */ and
+	// /* hermes:end */ comments in the stylesheets, replacing only that
+	// region and keeping the hand-written CSS around it. The comments are
+	// appended when a stylesheet has none.
+	StylesheetRegion bool `yaml:"stylesheet_region,omitempty"`
+	// CSSVariables adds a :root rule to the stylesheet declaring a custom
+	// property per family, e.g. --font-open-sans: 'Open Sans', sans-serif;
+	CSSVariables bool `yaml:"css_variables,omitempty"`
+	// Presets are named font entry fields that entries inherit with
+	// extends, see applyPresets
+	Presets map[string]FontEntry `yaml:"presets,omitempty"`
+
+	// naming is the parsed Naming template
+	naming *template.Template
+}
+
+// fontContentTypes are the Content-Types expected for font downloads
+func (cfg *FontsYAML) fontContentTypes() []string {
+	if len(cfg.ContentTypes) > 0 {
+		return cfg.ContentTypes
+	}
+	return defaultFontContentTypes
+}
+
+// clean reports whether unreferenced files in dir should be removed
+func (cfg *FontsYAML) clean() bool {
+	return !NoClean && (cfg.Clean == nil || *cfg.Clean)
+}
+
+// EnvironmentOverride holds the fields an environment may override.
+// Empty fields keep the base config's value.
+// Example:
+//
+//	environments:
+//	  prod:
+//	    dir: "./dist/fonts"
+//	    base_url: "/static/fonts"
+type EnvironmentOverride struct {
+	Dir        string `yaml:"dir,omitempty"`
+	Stylesheet string `yaml:"stylesheet,omitempty"`
+	BaseURL    string `yaml:"base_url,omitempty"`
+}
+
+type FontEntry struct {
+	Family   string   `yaml:"family,omitempty"`
+	Variants []string `yaml:"variants,omitempty"`
+	// Text subsets the font files to just the glyphs needed for this text
+	Text string `yaml:"text,omitempty"`
+	// VariableFallback adds the static files of a variable font as a
+	// fallback src for browsers without variable font support
+	VariableFallback bool `yaml:"variable_fallback,omitempty"`
+	// VariableSupportsGuard emits the static files as plain @font-face rules
+	// and wraps the variable font's rule in @supports (font-variation-settings: normal)
+	VariableSupportsGuard bool `yaml:"variable_supports_guard,omitempty"`
+	// NearestWeight substitutes the closest available weight's file when a
+	// requested numeric variant doesn't exist
+	NearestWeight bool `yaml:"nearest_weight,omitempty"`
+	// GoogleURL declares the family and variants with a Google Fonts embed
+	// url, e.g. "https://fonts.googleapis.com/css2?family=Roboto:ital,wght@0,400;1,700"
+	GoogleURL string `yaml:"google_url,omitempty"`
+	// Subsets installs each variant as one file per subset, with its own
+	// unicode-range, e.g. ["latin", "latin-ext"], or ["all"] for every subset
+	Subsets []string `yaml:"subsets,omitempty"`
+	// SkipSubsets leaves out these subsets of subsets or css_url, e.g.
+	// ["cyrillic", "greek"] with subsets: ["all"]
+	SkipSubsets []string `yaml:"skip_subsets,omitempty"`
+	// GroupSubsets orders the per-subset rules by subset rather than by
+	// variant, under one comment per subset
+	GroupSubsets bool `yaml:"group_subsets,omitempty"`
+	// Stretch sets the font-stretch of variants, keyed by variant or "all",
+	// e.g. {all: condensed} or {all: "75% 125%"} for a wdth axis range
+	Stretch map[string]string `yaml:"stretch,omitempty"`
+	// Weight sets the font-weight of variants whose token doesn't map to
+	// the weight wanted, keyed by variant, e.g. {book: 450}. File names
+	// still derive from the token
+	Weight map[string]string `yaml:"weight,omitempty"`
+	// Axes installs the variable font limited to these axis ranges, e.g.
+	// {wght: "100..900", slnt: "-10..0"}, with matching font-weight,
+	// font-stretch and oblique font-style ranges. Variants may only be
+	// regular and italic, and default to regular
+	Axes map[string]string `yaml:"axes,omitempty"`
+	// CombinedItalic draws each italic variant with its upright variant's
+	// variable file, when the family has a slnt axis and no separate italic
+	// file, as one rule with an oblique font-style range, e.g.
+	// oblique 0deg 10deg. The italic's file isn't downloaded twice.
+	CombinedItalic bool `yaml:"combined_italic,omitempty"`
+	// NamedInstances adds a rule for each named instance the variable
+	// font's fvar table defines, e.g. Condensed Bold, as the family
+	// "Roboto Flex Condensed Bold" pinned to the instance's coordinates
+	NamedInstances bool `yaml:"named_instances,omitempty"`
+	// CSSURL localizes a ready-made Google Fonts css2 stylesheet instead of
+	// resolving family and variants, e.g. "https://fonts.googleapis.com/css2?family=Inter:wght@400;700"
+	CSSURL string `yaml:"css_url,omitempty"`
+	// OnlyVariants replaces the top-level only_variants for this font;
+	// an empty list opts the font out of the filter
+	OnlyVariants *[]string `yaml:"only_variants,omitempty"`
+	// Order floats the font's rules towards the top of the stylesheet, lowest
+	// first. Fonts without an order follow in alphabetical order.
+	Order *int `yaml:"order,omitempty"`
+	// SelfHost set to false points the stylesheet at the provider's font
+	// urls instead of downloading the files, e.g. to move a site to
+	// self-hosting one font at a time. It defaults to true
+	SelfHost *bool `yaml:"self_host,omitempty"`
+	// Remote sets the preload attributes of a font that isn't self-hosted,
+	// see RemoteOptions
+	Remote *RemoteOptions `yaml:"remote,omitempty"`
+	// FallbackStack follows the family in its css_variables property, e.g.
+	// "Georgia, serif". It defaults to the generic family of its category
+	FallbackStack string `yaml:"fallback_stack,omitempty"`
+	// Fallback adds a rule for a metric-matched local font to show while
+	// the font loads, see FallbackFont
+	Fallback *FallbackFont `yaml:"fallback,omitempty"`
+	// Metrics sets the size-adjust and metric overrides of the font's own
+	// rules, see FontMetrics
+	Metrics *FontMetrics `yaml:"metrics,omitempty"`
+	// FeatureSettings sets the font-feature-settings descriptor of the
+	// font's rules by OpenType feature tag, e.g. {liga: 0, smcp: 1}
+	FeatureSettings map[string]int `yaml:"font_feature_settings,omitempty"`
+	// FeatureValues adds an @font-feature-values rule naming the font's
+	// alternates by block, e.g. {styleset: {alt-g: 1}, swash: {fancy: 2}},
+	// for font-variant-alternates: styleset(alt-g)
+	FeatureValues map[string]map[string]string `yaml:"font_feature_values,omitempty"`
+	// Critical lists the variants whose rules go to critical_stylesheet,
+	// or [all] for every variant
+	Critical []string `yaml:"critical,omitempty"`
+	// Timeout overrides --read-timeout for the font's downloads, e.g. 2m
+	// for a large variable font on a slow mirror
+	Timeout *time.Duration `yaml:"timeout,omitempty"`
+	// Retries overrides --retries for the font's downloads
+	Retries *int `yaml:"retries,omitempty"`
+	// Tags limit the entry to installs whose --tags include one of them,
+	// e.g. [web, email]. Untagged entries are always installed
+	Tags []string `yaml:"tags,omitempty"`
+	// Extends names a preset in `presets` whose fields the entry inherits
+	Extends string `yaml:"extends,omitempty"`
+
+	// only is the variant filter in effect, used to pick from the available
+	// variants when the entry lists none
+	only []string
+}
+
+// writeResolvedConfig writes cfg as YAML, showing the config an install
+// uses once presets, defaults, environments and flags are applied
+func writeResolvedConfig(w io.Writer, cfg *FontsYAML) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// loadFontsYAML reads the config, applies the HERMES_ENV environment's
+// overrides, expands environment variables in paths and resolves relative
+// paths against the config file's directory, unless --relative-to-cwd is set
+func loadFontsYAML(path string) (*FontsYAML, error) {
+	cfg, err := readFontsYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Fonts, err = expandGoogleURLs(cfg.Fonts); err != nil {
+		return nil, err
+	}
+	if err := applyEnvironment(cfg, viper.GetString("HERMES_ENV")); err != nil {
+		return nil, err
+	}
+	if cfg.naming, err = parseNaming(cfg.Naming); err != nil {
+		return nil, err
+	}
+	cfg.Dir = os.ExpandEnv(cfg.Dir)
+	cfg.Stylesheet = os.ExpandEnv(cfg.Stylesheet)
+	cfg.CriticalStylesheet = os.ExpandEnv(cfg.CriticalStylesheet)
+	cfg.TSOutput = os.ExpandEnv(cfg.TSOutput)
+	cfg.Fontsource = os.ExpandEnv(cfg.Fontsource)
+	cfg.Manifest = os.ExpandEnv(cfg.Manifest)
+	cfg.History = os.ExpandEnv(cfg.History)
+	if cfg.GoEmbed != nil {
+		cfg.GoEmbed.Path = os.ExpandEnv(cfg.GoEmbed.Path)
+	}
+	if cfg.ServerConfig != nil {
+		cfg.ServerConfig.Path = os.ExpandEnv(cfg.ServerConfig.Path)
+	}
+	if cfg.Menu != nil {
+		cfg.Menu.Dir = os.ExpandEnv(cfg.Menu.Dir)
+		cfg.Menu.Stylesheet = os.ExpandEnv(cfg.Menu.Stylesheet)
+	}
+	for i := range cfg.Targets {
+		cfg.Targets[i].Dir = os.ExpandEnv(cfg.Targets[i].Dir)
+		cfg.Targets[i].Stylesheet = os.ExpandEnv(cfg.Targets[i].Stylesheet)
+	}
+	if !RelativeToCWD {
+		base := filepath.Dir(path)
+		cfg.Dir = resolvePath(base, cfg.Dir)
+		if cfg.Stylesheet != stdoutStylesheet {
+			cfg.Stylesheet = resolvePath(base, cfg.Stylesheet)
+		}
+		cfg.CriticalStylesheet = resolvePath(base, cfg.CriticalStylesheet)
+		cfg.TSOutput = resolvePath(base, cfg.TSOutput)
+		cfg.Fontsource = resolvePath(base, cfg.Fontsource)
+		cfg.Manifest = resolvePath(base, cfg.Manifest)
+		cfg.History = resolvePath(base, cfg.History)
+		if cfg.GoEmbed != nil {
+			cfg.GoEmbed.Path = resolvePath(base, cfg.GoEmbed.Path)
+		}
+		if cfg.ServerConfig != nil {
+			cfg.ServerConfig.Path = resolvePath(base, cfg.ServerConfig.Path)
+		}
+		if cfg.Menu != nil {
+			cfg.Menu.Dir = resolvePath(base, cfg.Menu.Dir)
+			cfg.Menu.Stylesheet = resolvePath(base, cfg.Menu.Stylesheet)
+		}
+		for i := range cfg.Targets {
+			cfg.Targets[i].Dir = resolvePath(base, cfg.Targets[i].Dir)
+			cfg.Targets[i].Stylesheet = resolvePath(base, cfg.Targets[i].Stylesheet)
+		}
+	}
+	return cfg, nil
+}
+
+// applyEnvironment merges the named environment's overrides over cfg
+func applyEnvironment(cfg *FontsYAML, env string) error {
+	if env == "" {
+		return nil
+	}
+	override, ok := cfg.Environments[env]
+	if !ok {
+		if len(cfg.Environments) == 0 {
+			return fmt.Errorf("environment %q (from HERMES_ENV) is not defined, the config has no `environments`", env)
+		}
+		names := make([]string, 0, len(cfg.Environments))
+		for name := range cfg.Environments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("environment %q (from HERMES_ENV) is not defined in `environments` (defined: %s)", env, strings.Join(names, ", "))
+	}
+	if override.Dir != "" {
+		cfg.Dir = override.Dir
+	}
+	if override.Stylesheet != "" {
+		cfg.Stylesheet = override.Stylesheet
+	}
+	if override.BaseURL != "" {
+		cfg.BaseURL = override.BaseURL
+	}
+	return nil
+}
+
+func resolvePath(base, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+func readFontsYAML(path string) (*FontsYAML, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var doc yaml.Node
+	dec := yaml.NewDecoder(f)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	applyGlobalDefaults(&doc)
+	if err := applyPresets(&doc); err != nil {
+		return nil, err
+	}
+	var cfg FontsYAML
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validateFontsYAML checks the fields install cannot run without
+func validateFontsYAML(cfg *FontsYAML) error {
+	if cfg.Version > configVersion {
+		return fmt.Errorf("config schema version %d is newer than this hermes supports (%d), upgrade hermes", cfg.Version, configVersion)
+	}
+	if cfg.Dir == "" {
+		return fmt.Errorf("`dir` not specified in YAML")
+	}
+	if cfg.Stylesheet == "" {
+		return fmt.Errorf("`stylesheet` not specified in YAML")
+	}
+	if len(cfg.Fonts) == 0 {
+		return fmt.Errorf("no fonts specified in YAML")
+	}
+	for _, entry := range cfg.Fonts {
+		if err := entry.validate(); err != nil {
+			return err
+		}
+		if err := entry.validateCritical(); err != nil {
+			return err
+		}
+	}
+	if err := validateCriticalStylesheet(cfg); err != nil {
+		return err
+	}
+	if _, err := renderHeader(cfg.Header, time.Time{}); err != nil {
+		return err
+	}
+	if err := validateFamilyAffixes(cfg); err != nil {
+		return err
+	}
+	if err := validateCharset(cfg); err != nil {
+		return err
+	}
+	switch cfg.OnEmpty {
+	case "", "keep", "write", "error":
+	default:
+		return fmt.Errorf("`on_empty` must be keep, write or error, got %q", cfg.OnEmpty)
+	}
+	if err := validateMirrors(cfg.Mirrors); err != nil {
+		return err
+	}
+	if err := validateFormatOrder(cfg.FormatOrder); err != nil {
+		return err
+	}
+	if err := validateLayout(cfg); err != nil {
+		return err
+	}
+	if cfg.CSSLayer != "" && !cssLayerName.MatchString(cfg.CSSLayer) {
+		return fmt.Errorf("css_layer %q is not a valid layer name, e.g. fonts or base.fonts", cfg.CSSLayer)
+	}
+	if err := validateVariantAliases(cfg.VariantAliases); err != nil {
+		return err
+	}
+	if cfg.ManifestFormat != nil {
+		if cfg.Manifest == "" {
+			return fmt.Errorf("`manifest_format` needs a `manifest` path")
+		}
+		if err := cfg.ManifestFormat.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.GoEmbed != nil {
+		if err := cfg.GoEmbed.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.ServerConfig != nil {
+		if err := cfg.ServerConfig.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Menu != nil {
+		if err := cfg.Menu.validate(cfg); err != nil {
+			return err
+		}
+	}
+	if err := validateTargets(cfg); err != nil {
+		return err
+	}
+	if cfg.Fontsource != "" {
+		// dir's cleanup would remove the packages' files
+		if rel, err := filepath.Rel(cfg.Dir, cfg.Fontsource); err == nil && !strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("`fontsource` must be a directory outside `dir`")
+		}
+	}
+	if cfg.URLSigning != nil {
+		if err := cfg.URLSigning.validate(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.MetricPrecision != nil && (*cfg.MetricPrecision < 0 || *cfg.MetricPrecision > maxMetricPrecision) {
+		return fmt.Errorf("`metric_precision` must be between 0 and %d, got %d", maxMetricPrecision, *cfg.MetricPrecision)
+	}
+	return validatePrecompress(cfg.Precompress)
+}
+
+// mergeDuplicateFonts combines entries whose family names normalize to the
+// same family, merging their variant lists into the first entry. With mode
+// "error" duplicates are reported as an error instead.
+func mergeDuplicateFonts(fonts []FontEntry, mode string) ([]FontEntry, error) {
+	if mode != "merge" && mode != "error" {
+		return nil, fmt.Errorf("invalid duplicate handling %q (expected merge or error)", mode)
+	}
+	merged := []FontEntry{}
+	seen := map[string]int{}
+	for _, entry := range fonts {
+		key := entry.key()
+		i, ok := seen[key]
+		if !ok {
+			seen[key] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+		if mode == "error" {
+			return nil, fmt.Errorf("font %q is listed more than once (as %q and %q)", key, merged[i].Family, entry.Family)
+		}
+		printWarning("font %q is listed more than once, merging its variants into the first entry", entry.Family)
+		first := &merged[i]
+		if first.Order == nil {
+			first.Order = entry.Order
+		}
+		first.Variants = append([]string{}, first.Variants...)
+		for _, variant := range entry.Variants {
+			if !slices.Contains(first.Variants, variant) {
+				first.Variants = append(first.Variants, variant)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// applyOnlyVariants intersects each font's variants with its only_variants
+// filter. Fonts that list no variants get the filtered available variants
+// once their metadata is known.
+func applyOnlyVariants(fonts []FontEntry, global []string) []FontEntry {
+	filtered := make([]FontEntry, len(fonts))
+	for i, entry := range fonts {
+		filtered[i] = entry
+		only := global
+		if entry.OnlyVariants != nil {
+			only = *entry.OnlyVariants
+		}
+		if len(only) == 0 || entry.CSSURL != "" {
+			continue
+		}
+		if len(entry.Variants) == 0 {
+			filtered[i].only = only
+			continue
+		}
+		filtered[i].Variants = intersectVariants(entry.Variants, only)
+		if len(filtered[i].Variants) == 0 {
+			printWarning("none of the variants of %s are in only_variants %v", entry.Family, only)
+		}
+	}
+	return filtered
+}
+
+// intersectVariants returns the variants that are also in only, in order
+func intersectVariants(variants, only []string) []string {
+	kept := []string{}
+	for _, variant := range variants {
+		if slices.Contains(only, variant) {
+			kept = append(kept, variant)
+		}
+	}
+	return kept
+}
+
+// wantsStaticFallback reports whether a variable font's static files are
+// installed alongside it. Text subsets are always static.
+func (e FontEntry) wantsStaticFallback() bool {
+	return (e.VariableFallback || e.VariableSupportsGuard) && e.Text == ""
+}
+
+func (e FontEntry) validate() error {
+	if !e.selfHosted() && e.Text != "" {
+		return fmt.Errorf("font %s: `text` subsets must be self-hosted, remove `self_host: false`", e.Family)
+	}
+	if e.Timeout != nil && *e.Timeout <= 0 {
+		return fmt.Errorf("font %s: `timeout` must be positive, e.g. 2m", e.name())
+	}
+	if e.Retries != nil && *e.Retries < 0 {
+		return fmt.Errorf("font %s: `retries` cannot be negative", e.name())
+	}
+	if slices.Contains(e.Tags, "") {
+		return fmt.Errorf("font %s: `tags` cannot hold an empty tag", e.name())
+	}
+	if e.Fallback != nil {
+		if err := e.Fallback.validate(); err != nil {
+			return fmt.Errorf("font %s: %v", e.name(), err)
+		}
+	}
+	if e.Remote != nil {
+		if e.selfHosted() {
+			return fmt.Errorf("font %s: `remote` only applies with `self_host: false`", e.name())
+		}
+		if err := e.Remote.validate(); err != nil {
+			return fmt.Errorf("font %s: %v", e.name(), err)
+		}
+	}
+	if err := e.validateFeatures(); err != nil {
+		return err
+	}
+	for variant, value := range e.Stretch {
+		if variant != "all" && !variantToken.MatchString(variant) {
+			return fmt.Errorf("font %s: `stretch` key %q is not a variant or all", e.name(), variant)
+		}
+		if err := validateStretch(value); err != nil {
+			return fmt.Errorf("font %s: %v", e.name(), err)
+		}
+	}
+	if e.Metrics != nil {
+		if err := e.Metrics.validate(); err != nil {
+			return fmt.Errorf("font %s: %v", e.name(), err)
+		}
+	}
+	for variant, value := range e.Weight {
+		if len(e.Variants) > 0 && !slices.Contains(e.Variants, variant) {
+			return fmt.Errorf("font %s: `weight` key %s is not one of its variants", e.name(), variant)
+		}
+		if err := validateWeight(value); err != nil {
+			return fmt.Errorf("font %s: `weight` of %s: %v", e.name(), variant, err)
+		}
+	}
+	if e.CSSURL == "" {
+		if e.Family == "" {
+			return fmt.Errorf("font entry without a `family`, `google_url` or `css_url`")
+		}
+		if len(e.Subsets) > 0 && (e.Text != "" || e.VariableFallback || e.VariableSupportsGuard) {
+			return fmt.Errorf("font %s: `subsets` cannot be combined with text or variable font fallbacks", e.Family)
+		}
+		if e.CombinedItalic && (len(e.Axes) > 0 || len(e.Subsets) > 0 || e.Text != "" || e.wantsStaticFallback()) {
+			return fmt.Errorf("font %s: `combined_italic` cannot be combined with axes, subsets, text or variable font fallbacks", e.Family)
+		}
+		if len(e.Axes) > 0 {
+			if err := validateAxes(e.Axes); err != nil {
+				return fmt.Errorf("font %s: %v", e.Family, err)
+			}
+			if len(e.Subsets) > 0 || e.Text != "" || e.VariableFallback || e.VariableSupportsGuard || e.NearestWeight || len(e.Stretch) > 0 || len(e.Weight) > 0 {
+				return fmt.Errorf("font %s: `axes` cannot be combined with subsets, text, stretch, weight, nearest_weight or variable font fallbacks", e.Family)
+			}
+			for _, variant := range e.Variants {
+				if variant != "regular" && variant != "italic" {
+					return fmt.Errorf("font %s: with `axes`, variants may only be regular and italic, not %s", e.Family, variant)
+				}
+			}
+			return nil
+		}
+		if len(e.Subsets) == 0 && (len(e.SkipSubsets) > 0 || e.GroupSubsets) {
+			return fmt.Errorf("font %s: `skip_subsets` and `group_subsets` need `subsets`", e.Family)
+		}
+		return nil
+	}
+	if e.Family != "" || len(e.Variants) > 0 || e.Text != "" || len(e.Subsets) > 0 || len(e.Stretch) > 0 || len(e.Weight) > 0 || len(e.Axes) > 0 || e.CombinedItalic || e.Metrics != nil {
+		return fmt.Errorf("font entry with `css_url` %s cannot also set family, variants, text, subsets, stretch, weight, axes, combined_italic or metrics", e.CSSURL)
+	}
+	return nil
+}
+
+// selfHosted reports whether the entry's files are downloaded into dir
+func (e FontEntry) selfHosted() bool {
+	return e.SelfHost == nil || *e.SelfHost
+}
+
+// name is how an entry is referred to in messages
+func (e FontEntry) name() string {
+	if e.CSSURL != "" {
+		return e.CSSURL
+	}
+	return e.Family
+}
+
+// key identifies the font an entry installs when detecting duplicates
+func (e FontEntry) key() string {
+	if e.CSSURL != "" {
+		return e.CSSURL
+	}
+	return parseFontFamily(e.Family)
+}
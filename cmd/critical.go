@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// critical reports whether a variant's rules go to critical_stylesheet
+func (e FontEntry) critical(variant string) bool {
+	return slices.Contains(e.Critical, "all") || slices.Contains(e.Critical, variant)
+}
+
+func (e FontEntry) validateCritical() error {
+	for _, variant := range e.Critical {
+		if variant == "all" {
+			continue
+		}
+		// the variants of css_url and axes entries are only known from the fetched rules
+		if e.CSSURL != "" || len(e.Axes) > 0 {
+			return fmt.Errorf("font %s: `critical` can only be [all] for css_url and axes entries", e.name())
+		}
+		if !variantToken.MatchString(variant) {
+			return fmt.Errorf("font %s: `critical` entry %q is not a variant or all", e.name(), variant)
+		}
+	}
+	return nil
+}
+
+// validateCriticalStylesheet checks that critical fonts have somewhere to go
+func validateCriticalStylesheet(cfg *FontsYAML) error {
+	for _, entry := range cfg.Fonts {
+		if len(entry.Critical) > 0 && cfg.CriticalStylesheet == "" {
+			return fmt.Errorf("font %s: `critical` needs a `critical_stylesheet` to write its rules to", entry.name())
+		}
+	}
+	switch {
+	case cfg.CriticalStylesheet == stdoutStylesheet:
+		return fmt.Errorf("`critical_stylesheet` cannot be written to stdout")
+	case cfg.CriticalStylesheet != "" && filepath.Clean(cfg.CriticalStylesheet) == filepath.Clean(cfg.Stylesheet):
+		return fmt.Errorf("`critical_stylesheet` must differ from `stylesheet`")
+	}
+	return nil
+}
+
+// splitCritical separates the rules of critical variants from the rest,
+// dropping the empty rules of skipped variants
+func splitCritical(rules []string, critical []bool) (rest, crit []string) {
+	for i, rule := range rules {
+		switch {
+		case rule == "":
+		case critical[i]:
+			crit = append(crit, rule)
+		default:
+			rest = append(rest, rule)
+		}
+	}
+	return rest, crit
+}
+
+// removeStaleCriticalStylesheet removes the critical stylesheet the last
+// install wrote when the config now names another one, or none. With
+// stylesheet_region only its region is removed, as the file is shared.
+func removeStaleCriticalStylesheet(cfg *FontsYAML, prev string, verbose bool) {
+	if prev == "" || prev == cfg.CriticalStylesheet {
+		return
+	}
+	data, err := os.ReadFile(prev)
+	if err != nil {
+		return
+	}
+	what := "old critical stylesheet"
+	if cfg.StylesheetRegion {
+		what = "hermes region of old critical stylesheet"
+	}
+	if DryRun {
+		printStatus(colorCyan, "Would remove", "%s: %s", what, prev)
+		return
+	}
+	if verbose {
+		printStatus(colorYellow, "Removing", "%s: %s", what, prev)
+	}
+	if cfg.StylesheetRegion {
+		if css, ok := removeRegion(string(data)); ok {
+			if err := writeCSS(prev, cfg.sharedCharset(css), css); err != nil {
+				printWarning("failed to remove the hermes region of %s: %v", prev, err)
+			}
+		}
+		return
+	}
+	os.Remove(prev)
+}
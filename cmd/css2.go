@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// css2API is the Google Fonts CSS API used for features the Developer API
+// does not offer, such as subsetting a font to a given text
+const css2API = "https://fonts.googleapis.com/css2"
+
+// css2UserAgent makes the CSS API serve woff2 files; it picks the font
+// format based on the browser it thinks it is talking to
+const css2UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+var cssSrcURL = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+
+// css2VariantURL builds the CSS API URL for a single variant of family
+func css2VariantURL(family, variant string) string {
+	style, weight := variantStyleWeight(variant)
+	ital := "0"
+	if style == "italic" {
+		ital = "1"
+	}
+	return css2API + "?family=" + strings.ReplaceAll(family, " ", "+") + ":ital,wght@" + ital + "," + weight
+}
+
+// textSubsetURL builds the CSS API URL for a variant subsetted to text
+func textSubsetURL(family, variant, text string) string {
+	return css2VariantURL(family, variant) + "&text=" + url.QueryEscape(text)
+}
+
+// textSubsetComment is placed above the @font-face rule of a text subset,
+// with the text on one line and kept from closing the comment early
+func textSubsetComment(text string) string {
+	text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+	return `/* text subset: "` + strings.ReplaceAll(text, "*/", "* /") + `" */`
+}
+
+// fetchCSS downloads a CSS API stylesheet, asking for woff2 font files
+func fetchCSS(cssURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, cssURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", css2UserAgent)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("bad status: %s", res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// resolveCSS2FontURL fetches a CSS API stylesheet and returns the first font src url in it
+func resolveCSS2FontURL(cssURL string) (string, error) {
+	body, err := fetchCSS(cssURL)
+	if err != nil {
+		return "", err
+	}
+	m := cssSrcURL.FindSubmatch(body)
+	if m == nil {
+		return "", fmt.Errorf("no font url found in %s", cssURL)
+	}
+	return string(m[1]), nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+)
+
+// The sentinel comments around the rules hermes manages in a stylesheet
+// shared with hand-written CSS, see stylesheet_region
+const (
+	regionStart = "/* hermes:start */"
+	regionEnd   = "/* hermes:end */"
+)
+
+// cssRegion splits css around its hermes region, returning the CSS between
+// the sentinels; ok is false when they are missing
+func cssRegion(css string) (before, region, after string, ok bool) {
+	start := strings.Index(css, regionStart)
+	if start < 0 {
+		return css, "", "", false
+	}
+	end := strings.Index(css[start:], regionEnd)
+	if end < 0 {
+		return css, "", "", false
+	}
+	end += start
+	region = strings.TrimPrefix(css[start+len(regionStart):end], "\n")
+	return css[:start], strings.TrimSuffix(region, "\n"), css[end+len(regionEnd):], true
+}
+
+// replaceRegion puts region between the sentinels of css, leaving the rest
+// as it is, and appends the sentinels when css has none
+func replaceRegion(css, region string) string {
+	block := regionStart + "\n" + region + "\n" + regionEnd
+	before, _, after, ok := cssRegion(css)
+	if ok {
+		return before + block + after
+	}
+	if strings.TrimSpace(css) == "" {
+		return block + "\n"
+	}
+	return strings.TrimRight(css, "\n") + "\n\n" + block + "\n"
+}
+
+// removeRegion removes the hermes region and its sentinels from css,
+// reporting whether it had one
+func removeRegion(css string) (string, bool) {
+	before, _, after, ok := cssRegion(css)
+	if !ok {
+		return css, false
+	}
+	before, after = strings.TrimRight(before, "\n"), strings.TrimLeft(after, "\n")
+	if before == "" {
+		return after, true
+	}
+	return before + "\n" + after, true
+}
+
+// sharedCharset is the charset of a stylesheet shared with hand-written
+// CSS: the configured one, or else the one the file declares, which
+// writeCSS would otherwise drop
+func (cfg *FontsYAML) sharedCharset(css string) string {
+	if charset := cfg.cssCharset(); charset != "" {
+		return charset
+	}
+	if rule, _, ok := strings.Cut(css, ";\n"); ok && strings.HasPrefix(rule, "@charset ") {
+		return strings.Trim(strings.TrimPrefix(rule, "@charset "), "\"'")
+	}
+	return ""
+}
+
+// readStylesheet reads the CSS hermes manages in the stylesheet at path:
+// the whole file, or with stylesheet_region only its region, which is
+// empty before the first install
+func (cfg *FontsYAML) readStylesheet(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !cfg.StylesheetRegion {
+		return string(data), err
+	}
+	_, region, _, _ := cssRegion(string(data))
+	return region, nil
+}
+
+// writeStylesheet writes the CSS hermes manages to the stylesheet at path,
+// with stylesheet_region replacing only its region
+func (cfg *FontsYAML) writeStylesheet(path, css string) error {
+	if !cfg.StylesheetRegion || path == stdoutStylesheet {
+		return writeCSS(path, cfg.cssCharset(), css)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return writeCSS(path, cfg.sharedCharset(string(data)), replaceRegion(string(data), css))
+}
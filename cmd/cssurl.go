@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cssFontFaceRule matches an @font-face rule and the subset comment the CSS
+// API places above it, e.g. /* latin-ext */
+var cssFontFaceRule = regexp.MustCompile(`(?:/\*\s*([^*]*?)\s*\*/\s*)?@font-face\s*\{([^}]*)\}`)
+
+var cssDescriptor = regexp.MustCompile(`([a-z-]+)\s*:\s*([^;]+);`)
+
+// cssFontFace is one @font-face rule of a fetched stylesheet
+type cssFontFace struct {
+	Subset string
+	Family string
+	Style  string
+	Weight string
+	// UnicodeRange is empty when the rule covers the whole font
+	UnicodeRange string
+	URL          string
+	// Body is the rule's declarations as fetched
+	Body string
+}
+
+// parseFontFaces extracts the @font-face rules of a CSS API stylesheet.
+// Rules without a src url are skipped.
+func parseFontFaces(css string) []cssFontFace {
+	faces := []cssFontFace{}
+	for _, m := range cssFontFaceRule.FindAllStringSubmatch(css, -1) {
+		face := cssFontFace{Subset: m[1], Style: "normal", Weight: "400", Body: m[2]}
+		for _, d := range cssDescriptor.FindAllStringSubmatch(m[2], -1) {
+			value := strings.TrimSpace(d[2])
+			switch d[1] {
+			case "font-family":
+				face.Family = strings.Trim(value, `'"`)
+			case "font-style":
+				face.Style = value
+			case "font-weight":
+				face.Weight = value
+			case "unicode-range":
+				face.UnicodeRange = value
+			}
+		}
+		if u := cssSrcURL.FindStringSubmatch(m[2]); u != nil {
+			face.URL = u[1]
+			faces = append(faces, face)
+		}
+	}
+	return faces
+}
+
+// variant names a rule's font the way the Developer API names variants,
+// with weight ranges of variable fonts written as e.g. 100-900
+func (f cssFontFace) variant() string {
+	return variantName(strings.Join(strings.Fields(f.Weight), "-"), f.Style == "italic")
+}
+
+// subsetKind turns a subset comment into a file name suffix, e.g. "[12]" into "12"
+func subsetKind(subset string, i int) string {
+	kind := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(subset))
+	if kind == "" {
+		kind = fmt.Sprint(i)
+	}
+	return kind
+}
+
+// installCSSURL localizes a ready-made CSS API stylesheet: every @font-face
+// rule's file is downloaded and the rule is kept as fetched, including its
+// unicode-range, with src pointing at the local file
+func (in *installer) installCSSURL(entry FontEntry) entryResult {
+	body, err := fetchCSS(entry.CSSURL)
+	if err != nil {
+		printError("failed to fetch %s: %v", entry.CSSURL, err)
+		exit(1)
+	}
+	faces := parseFontFaces(string(body))
+	if len(faces) == 0 {
+		familyWarning(entry.name(), "no @font-face rules found in %s", entry.CSSURL)
+		return entryResult{}
+	}
+	locked := &LockedFont{Family: faces[0].Family, Variants: map[string]*LockedVariant{}}
+	res := in.localizeFaces(entry, entry.CSSURL, faces, in.lock.Fonts[entry.CSSURL], locked, nil)
+	in.lockFont(entry.CSSURL, locked)
+	return res
+}
+
+// localizeFaces downloads the file of each fetched rule into locked and
+// returns the rules pointing at the local files. rewrite, when set, adjusts
+// each rule's declarations.
+func (in *installer) localizeFaces(entry FontEntry, source string, faces []cssFontFace, prev, locked *LockedFont, rewrite func(cssFontFace) string) entryResult {
+	var res entryResult
+	// kinds are taken before skipping so file names don't depend on skip_subsets
+	kinds := make([]string, 0, len(faces))
+	kept := faces[:0]
+	for i, face := range faces {
+		if !slices.Contains(entry.SkipSubsets, face.Subset) {
+			kept = append(kept, face)
+			kinds = append(kinds, subsetKind(face.Subset, i))
+		}
+	}
+	faces = kept
+	if len(faces) == 0 {
+		familyWarning(entry.name(), "skip_subsets leaves no @font-face rules of %s", source)
+		return res
+	}
+	keys := make([]string, len(faces))
+	urls := make([]string, len(faces))
+	fileNames := make([]string, len(faces))
+	for i, face := range faces {
+		// key by variant and subset, as each subset has its own file
+		keys[i] = face.variant() + " " + kinds[i]
+		urls[i] = face.URL
+		fileNames[i] = in.sourceFileName(face.URL, face.Family, face.variant(), kinds[i])
+	}
+	in.shareRepeatedURLs(entry, faces[0].Family, keys, urls, fileNames)
+	vs := make([]*LockedVariant, len(faces))
+	res.rules = make([]string, len(faces))
+	res.outcomes = make([]variantOutcome, len(faces))
+	var wg sync.WaitGroup
+	for i, face := range faces {
+		var prevVariant *LockedVariant
+		if prev != nil {
+			prevVariant = prev.Variants[keys[i]]
+		}
+		wg.Add(1)
+		go func(i int, face cssFontFace, fileName string) {
+			defer wg.Done()
+			start := time.Now()
+			defer func() { res.outcomes[i].Duration = time.Since(start) }()
+			faceEntry := entry
+			faceEntry.Family = face.Family
+			v, downloaded := in.fetch(faceEntry, keys[i], face.URL, fileName, prevVariant)
+			if v == nil {
+				res.outcomes[i] = in.outcome(face.Family, keys[i], statusInvalidURL)
+				res.outcomes[i].Error = "the stylesheet has an invalid font URL"
+				return
+			}
+			vs[i] = v
+			body := face.Body
+			if rewrite != nil {
+				body = rewrite(face)
+			}
+			body = in.cfg.renameFontFamily(body, face.Family)
+			if settings := entry.featureSettings(); settings != "" {
+				body = strings.TrimRight(body, " \n") + "\n  font-feature-settings: " + settings + ";\n"
+			}
+			if v.Remote {
+				res.outcomes[i] = in.outcome(face.Family, keys[i], statusRemote)
+				res.rules[i] = entry.preloadNote(in.variantSrc(v), v.Integrity) + "\n@font-face {" + body + "}"
+				return
+			}
+			status := statusUpToDate
+			if downloaded {
+				status = statusDownloaded
+			}
+			in.want(v.File)
+			body = in.setMetrics(body, entry, v)
+			res.outcomes[i] = in.outcome(face.Family, keys[i], status, v.File)
+			res.rules[i] = "@font-face {" + cssSrcURL.ReplaceAllLiteralString(body, "url('"+in.fileURL(v)+"')") + "}"
+			res.rules[i] = strings.Join(append([]string{res.rules[i]}, in.namedInstanceRules(entry, in.cfg.cssFamily(face.Family), res.rules[i], v)...), "\n\n")
+		}(i, face, fileNames[i])
+	}
+	wg.Wait()
+	subsets := make([]string, len(faces))
+	for i, key := range keys {
+		if vs[i] != nil {
+			locked.Variants[key] = vs[i]
+		}
+		subsets[i] = faces[i].Subset
+	}
+	res.rules = subsetRules(res.rules, subsets, entry.GroupSubsets)
+	if entry.critical("all") {
+		res.rules, res.critical = nil, res.rules
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// genericFamilies maps catalog categories to the generic family that ends
+// a default fallback stack. Other categories fall back to sans-serif.
+var genericFamilies = map[string]string{
+	"serif":       "serif",
+	"monospace":   "monospace",
+	"handwriting": "cursive",
+}
+
+// fallbackStack is what follows the family in its custom property, led by
+// the family's fallback font when it has one
+func (e FontEntry) fallbackStack(family, category string) string {
+	if e.FallbackStack != "" {
+		return e.FallbackStack
+	}
+	generic, ok := genericFamilies[category]
+	if !ok {
+		generic = "sans-serif"
+	}
+	if e.Fallback != nil {
+		return "'" + fallbackFamily(family) + "', " + generic
+	}
+	return generic
+}
+
+// cssVariableName names a family's custom property, e.g. --font-open-sans
+func cssVariableName(family string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(family))
+	return "--font-" + strings.Join(strings.FieldsFunc(name, func(r rune) bool { return r == '-' }), "-")
+}
+
+// cssVariables renders the :root rule declaring a custom property for each
+// installed family, in stylesheet order. It is empty when nothing was installed.
+// Properties are named after the upstream family and hold its cssFamily.
+func cssVariables(cfg *FontsYAML, lock *FontsLock) string {
+	entries := cfg.Fonts
+	decls := []string{}
+	seen := map[string]bool{}
+	for _, i := range stylesheetOrder(entries) {
+		entry := entries[i]
+		locked, ok := lock.Fonts[entry.name()]
+		if !ok || len(locked.Variants) == 0 || seen[locked.Family] {
+			continue
+		}
+		seen[locked.Family] = true
+		family := cfg.cssFamily(locked.Family)
+		decls = append(decls, fmt.Sprintf("  %s: '%s', %s;", cssVariableName(locked.Family), family, entry.fallbackStack(family, locked.Category)))
+	}
+	if len(decls) == 0 {
+		return ""
+	}
+	return ":root {\n" + strings.Join(decls, "\n") + "\n}"
+}
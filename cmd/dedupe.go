@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// flag variables
+var FlatStylesheet bool
+
+// sharesFiles reports whether identical downloads are stored once, with
+// --flat-stylesheet. The hashed layout always stores them once.
+func (in *installer) sharesFiles() bool {
+	return FlatStylesheet && in.cfg.Layout != layoutHashed
+}
+
+// shareFile stores the file just downloaded to filePath as fileName once:
+// when a file with the same sum is already in dir or was downloaded this
+// run, the download is removed and that file's name returned instead
+func (in *installer) shareFile(fileName, filePath, sum string) (string, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.filesBySum == nil {
+		in.filesBySum = map[string]string{}
+		for _, font := range in.lock.Fonts {
+			for _, v := range font.Variants {
+				for ; v != nil; v = v.Fallback {
+					if !v.Remote && v.upToDate(in.cfg.Dir) {
+						in.filesBySum[v.SHA256] = v.File
+					}
+				}
+			}
+		}
+	}
+	existing, ok := in.filesBySum[sum]
+	if !ok || existing == fileName {
+		in.filesBySum[sum] = fileName
+		return fileName, false
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		in.dedupedBytes += info.Size()
+	}
+	os.Remove(filePath)
+	in.dedupedFiles++
+	return existing, true
+}
+
+// logDeduped reports the files --flat-stylesheet stored once
+func (in *installer) logDeduped() {
+	if in.dedupedFiles == 0 {
+		return
+	}
+	files := "files"
+	if in.dedupedFiles == 1 {
+		files = "file"
+	}
+	printSuccess("Deduplicated", "%d %s with the same content as another, saving %s in %s", in.dedupedFiles, files, formatBytes(in.dedupedBytes), filepath.Clean(in.cfg.Dir))
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var DiffConfigJSON bool
+
+var diffConfigCmd = &cobra.Command{
+	Use:   "diff-config <old> <new>",
+	Short: "Compare the families and variants two configs install",
+	Long: `Resolves both configs against the provider, as install would, and lists
+the families and variants the new one adds and removes, without downloading
+font files or writing anything. Presets, only_variants and variant_aliases
+are applied, so a change that installs the same fonts shows no difference.
+The output is short enough to paste into a review; --json prints it for
+scripts.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolver := &memoResolver{Resolver: googleResolver{}, items: map[string]*FontItem{}}
+		diff := diffFontSets(resolvedFontSet(args[0], resolver), resolvedFontSet(args[1], resolver))
+		if DiffConfigJSON {
+			printResult("diff-config", &diff)
+			return
+		}
+		if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 {
+			fmt.Printf("%s and %s install the same fonts\n", args[0], args[1])
+			return
+		}
+		for _, f := range diff.Added {
+			fmt.Printf("%s %s (%s)\n", colorize(os.Stdout, colorGreen, "+"), f.Family, strings.Join(f.Variants, ", "))
+		}
+		for _, f := range diff.Removed {
+			fmt.Printf("%s %s (%s)\n", colorize(os.Stdout, colorRed, "-"), f.Family, strings.Join(f.Variants, ", "))
+		}
+		for _, f := range diff.Changed {
+			changes := []string{}
+			for _, variant := range f.Added {
+				changes = append(changes, "+"+variant)
+			}
+			for _, variant := range f.Removed {
+				changes = append(changes, "-"+variant)
+			}
+			fmt.Printf("%s %s: %s\n", colorize(os.Stdout, colorYellow, "~"), f.Family, strings.Join(changes, ", "))
+		}
+	},
+}
+
+// fontSetDiff is what a config change does to the installed fonts
+type fontSetDiff struct {
+	resultSchema
+	Added   []diffFamily  `json:"added"`
+	Removed []diffFamily  `json:"removed"`
+	Changed []diffChanges `json:"changed"`
+}
+
+// diffFamily is a family the change adds or removes with its variants
+type diffFamily struct {
+	Family   string   `json:"family"`
+	Variants []string `json:"variants"`
+}
+
+// diffChanges are the variants the change adds to and removes from a
+// family both configs install
+type diffChanges struct {
+	Family  string   `json:"family"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// memoResolver looks each family up once, as both configs mostly list the
+// same ones
+type memoResolver struct {
+	Resolver
+	items map[string]*FontItem
+}
+
+func (r *memoResolver) Resolve(family string) (*FontItem, error) {
+	if item, ok := r.items[family]; ok {
+		if item == nil {
+			return nil, errFontNotFound
+		}
+		return item, nil
+	}
+	item, err := r.Resolver.Resolve(family)
+	if err != nil && !errors.Is(err, errFontNotFound) {
+		return nil, err
+	}
+	r.items[family] = item
+	return item, err
+}
+
+// resolvedFontSet loads the config at path and returns the variants it
+// installs by the provider's family name
+func resolvedFontSet(path string, resolver Resolver) map[string][]string {
+	cfg, err := loadFontsYAML(path)
+	if err != nil {
+		printError("could not read YAML %s: %v", path, err)
+		exit(1)
+	}
+	if err := validateFontsYAML(cfg); err != nil {
+		printError("%s: %v", path, err)
+		exit(1)
+	}
+	if cfg.Fonts, err = mergeDuplicateFonts(cfg.Fonts, "merge"); err != nil {
+		printError("%s: %v", path, err)
+		exit(1)
+	}
+	cfg.Fonts = applyOnlyVariants(cfg.Fonts, cfg.OnlyVariants)
+	set := map[string][]string{}
+	add := func(family string, variants ...string) {
+		for _, variant := range variants {
+			if !slices.Contains(set[family], variant) {
+				set[family] = append(set[family], variant)
+			}
+		}
+	}
+	for _, entry := range cfg.Fonts {
+		if entry.CSSURL != "" {
+			css, err := fetchCSS(entry.CSSURL)
+			if err != nil {
+				printError("%s: css_url %s: %v", path, entry.CSSURL, err)
+				exit(1)
+			}
+			for _, face := range parseFontFaces(string(css)) {
+				add(face.Family, face.variant())
+			}
+			continue
+		}
+		resolved, err := resolver.Resolve(entry.Family)
+		if errors.Is(err, errFontNotFound) {
+			printWarning("%s: no font found for %s, comparing it as listed", path, entry.Family)
+			add(entry.Family, entry.Variants...)
+			continue
+		}
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		item := aliasVariants(*resolved, cfg.VariantAliases)
+		variants := entry.Variants
+		switch {
+		case len(variants) == 0 && len(entry.only) > 0:
+			variants = intersectVariants(item.Variants, entry.only)
+		case len(variants) == 0 && len(entry.Axes) > 0:
+			variants = []string{"regular"}
+		}
+		add(item.Family, variants...)
+	}
+	return set
+}
+
+// diffFontSets compares the families and variants of two configs, each
+// list sorted
+func diffFontSets(before, after map[string][]string) fontSetDiff {
+	diff := fontSetDiff{Added: []diffFamily{}, Removed: []diffFamily{}, Changed: []diffChanges{}}
+	for family, variants := range after {
+		if _, ok := before[family]; !ok && len(variants) > 0 {
+			diff.Added = append(diff.Added, diffFamily{family, sortedVariants(variants)})
+		}
+	}
+	for family, variants := range before {
+		newVariants, ok := after[family]
+		if !ok {
+			if len(variants) > 0 {
+				diff.Removed = append(diff.Removed, diffFamily{family, sortedVariants(variants)})
+			}
+			continue
+		}
+		c := diffChanges{Family: family, Added: []string{}, Removed: []string{}}
+		for _, variant := range newVariants {
+			if !slices.Contains(variants, variant) {
+				c.Added = append(c.Added, variant)
+			}
+		}
+		for _, variant := range variants {
+			if !slices.Contains(newVariants, variant) {
+				c.Removed = append(c.Removed, variant)
+			}
+		}
+		if len(c.Added) > 0 || len(c.Removed) > 0 {
+			c.Added, c.Removed = sortedVariants(c.Added), sortedVariants(c.Removed)
+			diff.Changed = append(diff.Changed, c)
+		}
+	}
+	for _, list := range [][]diffFamily{diff.Added, diff.Removed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Family < list[j].Family })
+	}
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Family < diff.Changed[j].Family })
+	return diff
+}
+
+// sortedVariants is a sorted copy of variants
+func sortedVariants(variants []string) []string {
+	sorted := slices.Clone(variants)
+	sort.Slice(sorted, func(i, j int) bool { return variantLess(sorted[i], sorted[j]) })
+	return sorted
+}
+
+func init() {
+	rootCmd.AddCommand(diffConfigCmd)
+	diffConfigCmd.Flags().BoolVar(&DiffConfigJSON, "json", false, "Print the differences as JSON, described by hermes schema --result diff-config")
+}
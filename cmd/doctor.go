@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorCheck is a single line of the doctor checklist
+type doctorCheck struct {
+	name string
+	err  error
+	// hint explains how to fix a failed check
+	hint string
+	// skipped checks are neither passed nor failed
+	skipped bool
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [config]",
+	Short: "Diagnose common environment and configuration problems",
+	Long: `Checks that the Google Fonts API is reachable, that GFONTS_KEY is valid,
+that the configured output paths are writable and that the config file is valid.
+No font files are downloaded.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := configFile(args)
+		checks := []doctorCheck{checkReachability(), checkAPIKey()}
+		cfg, err := loadFontsYAML(configPath)
+		if err == nil {
+			err = validateFontsYAML(cfg)
+		}
+		checks = append(checks, doctorCheck{
+			name: "Config file " + configPath + " is valid",
+			err:  err,
+			hint: "Fix the YAML syntax or pass the path to your config: hermes doctor path/to/fonts.yaml",
+		})
+		if err == nil {
+			checks = append(checks,
+				doctorCheck{
+					name: "Font directory " + cfg.Dir + " is writable",
+					err:  checkWritable(cfg.Dir),
+					hint: "Choose a different `dir` or fix the directory permissions",
+				},
+				doctorCheck{
+					name: "Stylesheet directory " + filepath.Dir(cfg.Stylesheet) + " is writable",
+					err:  checkWritable(filepath.Dir(cfg.Stylesheet)),
+					hint: "Choose a different `stylesheet` path or fix the directory permissions",
+				},
+			)
+		}
+		failed := 0
+		for _, c := range checks {
+			switch {
+			case c.skipped:
+				fmt.Printf("%s %s\n", colorize(os.Stdout, colorYellow, "[-]"), c.name)
+				fmt.Printf("    %s\n", c.hint)
+			case c.err != nil:
+				failed++
+				fmt.Printf("%s %s: %v\n", colorize(os.Stdout, colorRed, "[x]"), c.name, c.err)
+				fmt.Printf("    %s\n", c.hint)
+			default:
+				fmt.Printf("%s %s\n", colorize(os.Stdout, colorGreen, "[✓]"), c.name)
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("\n%d check(s) failed\n", failed)
+			exit(1)
+		}
+		fmt.Println("\nNo problems found")
+	},
+}
+
+func checkReachability() doctorCheck {
+	check := doctorCheck{
+		name: "Google Fonts API is reachable",
+		hint: "Check your network connection and proxy settings",
+	}
+	client := &http.Client{Transport: httpClient.Transport, Timeout: 10 * time.Second}
+	res, err := client.Get(webfontsAPI)
+	if err != nil {
+		check.err = err
+		return check
+	}
+	res.Body.Close()
+	return check
+}
+
+func checkAPIKey() doctorCheck {
+	check := doctorCheck{
+		name: "GFONTS_KEY is valid",
+		hint: "Get a key at https://console.cloud.google.com/apis/credentials and run: export GFONTS_KEY=<YOUR KEY>",
+	}
+	key := viper.GetString("GFONTS_KEY")
+	if key == "" {
+		check.name = "GFONTS_KEY is not set"
+		check.skipped = true
+		return check
+	}
+	res, err := ping(key)
+	if err != nil {
+		check.err = err
+		return check
+	}
+	if res.status != http.StatusOK {
+		check.err = fmt.Errorf("API responded with %s", res.message)
+	}
+	return check
+}
+
+// checkOutputsWritable runs checkWritable on each directory install
+// writes to, reporting every one that fails
+func checkOutputsWritable(cfg *FontsYAML, configPath string, toStdout bool) error {
+	dirs := []string{cfg.Dir, filepath.Dir(lockPath(configPath))}
+	if !toStdout {
+		dirs = append(dirs, filepath.Dir(cfg.Stylesheet))
+	}
+	if cfg.CriticalStylesheet != "" {
+		dirs = append(dirs, filepath.Dir(cfg.CriticalStylesheet))
+	}
+	seen := map[string]bool{}
+	var errs []error
+	for _, dir := range dirs {
+		if seen[filepath.Clean(dir)] {
+			continue
+		}
+		seen[filepath.Clean(dir)] = true
+		if err := checkWritable(dir); err != nil {
+			errs = append(errs, fmt.Errorf("%s is not writable: %w", dir, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkWritable creates and removes a temp file in dir. Missing directories are
+// checked against their nearest existing parent since install creates them.
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".hermes-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	doctorCmd.Flags().BoolVar(&RelativeToCWD, "relative-to-cwd", false, "Resolve dir and stylesheet relative to the working directory instead of the config file")
+}
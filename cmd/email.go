@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// emailClipSize is the message size past which Gmail clips an email,
+// hiding the rest of it, and its styles, behind a link
+const emailClipSize = 102 << 10
+
+// emailDescriptors are the descriptors email targets keep, the others,
+// such as unicode-range and font-display, being ignored by email clients
+var emailDescriptors = []string{"font-family", "font-style", "font-weight", "font-stretch"}
+
+// emailFormats are the file extensions an email target inlines, in order
+// of preference, by its format
+var emailFormats = map[string][]string{
+	"woff2": {"woff2", "ttf", "otf", "woff"},
+	// a woff2 is decoded to the ttf or otf it was compressed from
+	"ttf": {"ttf", "otf", "woff2"},
+}
+
+// emailRule rewrites a rule for an email target: each @font-face keeps the
+// descriptors of emailDescriptors and a single src, the target's format of
+// the self-hosted file inlined as a data: url, or the first url of a font
+// that isn't self-hosted. @supports blocks, which email clients don't
+// evaluate, @font-feature-values and faces of local fonts only are left
+// out, and so are comments.
+func emailRule(cfg *FontsYAML, t InstallTarget, rule string) (string, error) {
+	css, err := blankCSSComments(rule)
+	if err != nil {
+		return "", err
+	}
+	bodies, err := plainFontFaces(css, 0, len(css))
+	if err != nil {
+		return "", err
+	}
+	format := t.Format
+	if format == "" {
+		format = "woff2"
+	}
+	faces := []string{}
+	for _, body := range bodies {
+		var b strings.Builder
+		src := ""
+		for _, decl := range splitDeclarations(body) {
+			name, value, ok := strings.Cut(decl, ":")
+			name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+			switch {
+			case !ok:
+			case name == "src":
+				if src, err = emailSrc(cfg, format, value); err != nil {
+					return "", err
+				}
+			case slices.Contains(emailDescriptors, name):
+				fmt.Fprintf(&b, "  %s: %s;\n", name, value)
+			}
+		}
+		if src == "" {
+			continue
+		}
+		faces = append(faces, "@font-face {\n"+b.String()+"  src: "+src+";\n}")
+	}
+	return strings.Join(faces, "\n\n"), nil
+}
+
+// plainFontFaces collects the bodies of the @font-face rules of
+// css[start:end] outside @supports blocks, skipping any other rule
+func plainFontFaces(css string, start, end int) ([]string, error) {
+	bodies := []string{}
+	for i := start; i < end; {
+		open := strings.IndexAny(css[i:end], "{;")
+		if open < 0 {
+			break
+		}
+		open += i
+		if css[open] == ';' {
+			i = open + 1
+			continue
+		}
+		close, err := matchingBrace(css, open, end)
+		if err != nil {
+			return nil, err
+		}
+		at, _, _ := strings.Cut(strings.TrimSpace(css[i:open]), " ")
+		switch at {
+		case "@font-face":
+			bodies = append(bodies, css[open+1:close])
+		case "@layer", "@media":
+			inner, err := plainFontFaces(css, open+1, close)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, inner...)
+		}
+		i = close + 1
+	}
+	return bodies, nil
+}
+
+// emailSrc picks the src of an email rule from the urls of value, or none
+// when it only names local fonts
+func emailSrc(cfg *FontsYAML, format, value string) (string, error) {
+	var remote string
+	local := map[string]string{}
+	for _, m := range cssURL.FindAllStringSubmatch(value, -1) {
+		raw := m[1] + m[2] + m[3]
+		name, ok := localFontFile(raw, cfg.BaseURL)
+		if !ok {
+			if remote == "" {
+				remote = raw
+			}
+			continue
+		}
+		ext := strings.TrimPrefix(path.Ext(name), ".")
+		if _, ok := local[ext]; !ok {
+			local[ext] = name
+		}
+	}
+	for _, ext := range emailFormats[format] {
+		name, ok := local[ext]
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.Dir, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+		if format == "ttf" && ext == "woff2" {
+			if data, err = sfntData(data); err != nil {
+				return "", fmt.Errorf("could not decode %s: %w", name, err)
+			}
+			ext = "ttf"
+			if bytes.HasPrefix(data, []byte("OTTO")) {
+				// CFF outlines
+				ext = "otf"
+			}
+			name = strings.TrimSuffix(name, ".woff2") + "." + ext
+		}
+		return fmt.Sprintf("url('%s') format('%s')", dataURL(name, data), cfg.cssFormat(ext)), nil
+	}
+	if len(local) > 0 {
+		return "", fmt.Errorf("no %s file to inline in src: %s", format, value)
+	}
+	if remote != "" {
+		return "url('" + remote + "')", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FallbackFont declares a local system font standing in for the web font
+// while it loads. Its metric overrides make the fallback take up the same
+// space as the web font, so text doesn't shift when the web font arrives.
+// Example:
+//
+//	fonts:
+//	  - family: Roboto
+//	    fallback:
+//	      font: Arial
+//	      metrics:
+//	        size_adjust: 100.3%
+//	        ascent_override: 92.5%
+//	        descent_override: 24.3%
+//	        line_gap_override: 0%
+type FallbackFont struct {
+	// Font is the name of the local font, e.g. Arial
+	Font    string          `yaml:"font"`
+	Metrics FallbackMetrics `yaml:"metrics,omitempty"`
+}
+
+// FallbackMetrics are the percentages of the fallback rule's size-adjust and
+// metric override descriptors. Those left out aren't declared.
+type FallbackMetrics struct {
+	SizeAdjust      string `yaml:"size_adjust,omitempty"`
+	AscentOverride  string `yaml:"ascent_override,omitempty"`
+	DescentOverride string `yaml:"descent_override,omitempty"`
+	LineGapOverride string `yaml:"line_gap_override,omitempty"`
+}
+
+var cssPercentage = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?%$`)
+
+func (f *FallbackFont) validate() error {
+	if f.Font == "" {
+		return fmt.Errorf("`fallback` needs a `font`, e.g. Arial")
+	}
+	if strings.ContainsAny(f.Font, `'"\`) {
+		return fmt.Errorf("`fallback.font` %q cannot contain quotes or backslashes", f.Font)
+	}
+	for _, m := range []struct{ name, value string }{
+		{"size_adjust", f.Metrics.SizeAdjust},
+		{"ascent_override", f.Metrics.AscentOverride},
+		{"descent_override", f.Metrics.DescentOverride},
+		{"line_gap_override", f.Metrics.LineGapOverride},
+	} {
+		if m.value != "" && !cssPercentage.MatchString(m.value) {
+			return fmt.Errorf("`fallback.metrics.%s` must be a percentage, e.g. 95.5%%, got %q", m.name, m.value)
+		}
+	}
+	return nil
+}
+
+// fallbackFamily names the family of a font's fallback rule, e.g. "Roboto Fallback"
+func fallbackFamily(family string) string {
+	return family + " Fallback"
+}
+
+// rule renders the @font-face rule declaring the fallback for family
+func (f *FallbackFont) rule(family string) string {
+	rule := fmt.Sprintf("@font-face {\n  font-family: '%s';\n  src: local('%s');\n}", fallbackFamily(family), f.Font)
+	rule = addDescriptor(rule, "size-adjust", f.Metrics.SizeAdjust)
+	rule = addDescriptor(rule, "ascent-override", f.Metrics.AscentOverride)
+	rule = addDescriptor(rule, "descent-override", f.Metrics.DescentOverride)
+	return addDescriptor(rule, "line-gap-override", f.Metrics.LineGapOverride)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// fontFamilyDecl matches the font-family declaration of an @font-face body
+var fontFamilyDecl = regexp.MustCompile(`(?i)(font-family\s*:\s*)[^;}]*`)
+
+// cssFamily is the font-family the stylesheet declares for an upstream
+// family, with family_prefix and family_suffix, each set off by a space,
+// e.g. "ACME Roboto". The upstream name still resolves the font and names
+// its files.
+func (cfg *FontsYAML) cssFamily(family string) string {
+	if cfg.FamilyPrefix != "" {
+		family = cfg.FamilyPrefix + " " + family
+	}
+	if cfg.FamilySuffix != "" {
+		family += " " + cfg.FamilySuffix
+	}
+	return family
+}
+
+func validateFamilyAffixes(cfg *FontsYAML) error {
+	for field, value := range map[string]string{"family_prefix": cfg.FamilyPrefix, "family_suffix": cfg.FamilySuffix} {
+		if value == "" {
+			continue
+		}
+		if strings.TrimSpace(value) != value || !localNameSafe(value) || strings.Contains(value, `"`) {
+			return fmt.Errorf("`%s` %q must not have quotes, backslashes, control characters or surrounding spaces", field, value)
+		}
+	}
+	return nil
+}
+
+// renameFontFamily sets the font-family of a provider's @font-face body to
+// its cssFamily, leaving the body as it is without a prefix or suffix
+func (cfg *FontsYAML) renameFontFamily(body, family string) string {
+	if cfg.FamilyPrefix == "" && cfg.FamilySuffix == "" {
+		return body
+	}
+	return fontFamilyDecl.ReplaceAllLiteralString(body, "font-family: '"+cfg.cssFamily(family)+"'")
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// featureValueBlocks are the @font-feature-values blocks, with how many
+// values each name takes, 0 for one or more
+var featureValueBlocks = map[string]struct{ min, max int }{
+	"stylistic":         {1, 1},
+	"historical-forms":  {1, 1},
+	"styleset":          {1, 0},
+	"character-variant": {1, 2},
+	"swash":             {1, 1},
+	"ornaments":         {1, 1},
+	"annotation":        {1, 1},
+}
+
+var cssIdent = regexp.MustCompile(`^-?[_a-zA-Z][_a-zA-Z0-9-]*$`)
+
+// validFeatureTag reports whether tag is an OpenType feature tag: four
+// printable ASCII characters, which must not break out of the CSS string
+func validFeatureTag(tag string) bool {
+	if len(tag) != 4 {
+		return false
+	}
+	for i := 0; i < len(tag); i++ {
+		if c := tag[i]; c < 0x20 || c > 0x7e || c == '"' || c == '\'' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateFeatures checks the entry's font_feature_settings and
+// font_feature_values
+func (e FontEntry) validateFeatures() error {
+	for tag, value := range e.FeatureSettings {
+		if !validFeatureTag(tag) {
+			return fmt.Errorf("font %s: `font_feature_settings` key %q is not a four-character OpenType feature tag such as liga or smcp", e.name(), tag)
+		}
+		if value < 0 {
+			return fmt.Errorf("font %s: `font_feature_settings` %s cannot be negative", e.name(), tag)
+		}
+	}
+	for block, names := range e.FeatureValues {
+		count, ok := featureValueBlocks[block]
+		if !ok {
+			return fmt.Errorf("font %s: `font_feature_values` block %q is not one of stylistic, historical-forms, styleset, character-variant, swash, ornaments or annotation", e.name(), block)
+		}
+		for name, values := range names {
+			if !cssIdent.MatchString(name) {
+				return fmt.Errorf("font %s: `font_feature_values.%s` name %q is not a CSS identifier", e.name(), block, name)
+			}
+			fields := strings.Fields(values)
+			if len(fields) < count.min || (count.max > 0 && len(fields) > count.max) {
+				return fmt.Errorf("font %s: `font_feature_values.%s.%s` takes %s, got %q", e.name(), block, name, featureValueCount(count.min, count.max), values)
+			}
+			for _, f := range fields {
+				if n, err := strconv.Atoi(f); err != nil || n < 0 {
+					return fmt.Errorf("font %s: `font_feature_values.%s.%s` must be non-negative integers, got %q", e.name(), block, name, values)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func featureValueCount(min, max int) string {
+	switch {
+	case max == 0:
+		return "one or more values"
+	case min == max:
+		return "one value"
+	}
+	return fmt.Sprintf("%d to %d values", min, max)
+}
+
+// featureSettings is the font-feature-settings descriptor of the entry's
+// rules, e.g. "liga" 0, "smcp" 1, or empty when it sets none
+func (e FontEntry) featureSettings() string {
+	tags := make([]string, 0, len(e.FeatureSettings))
+	for tag := range e.FeatureSettings {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	settings := make([]string, len(tags))
+	for i, tag := range tags {
+		settings[i] = fmt.Sprintf("%q %d", tag, e.FeatureSettings[tag])
+	}
+	return strings.Join(settings, ", ")
+}
+
+// featureValuesRule renders the @font-feature-values rule naming the
+// entry's alternates for family, or "" when it names none
+func (e FontEntry) featureValuesRule(family string) string {
+	if len(e.FeatureValues) == 0 {
+		return ""
+	}
+	blocks := make([]string, 0, len(e.FeatureValues))
+	for block := range e.FeatureValues {
+		blocks = append(blocks, block)
+	}
+	sort.Strings(blocks)
+	var b strings.Builder
+	fmt.Fprintf(&b, "@font-feature-values '%s' {\n", family)
+	for _, block := range blocks {
+		names := make([]string, 0, len(e.FeatureValues[block]))
+		for name := range e.FeatureValues[block] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "  @%s {\n", block)
+		for _, name := range names {
+			fmt.Fprintf(&b, "    %s: %s;\n", name, strings.Join(strings.Fields(e.FeatureValues[block][name]), " "))
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
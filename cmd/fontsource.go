@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fontsourceDescription ends the description of the packages hermes
+// writes, telling them from others when removing stale ones
+const fontsourceDescription = " in the Fontsource layout, written by hermes"
+
+// fontsourceFace is one @font-face rule of a Fontsource package
+type fontsourceFace struct {
+	subset, weight, style string
+	// id names the face's files, e.g. roboto-latin-400-normal
+	id string
+	// decls are the rule's declarations, src rewritten to files
+	decls []string
+}
+
+// fontsourcePackage is the Fontsource package of a family, e.g.
+// @fontsource/roboto, or @fontsource-variable/roboto for its variable files
+type fontsourcePackage struct {
+	name, family string
+	faces        []fontsourceFace
+	// files maps the package's file names to the installed files
+	files map[string]string
+}
+
+// writeFontsource copies the installed fonts to dir in the layout of
+// Fontsource npm packages, so a project can swap a Fontsource dependency
+// for a local one ("@fontsource/roboto": "file:fonts/@fontsource/roboto")
+// and keep its imports. Matching Fontsource:
+//
+//   - each family is a package at @fontsource/<id>, where id is the
+//     family in lower case with dashes for spaces, with a package.json
+//     whose main and style are index.css
+//   - files are files/<id>-<subset>-<weight>-<style>.<ext>, e.g.
+//     files/roboto-latin-400-normal.woff2
+//   - index.css holds weight 400, or the lightest weight without it, and
+//     <weight>.css, <weight>-italic.css, <subset>.css, <subset>-<weight>.css
+//     and <subset>-<weight>-italic.css hold the faces their names select
+//   - variable files, with a weight range, go to @fontsource-variable/<id>
+//     under the family "<family> Variable", with wght in place of the
+//     weight, e.g. wght-italic.css
+//
+// Files of fonts installed without subsets get the subset "all". Rules of
+// fonts that aren't self-hosted and the unicode.json and metadata.json
+// files of Fontsource packages are left out. Packages hermes wrote for
+// families no longer installed are removed like stale files in dir.
+func writeFontsource(cfg *FontsYAML, dir string, rules []string, fonts map[string]*LockedFont, verbose bool) error {
+	type fileInfo struct{ family, subset string }
+	installed := map[string]fileInfo{}
+	for _, font := range fonts {
+		for key, v := range font.Variants {
+			subset := "all"
+			if _, kind, ok := strings.Cut(key, " "); ok {
+				subset = kind
+			}
+			for f := v; f != nil; f = f.Fallback {
+				if !f.Remote && f.File != "" {
+					installed[f.File] = fileInfo{font.Family, subset}
+				}
+			}
+		}
+	}
+	css, err := blankCSSComments(strings.Join(rules, "\n\n"))
+	if err != nil {
+		return err
+	}
+	bodies, err := plainFontFaces(css, 0, len(css))
+	if err != nil {
+		return err
+	}
+	packages := map[string]*fontsourcePackage{}
+	for _, body := range bodies {
+		decls := map[string]string{}
+		var order []string
+		for _, decl := range splitDeclarations(body) {
+			name, value, ok := strings.Cut(decl, ":")
+			if ok {
+				name = strings.TrimSpace(name)
+				decls[name] = strings.TrimSpace(value)
+				order = append(order, name)
+			}
+		}
+		// the face's installed files, one per format
+		files := map[string]string{}
+		var exts []string
+		var info fileInfo
+		for _, m := range cssURL.FindAllStringSubmatch(decls["src"], -1) {
+			name, local := localFontFile(m[1]+m[2]+m[3], cfg.BaseURL)
+			fi, ok := installed[name]
+			ext := strings.TrimPrefix(path.Ext(name), ".")
+			if !local || !ok || files[ext] != "" {
+				continue
+			}
+			if len(files) == 0 {
+				info = fi
+			}
+			files[ext] = name
+			exts = append(exts, ext)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		face := fontsourceFace{subset: info.subset, weight: decls["font-weight"], style: "normal"}
+		if strings.HasPrefix(decls["font-style"], "italic") {
+			face.style = "italic"
+		}
+		scope, family := "@fontsource", decls["font-family"]
+		variable := strings.Contains(face.weight, " ")
+		if variable {
+			scope, face.weight = "@fontsource-variable", "wght"
+			family = "'" + strings.Trim(family, `'"`) + " Variable'"
+		}
+		id := strings.ToLower(strings.ReplaceAll(info.family, " ", "-"))
+		face.id = fmt.Sprintf("%s-%s-%s-%s", id, face.subset, face.weight, face.style)
+		pkg := packages[scope+"/"+id]
+		if pkg == nil {
+			pkg = &fontsourcePackage{name: scope + "/" + id, family: info.family, files: map[string]string{}}
+			packages[pkg.name] = pkg
+		}
+		if _, ok := pkg.files[face.id+"."+exts[0]]; ok {
+			// another rule of the same file and face
+			continue
+		}
+		srcs := make([]string, len(exts))
+		for i, ext := range exts {
+			pkg.files[face.id+"."+ext] = files[ext]
+			srcs[i] = fmt.Sprintf("url(./files/%s.%s) format('%s')", face.id, ext, cfg.cssFormat(ext))
+		}
+		decls["src"] = strings.Join(srcs, ", ")
+		decls["font-family"] = family
+		for _, name := range order {
+			face.decls = append(face.decls, name+": "+decls[name])
+		}
+		pkg.faces = append(pkg.faces, face)
+	}
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pkg := packages[name]
+		if verbose {
+			fmt.Printf("Writing Fontsource package %s to %s\n", pkg.name, filepath.Join(dir, filepath.FromSlash(pkg.name)))
+		}
+		if err := pkg.write(cfg, filepath.Join(dir, filepath.FromSlash(pkg.name))); err != nil {
+			return fmt.Errorf("%s: %w", pkg.name, err)
+		}
+	}
+	if cfg.clean() && OnlyFamily == "" && len(Tags) == 0 {
+		removeStaleFontsource(dir, packages, verbose)
+	}
+	return nil
+}
+
+// removeStaleFontsource removes the packages hermes wrote to dir for
+// families no longer installed, leaving any other package alone
+func removeStaleFontsource(dir string, packages map[string]*fontsourcePackage, verbose bool) {
+	for _, scope := range []string{"@fontsource", "@fontsource-variable"} {
+		entries, err := os.ReadDir(filepath.Join(dir, scope))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if _, ok := packages[scope+"/"+e.Name()]; ok || !e.IsDir() {
+				continue
+			}
+			pkgDir := filepath.Join(dir, scope, e.Name())
+			data, err := os.ReadFile(filepath.Join(pkgDir, "package.json"))
+			if err != nil || !strings.Contains(string(data), fontsourceDescription) {
+				continue
+			}
+			if verbose {
+				fmt.Printf("Removing Fontsource package %s/%s\n", scope, e.Name())
+			}
+			os.RemoveAll(pkgDir)
+		}
+	}
+}
+
+// write writes the package to dir, removing the files and stylesheets of
+// faces no longer installed
+func (pkg *fontsourcePackage) write(cfg *FontsYAML, dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "files"), 0755); err != nil {
+		return err
+	}
+	for name, src := range pkg.files {
+		data, err := os.ReadFile(filepath.Join(cfg.Dir, filepath.FromSlash(src)))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, "files", name)
+		if !unchanged(target, data) {
+			if err := writeFileAtomic(target, data); err != nil {
+				return err
+			}
+		}
+	}
+	stylesheets := pkg.stylesheets()
+	for name, faces := range stylesheets {
+		rules := make([]string, len(faces))
+		for i, f := range faces {
+			rules[i] = "/* " + f.id + " */\n@font-face {\n  " + strings.Join(f.decls, ";\n  ") + ";\n}"
+		}
+		if err := writeOutput(filepath.Join(dir, name), []byte(strings.Join(rules, "\n\n")+"\n")); err != nil {
+			return err
+		}
+	}
+	manifest, err := json.MarshalIndent(struct {
+		Name        string `json:"name"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+		Main        string `json:"main"`
+		Style       string `json:"style"`
+	}{pkg.name, "0.0.0", pkg.family + fontsourceDescription, "index.css", "index.css"}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(filepath.Join(dir, "package.json"), append(manifest, '\n')); err != nil {
+		return err
+	}
+	if !cfg.clean() || OnlyFamily != "" || len(Tags) > 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "files"))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, ok := pkg.files[e.Name()]; !ok && !e.IsDir() {
+			os.Remove(filepath.Join(dir, "files", e.Name()))
+		}
+	}
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, ok := stylesheets[e.Name()]; !ok && filepath.Ext(e.Name()) == ".css" {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return nil
+}
+
+// stylesheets groups the package's faces by the Fontsource stylesheets
+// holding them, e.g. 700-italic.css and latin-700-italic.css
+func (pkg *fontsourcePackage) stylesheets() map[string][]fontsourceFace {
+	sheets := map[string][]fontsourceFace{}
+	weights := []string{}
+	for _, f := range pkg.faces {
+		name := f.weight
+		if f.style == "italic" {
+			name += "-italic"
+		}
+		sheets[name+".css"] = append(sheets[name+".css"], f)
+		sheets[f.subset+"-"+name+".css"] = append(sheets[f.subset+"-"+name+".css"], f)
+		if f.style == "normal" {
+			weights = append(weights, f.weight)
+		}
+	}
+	if len(weights) == 0 {
+		// an italic-only family's index holds its italics
+		for _, f := range pkg.faces {
+			weights = append(weights, f.weight)
+		}
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		a, _ := strconv.Atoi(weights[i])
+		b, _ := strconv.Atoi(weights[j])
+		return a < b
+	})
+	index := weights[0]
+	for _, w := range weights {
+		if w == "400" {
+			index = w
+		}
+	}
+	for _, f := range pkg.faces {
+		if f.weight == index && (f.style == "normal" || len(sheets[index+".css"]) == 0) {
+			sheets["index.css"] = append(sheets["index.css"], f)
+			sheets[f.subset+".css"] = append(sheets[f.subset+".css"], f)
+		}
+	}
+	return sheets
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FormatOptions controls the src descriptors emitted for files of one extension
+// Example:
+//
+//	formats:
+//	  woff2:
+//	    tech: ["color-COLRv1"]
+type FormatOptions struct {
+	// Format is the format() string, defaulting to the extension's usual one
+	Format string `yaml:"format,omitempty"`
+	// Tech lists tech() hints added to every src of this format
+	Tech []string `yaml:"tech,omitempty"`
+}
+
+// defaultFormats maps file extensions to their CSS format() strings
+var defaultFormats = map[string]string{
+	"woff2": "woff2",
+	"woff":  "woff",
+	"ttf":   "truetype",
+	"otf":   "opentype",
+}
+
+// defaultFormatOrder is the src order of a rule's files by extension when
+// format_order is not set, the smallest format first
+var defaultFormatOrder = []string{"woff2", "woff", "ttf", "otf"}
+
+// validateFormatOrder checks that format_order lists each extension once
+func validateFormatOrder(order []string) error {
+	seen := map[string]bool{}
+	for _, ext := range order {
+		if ext == "" {
+			return fmt.Errorf("`format_order` has an empty entry")
+		}
+		if seen[strings.ToLower(ext)] {
+			return fmt.Errorf("`format_order` lists %s more than once", ext)
+		}
+		seen[strings.ToLower(ext)] = true
+	}
+	return nil
+}
+
+// orderSrcs sorts a rule's src entries by format_order. Files of the same
+// format keep their order, and formats not listed go last.
+func (in *installer) orderSrcs(srcs []fontSrc) []fontSrc {
+	order := in.cfg.FormatOrder
+	if order == nil {
+		order = defaultFormatOrder
+	}
+	rank := func(s fontSrc) int {
+		if s.Local != "" {
+			// an installed copy is always preferred to a download
+			return -1
+		}
+		if i := slices.IndexFunc(order, func(ext string) bool { return strings.EqualFold(ext, s.ext) }); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	sorted := slices.Clone(srcs)
+	slices.SortStableFunc(sorted, func(a, b fontSrc) int { return rank(a) - rank(b) })
+	return sorted
+}
+
+// fontSrc builds the src entry for a font file from its extension's format
+// options, adding any extra tech() hints
+func (in *installer) fontSrc(fileName string, tech ...string) fontSrc {
+	ext := strings.TrimPrefix(filepath.Ext(fileName), ".")
+	opts := in.cfg.Formats[ext]
+	src := fontSrc{URL: in.srcURL(fileName), Format: in.cfg.cssFormat(ext), ext: ext}
+	src.Tech = append(src.Tech, opts.Tech...)
+	for _, t := range tech {
+		if !slices.Contains(src.Tech, t) {
+			src.Tech = append(src.Tech, t)
+		}
+	}
+	return src
+}
+
+// cssFormat is the format() string of ext, its formats: override or else
+// the extension's usual one
+func (cfg *FontsYAML) cssFormat(ext string) string {
+	if format := cfg.Formats[ext].Format; format != "" {
+		return format
+	}
+	if format, ok := defaultFormats[ext]; ok {
+		return format
+	}
+	return ext
+}
+
+// variantSrcs is variantSrc followed, with convert_woff, by the src of the
+// woff converted from the variant's file
+func (in *installer) variantSrcs(v *LockedVariant, tech ...string) []fontSrc {
+	srcs := []fontSrc{in.variantSrc(v, tech...)}
+	if in.cfg.ConvertWOFF && !v.Remote && strings.HasSuffix(v.File, ".woff2") && in.convertWOFF(v.File) {
+		woff := *v
+		woff.File = woffFileName(v.File)
+		v.WOFF = woff.File
+		srcs = append(srcs, in.variantSrc(&woff, tech...))
+	}
+	return srcs
+}
+
+// convertWOFF converts a woff2 file to woff once per run, however many
+// variants share it, and marks the woff wanted. A font that fails to
+// convert only gets a warning and keeps just its woff2 src.
+func (in *installer) convertWOFF(fileName string) bool {
+	in.mu.Lock()
+	convert, ok := in.woffs[fileName]
+	if !ok {
+		convert = sync.OnceValue(func() bool {
+			woff := woffFileName(fileName)
+			if !DryRun {
+				converted, err := convertWOFFFile(in.filePath(fileName), filepath.Join(in.writeDir(), woff), in.filePath(woff))
+				if err != nil {
+					printWarning("could not convert %s to woff: %v", fileName, err)
+					return false
+				}
+				if converted && in.verbose {
+					printSuccess("Converted", "%s to %s", fileName, woff)
+				}
+			}
+			in.mu.Lock()
+			in.wantedFiles[woff] = struct{}{}
+			in.mu.Unlock()
+			return true
+		})
+		in.woffs[fileName] = convert
+	}
+	in.mu.Unlock()
+	return convert()
+}
+
+// variantSrc is the src entry for a locked file, pointing at the
+// provider's url when the font isn't self-hosted
+func (in *installer) variantSrc(v *LockedVariant, tech ...string) fontSrc {
+	if !v.Remote {
+		src := in.fontSrc(v.File, tech...)
+		src.URL = in.fileURL(v)
+		return src
+	}
+	name := v.URL
+	if u, err := url.Parse(v.URL); err == nil {
+		name = path.Base(u.Path)
+	}
+	src := in.fontSrc(name, tech...)
+	src.URL = v.URL
+	return src
+}
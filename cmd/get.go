@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,6 +17,9 @@ import (
 	"golang.org/x/text/language"
 )
 
+// webfontsAPI is the Google Fonts Developer API endpoint
+const webfontsAPI = "https://www.googleapis.com/webfonts/v1/webfonts"
+
 // flag variables
 var Dir string
 
@@ -28,12 +32,23 @@ type Axes struct {
 
 // extract font file path url from Google Fonts API JSON response
 type Font struct {
-	Items []struct {
-		Family   string            `json:"family"`
-		Variants []string          `json:"variants"`
-		Files    map[string]string `json:"files"`
-		Axes     []*Axes           `json:"axes,omitempty"`
-	} `json:"items"`
+	Items []FontItem `json:"items"`
+}
+
+// FontItem is a single font family in a Google Fonts API response
+type FontItem struct {
+	Family   string            `json:"family"`
+	Variants []string          `json:"variants"`
+	Files    map[string]string `json:"files"`
+	Axes     []*Axes           `json:"axes,omitempty"`
+	Subsets  []string          `json:"subsets,omitempty"`
+	// Category is the catalog's classification, e.g. serif or monospace
+	Category string `json:"category,omitempty"`
+	// Version and LastModified identify the catalog revision of the family
+	Version      string `json:"version,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// Menu is the url of the family's menu font, holding just the glyphs of its name
+	Menu string `json:"menu,omitempty"`
 }
 
 // getCmd represents the get command
@@ -51,6 +66,10 @@ var getCmd = &cobra.Command{
 			if len(fontResponse.Items) >= 1 {
 				donwloadFont(fontResponse)
 			}
+			// variants that failed were skipped, still fail the run
+			if errorCount.Load() > 0 {
+				exit(1)
+			}
 		}
 	},
 }
@@ -68,21 +87,21 @@ func validateDir() {
 	if Dir != "" {
 		absPath, err := filepath.Abs(Dir)
 		if err != nil {
-			fmt.Println("Error converting path to absolute:", err)
-			os.Exit(1)
+			printError("could not convert path to absolute: %v", err)
+			exit(1)
 		}
 
 		// Check if the directory exists
 		_, err = os.Stat(absPath)
 		if os.IsNotExist(err) {
-			fmt.Println("Error: The specified directory does not exist:", absPath)
-			os.Exit(1)
+			printError("the specified directory does not exist: %s", absPath)
+			exit(1)
 		}
 
 		// Check if the specified path is a directory
 		if fileInfo, err := os.Stat(absPath); err != nil || !fileInfo.IsDir() {
-			fmt.Println("Error: The specified path is not a directory:", absPath)
-			os.Exit(1)
+			printError("the specified path is not a directory: %s", absPath)
+			exit(1)
 		}
 
 		// Update the Dir variable with the absolute path
@@ -101,51 +120,91 @@ func parseFontFamily(fontFamily string) (parsedFontFamily string) {
 }
 
 func getFontUrl(fontFamily string) (fontResponse Font) {
+	return getFontMetadata(fontFamily, "&capability=WOFF2&capability=VF")
+}
+
+// getStaticFontUrl looks up a family's static files, even when it is a variable font
+func getStaticFontUrl(fontFamily string) (fontResponse Font) {
+	return getFontMetadata(fontFamily, "&capability=WOFF2")
+}
+
+func getFontMetadata(fontFamily, capabilities string) (fontResponse Font) {
+	if Offline {
+		return snapshotFontMetadata(fontFamily, capabilities)
+	}
+	return queryWebfonts("&family="+fontFamily+capabilities, "could not find specified font: "+fontFamily)
+}
+
+// queryWebfonts calls the Developer API with the given query parameters,
+// printing notFound when the API responds that nothing matched
+func queryWebfonts(query, notFound string) (fontResponse Font) {
+	fontResponse, err := fetchWebfonts(query)
+	if errors.Is(err, errFontNotFound) {
+		printError("%s", notFound)
+		exit(1)
+	}
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	return fontResponse
+}
+
+// fetchWebfonts calls the Developer API with the given query parameters,
+// returning errFontNotFound when the API responds that nothing matched
+func fetchWebfonts(query string) (fontResponse Font, err error) {
 	key := viper.Get("GFONTS_KEY")
 	if key == nil {
-		fmt.Println(`Error: required variable "GFONTS_KEY" not found. Get a key at: https://console.cloud.google.com/apis/credentials`)
-		os.Exit(1)
+		return fontResponse, fmt.Errorf(`required variable "GFONTS_KEY" not found. Get a key at: https://console.cloud.google.com/apis/credentials`)
 	}
 
-	url := "https://www.googleapis.com/webfonts/v1/webfonts?key=" + fmt.Sprint(key) + "&family=" + fontFamily + "&capability=WOFF2&capability=VF"
+	url := webfontsAPI + "?key=" + fmt.Sprint(key) + query
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fontResponse, err
+	}
+	// ask whether a response cached by an earlier run is still current
+	cached := readCachedMetadata(query)
+	if cached != nil {
+		cached.setConditional(req)
+	}
 	// Make the GET request
-	res, err := http.Get(url)
+	res, err := apiGet(req)
 	if err != nil {
-		fmt.Println("Error: failed to create connection to remote host", err)
-		os.Exit(1)
+		return fontResponse, fmt.Errorf("failed to create connection to remote host: %v", err)
 	}
 	defer res.Body.Close()
 
 	// check response and handle errors
-	if res.StatusCode == 200 {
+	switch res.StatusCode {
+	case 200:
 		// Read the response body
 		body, err := io.ReadAll(res.Body)
 		if err != nil {
-			fmt.Println("Error: Could not read response body", err)
-			os.Exit(1)
+			return fontResponse, fmt.Errorf("could not read response body: %v", err)
 		}
-
 		// parse the response body into the Font object struct
-		var fontResponse Font
-		err = json.Unmarshal(body, &fontResponse)
-		if err != nil {
-			fmt.Println("Error: could not parse json response", err)
-			os.Exit(1)
-		}
-		return fontResponse
-	} else if res.StatusCode == 400 {
-		fmt.Println("Error: invalid API Key")
-		os.Exit(1)
-		return
-	} else if res.StatusCode == 500 {
-		fmt.Println("Error: could not find specified font:", fontFamily)
-		os.Exit(1)
-		return
-	} else {
-		fmt.Println("An unexpected error occured")
-		os.Exit(1)
-		return
+		if err := json.Unmarshal(body, &fontResponse); err != nil {
+			return fontResponse, fmt.Errorf("could not parse json response: %v", err)
+		}
+		writeCachedMetadata(query, res, body)
+		return fontResponse, nil
+	case 304:
+		if cached == nil {
+			return fontResponse, fmt.Errorf("the API answered 304 Not Modified to a request that wasn't conditional")
+		}
+		if err := json.Unmarshal(cached.Body, &fontResponse); err != nil {
+			return fontResponse, fmt.Errorf("could not parse cached json response: %v", err)
+		}
+		return fontResponse, nil
+	case 400:
+		return fontResponse, fmt.Errorf("invalid API Key")
+	case 500:
+		return fontResponse, errFontNotFound
+	case 429:
+		return fontResponse, fmt.Errorf("rate limited by the Google Fonts API, try a lower --api-rate")
 	}
+	return fontResponse, fmt.Errorf("an unexpected error occured")
 }
 
 func donwloadFont(fontResponse Font) {
@@ -167,10 +226,19 @@ func donwloadFont(fontResponse Font) {
 	}
 
 	for variant, url := range fontFiles {
+		if err := checkFontURL(url); err != nil {
+			printWarning("%v, skipping %s", err, variant)
+			continue
+		}
 		// Make the GET request for each variant
-		res, err := http.Get(url)
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			printError("%v", err)
+			continue
+		}
+		res, err := downloadGet(req)
 		if err != nil {
-			fmt.Println(err)
+			printError("%v", err)
 			continue // Skip to the next variant if an error occurs
 		}
 		defer res.Body.Close()
@@ -185,7 +253,7 @@ func donwloadFont(fontResponse Font) {
 		fullPath := filePath + fileName
 		out, err := os.Create(fullPath)
 		if err != nil {
-			fmt.Println(err)
+			printError("%v", err)
 			continue // Skip to the next variant if an error occurs
 		}
 		defer out.Close()
@@ -193,10 +261,10 @@ func donwloadFont(fontResponse Font) {
 		// Write the downloaded file to the local file
 		_, err = io.Copy(out, res.Body)
 		if err != nil {
-			fmt.Println(err)
+			printError("%v", err)
 			continue // Skip to the next variant if an error occurs
 		}
-		fmt.Printf("%s successfully downloaded to %s\n", fileName, fullPath)
+		printSuccess("Downloaded", "%s to %s", fileName, fullPath)
 	}
 	fmt.Println("\nNext steps: Copy the following CSS rules wherever you would like to use your font!")
 	printCssConfig(fontResponse, hasVariable)
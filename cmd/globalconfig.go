@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// GlobalConfig holds the user-level defaults shared by every project, read
+// from $HERMES_GLOBAL_CONFIG or config.yaml in the user config directory,
+// e.g. ~/.config/hermes/config.yaml. A value is used only when nothing more
+// specific sets it: flags win over the project's fonts.yaml, which wins
+// over the global config, which wins over the built-in defaults. GFONTS_KEY
+// wins over api_key.
+// Example:
+//
+//	api_key: AIza...
+//	parallel_files: 8
+//	retries: 2
+//	defaults:
+//	  dir: ./public/fonts
+//	  cache_bust: true
+type GlobalConfig struct {
+	// APIKey is the Google Fonts API key, for when GFONTS_KEY isn't set
+	APIKey string `yaml:"api_key,omitempty"`
+	// ParallelFamilies, ParallelFiles and Retries are the defaults of the
+	// install flags of the same names
+	ParallelFamilies int `yaml:"parallel_families,omitempty"`
+	ParallelFiles    int `yaml:"parallel_files,omitempty"`
+	Retries          int `yaml:"retries,omitempty"`
+	// Defaults are fonts.yaml fields every project starts from. A field the
+	// project sets replaces the default's whole, as with presets, and
+	// relative paths resolve against the project's config file
+	Defaults yaml.Node `yaml:"defaults,omitempty"`
+}
+
+// globalConfig is the loaded global config, empty when there is none
+var globalConfig GlobalConfig
+
+// globalConfigPath is $HERMES_GLOBAL_CONFIG, or hermes/config.yaml in the
+// user config directory
+func globalConfigPath() string {
+	if path := viper.GetString("HERMES_GLOBAL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "hermes", "config.yaml")
+}
+
+// readGlobalConfig reads the global config at path. A missing file is an
+// empty config.
+func readGlobalConfig(path string) (GlobalConfig, error) {
+	var cfg GlobalConfig
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.ParallelFamilies < 0 || cfg.ParallelFiles < 0 || cfg.Retries < 0 {
+		return cfg, fmt.Errorf("`parallel_families`, `parallel_files` and `retries` cannot be negative")
+	}
+	if cfg.Defaults.Kind != 0 {
+		if cfg.Defaults.Kind != yaml.MappingNode {
+			return cfg, fmt.Errorf("`defaults` must be a mapping of fonts.yaml fields")
+		}
+		if mappingValue(&cfg.Defaults, "fonts") != nil {
+			return cfg, fmt.Errorf("`defaults` cannot list fonts, only the fields around them")
+		}
+	}
+	return cfg, nil
+}
+
+// applyGlobalDefaults adds the global defaults' fields that the config's
+// root mapping doesn't set
+func applyGlobalDefaults(doc *yaml.Node) {
+	if len(globalConfig.Defaults.Content) == 0 || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	root := doc.Content[0]
+	defaults := globalConfig.Defaults.Content
+	for i := 0; i+1 < len(defaults); i += 2 {
+		if mappingValue(root, defaults[i].Value) == nil {
+			root.Content = append(root.Content, defaults[i], defaults[i+1])
+		}
+	}
+}
+
+// loadGlobalConfig reads the global config and fills in the settings that
+// no flag or environment variable set
+func loadGlobalConfig() {
+	path := globalConfigPath()
+	cfg, err := readGlobalConfig(path)
+	if err != nil {
+		printError("invalid global config %s: %v", path, err)
+		exit(1)
+	}
+	globalConfig = cfg
+	if cfg.APIKey != "" {
+		viper.SetDefault("GFONTS_KEY", cfg.APIKey)
+	}
+	flags := installCmd.Flags()
+	if cfg.ParallelFamilies > 0 && !flags.Changed("parallel-families") {
+		ParallelFamilies = cfg.ParallelFamilies
+	}
+	if cfg.ParallelFiles > 0 && !flags.Changed("parallel-files") {
+		ParallelFiles = cfg.ParallelFiles
+	}
+	if cfg.Retries > 0 && !flags.Changed("retries") {
+		Retries = cfg.Retries
+	}
+}
+
+func init() {
+	cobra.OnInitialize(loadGlobalConfig)
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GoEmbedOptions configures the generated Go source file that embeds the
+// font files with //go:embed and the stylesheet as a string constant
+// Example:
+//
+//	go_embed:
+//	  path: "./assets/fonts.go"
+//	  package: "assets"
+type GoEmbedOptions struct {
+	// Path is the Go file to write. dir must be inside its directory, as
+	// //go:embed cannot reach outside the package.
+	Path string `yaml:"path"`
+	// Package defaults to the name of Path's directory
+	Package string `yaml:"package,omitempty"`
+	// CSSName is the name of the stylesheet constant, CSS by default
+	CSSName string `yaml:"css_name,omitempty"`
+	// VarPrefix starts the name of every font variable, Font by default,
+	// e.g. FontRoboto700italic for Roboto_700italic.woff2
+	VarPrefix string `yaml:"var_prefix,omitempty"`
+}
+
+func (o *GoEmbedOptions) validate() error {
+	if o.Path == "" {
+		return fmt.Errorf("go_embed: `path` not specified")
+	}
+	if o.Package != "" && !token.IsIdentifier(o.Package) {
+		return fmt.Errorf("go_embed: package %q is not a valid Go identifier", o.Package)
+	}
+	if o.CSSName != "" && !token.IsIdentifier(o.CSSName) {
+		return fmt.Errorf("go_embed: css_name %q is not a valid Go identifier", o.CSSName)
+	}
+	if o.VarPrefix != "" && !token.IsIdentifier(o.VarPrefix) {
+		return fmt.Errorf("go_embed: var_prefix %q is not a valid Go identifier", o.VarPrefix)
+	}
+	return nil
+}
+
+// writeGoEmbed writes a Go file declaring a []byte variable for each font
+// file in dir, filled by //go:embed, and the stylesheet as a constant
+func writeGoEmbed(o *GoEmbedOptions, dir, css string, files map[string]struct{}) error {
+	pkgDir := filepath.Dir(o.Path)
+	pkg := o.Package
+	if pkg == "" {
+		abs, err := filepath.Abs(pkgDir)
+		if err != nil {
+			return err
+		}
+		if pkg = filepath.Base(abs); !token.IsIdentifier(pkg) {
+			return fmt.Errorf("directory name %q is not a valid package name, set go_embed.package", pkg)
+		}
+	}
+	rel, err := relativeTo(pkgDir, dir)
+	if err != nil {
+		return err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("dir %s is outside the directory of %s, which //go:embed cannot reach", dir, o.Path)
+	}
+	cssName, prefix := o.CSSName, o.VarPrefix
+	if cssName == "" {
+		cssName = "CSS"
+	}
+	if prefix == "" {
+		prefix = "Font"
+	}
+
+	names := []string{}
+	for name := range files {
+		// sidecars are not needed when the bytes are embedded
+		if strings.HasSuffix(name, ".woff2") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("// Code generated by hermes install. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import _ \"embed\"\n\n")
+	fmt.Fprintf(&b, "// %s is the generated stylesheet\n", cssName)
+	fmt.Fprintf(&b, "const %s = %s\n", cssName, goStringLiteral(css))
+	seen := map[string]string{cssName: "the stylesheet"}
+	for _, name := range names {
+		ident := prefix + goIdentifier(strings.TrimSuffix(name, ".woff2"))
+		if other, ok := seen[ident]; ok {
+			return fmt.Errorf("%s and %s both map to the variable %s", name, other, ident)
+		}
+		seen[ident] = name
+		pattern := filepath.ToSlash(filepath.Join(rel, name))
+		if strings.ContainsAny(pattern, " \"`") {
+			pattern = strconv.Quote(pattern)
+		}
+		fmt.Fprintf(&b, "\n// %s is %s\n//\n//go:embed %s\nvar %s []byte\n", ident, name, pattern, ident)
+	}
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return err
+	}
+	return writeOutput(o.Path, src)
+}
+
+// goStringLiteral quotes s as a raw string when it can
+func goStringLiteral(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// goIdentifier turns a file name such as Open Sans_700italic into the
+// exported identifier part OpenSans700italic
+func goIdentifier(name string) string {
+	id := []rune(tsIdentifier(name))
+	if id[0] == '_' {
+		return string(id)
+	}
+	id[0] = unicode.ToUpper(id[0])
+	return string(id)
+}
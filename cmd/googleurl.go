@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// expandGoogleURLs replaces google_url entries with one entry per family in
+// the url, carrying over the entry's other options
+func expandGoogleURLs(fonts []FontEntry) ([]FontEntry, error) {
+	expanded := []FontEntry{}
+	for _, entry := range fonts {
+		if entry.GoogleURL == "" {
+			expanded = append(expanded, entry)
+			continue
+		}
+		if entry.Family != "" || len(entry.Variants) > 0 || entry.CSSURL != "" {
+			return nil, fmt.Errorf("font entry with `google_url` %s cannot also set family, variants or css_url", entry.GoogleURL)
+		}
+		families, err := parseGoogleURL(entry.GoogleURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid google_url %s: %v", entry.GoogleURL, err)
+		}
+		for _, f := range families {
+			e := entry
+			e.GoogleURL = ""
+			e.Family, e.Variants = f.family, f.variants
+			expanded = append(expanded, e)
+		}
+	}
+	return expanded, nil
+}
+
+type googleURLFamily struct {
+	family   string
+	variants []string
+}
+
+// parseGoogleURL reads the families and variants of a Google Fonts embed url,
+// in either the css2 form, family=Roboto:ital,wght@0,400;1,700, or the
+// older css form, family=Roboto:400,700italic
+func parseGoogleURL(rawURL string) ([]googleURLFamily, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	// url.Query drops parameters containing ;, which css2 uses between tuples
+	var specs []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		key, value, _ := strings.Cut(param, "=")
+		if key != "family" {
+			continue
+		}
+		spec, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no family parameter")
+	}
+	// the css API lists several families in one parameter, separated by |
+	if !strings.HasSuffix(u.Path, "/css2") {
+		specs = strings.Split(strings.Join(specs, "|"), "|")
+	}
+	families := []googleURLFamily{}
+	for _, spec := range specs {
+		name, axes, _ := strings.Cut(spec, ":")
+		var variants []string
+		if strings.HasSuffix(u.Path, "/css2") {
+			variants, err = parseCSS2Axes(axes)
+		} else {
+			// drop a trailing subset list, e.g. Roboto:400,700:latin
+			axes, _, _ = strings.Cut(axes, ":")
+			variants, err = parseCSSVariants(axes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		families = append(families, googleURLFamily{family: strings.TrimSpace(name), variants: variants})
+	}
+	return families, nil
+}
+
+// parseCSS2Axes turns a css2 axis spec such as ital,wght@0,400;1,700 into
+// variants. Only the ital and wght axes map to variants.
+func parseCSS2Axes(spec string) ([]string, error) {
+	if spec == "" {
+		return []string{"regular"}, nil
+	}
+	names, tuples, ok := strings.Cut(spec, "@")
+	if !ok {
+		return nil, fmt.Errorf("axis spec %q has no @ values", spec)
+	}
+	axes := strings.Split(names, ",")
+	for _, axis := range axes {
+		if axis != "ital" && axis != "wght" {
+			return nil, fmt.Errorf("axis %s is not supported, only ital and wght map to variants", axis)
+		}
+	}
+	variants := []string{}
+	for _, tuple := range strings.Split(tuples, ";") {
+		values := strings.Split(tuple, ",")
+		if len(values) != len(axes) {
+			return nil, fmt.Errorf("value %q does not match the axes %s", tuple, names)
+		}
+		italic, weight := false, "400"
+		for i, v := range values {
+			switch axes[i] {
+			case "ital":
+				if v != "0" && v != "1" {
+					return nil, fmt.Errorf("ital value %q must be 0 or 1", v)
+				}
+				italic = v == "1"
+			case "wght":
+				if strings.Contains(v, "..") {
+					return nil, fmt.Errorf("weight range %s is not supported, list the weights instead", v)
+				}
+				if _, err := strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("invalid weight %q", v)
+				}
+				weight = v
+			}
+		}
+		if variant := variantName(weight, italic); !slices.Contains(variants, variant) {
+			variants = append(variants, variant)
+		}
+	}
+	return variants, nil
+}
+
+// parseCSSVariants turns a css API variant list such as 400,700italic,400i into variants
+func parseCSSVariants(spec string) ([]string, error) {
+	if spec == "" {
+		return []string{"regular"}, nil
+	}
+	variants := []string{}
+	for _, v := range strings.Split(spec, ",") {
+		italic := false
+		switch {
+		case strings.HasSuffix(v, "italic"):
+			v, italic = strings.TrimSuffix(v, "italic"), true
+		case strings.HasSuffix(v, "i"):
+			v, italic = strings.TrimSuffix(v, "i"), true
+		}
+		switch v {
+		case "", "regular":
+			v = "400"
+		case "bold":
+			v = "700"
+		}
+		if _, err := strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid variant %q", v)
+		}
+		if variant := variantName(v, italic); !slices.Contains(variants, variant) {
+			variants = append(variants, variant)
+		}
+	}
+	return variants, nil
+}
+
+// variantName is the Developer API name of a weight and style, e.g. 700italic
+func variantName(weight string, italic bool) string {
+	switch {
+	case weight == "400" && italic:
+		return "italic"
+	case weight == "400":
+		return "regular"
+	case italic:
+		return weight + "italic"
+	}
+	return weight
+}
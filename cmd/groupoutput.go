@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// flag variables
+var GroupOutput bool
+
+// outputGroup holds the lines a family printed with --group-output until
+// the family is done, as its variants download concurrently with others
+type outputGroup struct {
+	mu    sync.Mutex
+	lines []groupedLine
+}
+
+type groupedLine struct {
+	f                   *os.File
+	color, prefix, text string
+}
+
+// outputGroups are the groups of the families being installed, by name
+var outputGroups = struct {
+	sync.Mutex
+	m map[string]*outputGroup
+}{m: map[string]*outputGroup{}}
+
+// familyGroup is the group of family, or nil without --group-output
+func familyGroup(family string) *outputGroup {
+	if !GroupOutput {
+		return nil
+	}
+	outputGroups.Lock()
+	defer outputGroups.Unlock()
+	g := outputGroups.m[family]
+	if g == nil {
+		g = &outputGroup{}
+		outputGroups.m[family] = g
+	}
+	return g
+}
+
+// print adds a line to the group, or prints it right away for a nil group
+func (g *outputGroup) print(f *os.File, color, prefix, format string, a ...any) {
+	if g == nil {
+		fprintStatus(f, color, prefix, format, a...)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lines = append(g.lines, groupedLine{f, color, prefix, fmt.Sprintf(format, a...)})
+}
+
+// flush prints the group's lines in the order they were added, as one
+// block
+func (g *outputGroup) flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	for _, l := range g.lines {
+		fmt.Fprintln(l.f, colorize(l.f, l.color, l.prefix), l.text)
+	}
+	g.lines = nil
+}
+
+// familyStatus is printStatus for a line about family, grouped with
+// --group-output
+func familyStatus(family, color, prefix, format string, a ...any) {
+	familyGroup(family).print(os.Stdout, color, prefix, format, a...)
+}
+
+// familyWarning is printWarning for a warning about family, grouped with
+// --group-output
+func familyWarning(family, format string, a ...any) {
+	if WarningsAsErrors {
+		errorCount.Add(1)
+		familyGroup(family).print(os.Stderr, colorRed, "Error:", format, a...)
+		return
+	}
+	familyGroup(family).print(os.Stdout, colorYellow, "Warning:", format, a...)
+}
+
+// flushFamilyOutput prints the block of lines family's install grouped,
+// once it is done
+func flushFamilyOutput(family string) {
+	outputGroups.Lock()
+	g := outputGroups.m[family]
+	delete(outputGroups.m, family)
+	outputGroups.Unlock()
+	if g != nil {
+		g.flush()
+	}
+}
+
+// flushAllOutput prints the lines of the families still installing, so
+// an exit keeps the context of its error
+func flushAllOutput() {
+	outputGroups.Lock()
+	groups := outputGroups.m
+	outputGroups.m = map[string]*outputGroup{}
+	outputGroups.Unlock()
+	for _, g := range groups {
+		g.flush()
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// flag variables
+var NoHeader bool
+var FontMetadata bool
+
+// defaultHeader is the banner written at the top of generated stylesheets.
+// --keep-timestamps keeps the old banner, and its time, when the rules are
+// unchanged, see keepHeader.
+const defaultHeader = "DO NOT EDIT - generated by Hermes {{.Version}} on {{.Time}}"
+
+// headerData is the data available to the header template
+type headerData struct {
+	Version string
+	Time    string
+}
+
+// renderHeader renders the header template as a CSS comment block
+func renderHeader(header string, now time.Time) (string, error) {
+	if header == "" {
+		header = defaultHeader
+	}
+	tmpl, err := template.New("header").Option("missingkey=error").Parse(header)
+	if err != nil {
+		return "", fmt.Errorf("invalid header template: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, headerData{Version: Version, Time: now.UTC().Format(time.RFC3339)}); err != nil {
+		return "", fmt.Errorf("invalid header template: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	for i, line := range lines {
+		// keep the text from closing the comment early
+		lines[i] = " * " + strings.ReplaceAll(line, "*/", "* /")
+	}
+	return "/*\n" + strings.Join(lines, "\n") + "\n */", nil
+}
+
+// stylesheetHeader is the rendered header of cfg's stylesheets, empty with
+// --no-header or no_header
+func stylesheetHeader(cfg *FontsYAML, now time.Time) (string, error) {
+	if NoHeader || cfg.NoHeader {
+		return "", nil
+	}
+	return renderHeader(cfg.Header, now)
+}
+
+// metadataComment renders a comment block listing each installed family
+// with its catalog revision, license and the hosts its files came from,
+// in config order, for --font-metadata
+func metadataComment(cfg *FontsYAML, lock *FontsLock) string {
+	lines := []string{}
+	for _, entry := range cfg.Fonts {
+		locked, ok := lock.Fonts[entry.name()]
+		if !ok {
+			continue
+		}
+		lines = append(lines, "  "+locked.Family)
+		switch {
+		case locked.Version != "" && locked.LastModified != "":
+			lines = append(lines, "    version: "+locked.Version+", updated "+locked.LastModified)
+		case locked.Version != "":
+			lines = append(lines, "    version: "+locked.Version)
+		}
+		if locked.License != "" {
+			lines = append(lines, "    license: "+licenseID(locked.License)+" ("+locked.License+")")
+		}
+		if hosts := lockedHosts(locked); len(hosts) > 0 {
+			lines = append(lines, "    source: "+strings.Join(hosts, ", "))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	for i, line := range lines {
+		lines[i] = " * " + strings.ReplaceAll(line, "*/", "* /")
+	}
+	return "/*\n * Fonts:\n" + strings.Join(lines, "\n") + "\n */"
+}
+
+// lockedHosts are the hosts that served the font's files, the mirror
+// instead of the provider when one did
+func lockedHosts(locked *LockedFont) []string {
+	seen := map[string]bool{}
+	hosts := []string{}
+	lockedSources(locked, func(v *LockedVariant, u *url.URL) {
+		if !seen[u.Host] {
+			seen[u.Host] = true
+			hosts = append(hosts, u.Host)
+		}
+	})
+	sort.Strings(hosts)
+	return hosts
+}
+
+// lockedSources calls fn with each of the font's files and the url that
+// served it, skipping urls without a host
+func lockedSources(locked *LockedFont, fn func(v *LockedVariant, u *url.URL)) {
+	for _, v := range locked.Variants {
+		for ; v != nil; v = v.Fallback {
+			src := v.URL
+			if v.Mirror != "" {
+				src = v.Mirror
+			}
+			if u, err := url.Parse(src); err == nil && u.Host != "" {
+				fn(v, u)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// hermesIgnoreFile lists, with gitignore patterns, the files in dir that
+// cleanup must leave alone, such as fonts placed there by hand
+const hermesIgnoreFile = ".hermesignore"
+
+// dirIgnore holds the patterns of a dir's .hermesignore
+type dirIgnore struct {
+	patterns *ignore.GitIgnore
+}
+
+// readHermesIgnore reads the .hermesignore in dir. A missing or unreadable
+// file ignores nothing, with a warning for the latter.
+func readHermesIgnore(dir string) dirIgnore {
+	path := filepath.Join(dir, hermesIgnoreFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return dirIgnore{}
+	}
+	patterns, err := ignore.CompileIgnoreFile(path)
+	if err != nil {
+		printWarning("could not read %s, no files are ignored: %v", path, err)
+		return dirIgnore{}
+	}
+	return dirIgnore{patterns: patterns}
+}
+
+// ignored reports whether f, relative to dir with forward slashes, matches
+// the .hermesignore. The file itself always does.
+func (ig dirIgnore) ignored(f string) bool {
+	if f == hermesIgnoreFile {
+		return true
+	}
+	return ig.patterns != nil && ig.patterns.MatchesPath(f)
+}
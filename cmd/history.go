@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// flag variables
+var RecordHistory bool
+
+// historyPath is the file recording changes go to: the config's history,
+// or fonts.history.md for fonts.yaml
+func historyPath(cfg *FontsYAML, configPath string) string {
+	if cfg.History != "" {
+		return cfg.History
+	}
+	return strings.TrimSuffix(configPath, filepath.Ext(configPath)) + ".history.md"
+}
+
+// recordsHistory reports whether the run appends its changes to the
+// history: every update does, an install with --record-history
+func recordsHistory() bool {
+	return (Refresh || RecordHistory) && !DryRun
+}
+
+// lockedSize is the size on disk of the files the lock records in dir,
+// each counted once
+func lockedSize(lock *FontsLock, dir string) int64 {
+	seen := map[string]bool{}
+	var size int64
+	for _, font := range lock.Fonts {
+		for _, f := range font.files() {
+			if seen[f] {
+				continue
+			}
+			seen[f] = true
+			if info, err := os.Stat(filepath.Join(dir, f)); err == nil {
+				size += info.Size()
+			}
+		}
+	}
+	return size
+}
+
+// lockedFontSet lists the variants of each locked family, as diff-config
+// does for a config
+func lockedFontSet(lock *FontsLock) map[string][]string {
+	set := map[string][]string{}
+	for _, font := range lock.Fonts {
+		for key := range font.Variants {
+			// css_url variants are keyed by variant and subset
+			variant, _, _ := strings.Cut(key, " ")
+			if !slices.Contains(set[font.Family], variant) {
+				set[font.Family] = append(set[font.Family], variant)
+			}
+		}
+	}
+	return set
+}
+
+// updatedVariants lists, by family, the variants both locks hold whose
+// files changed, e.g. for a new upstream release
+func updatedVariants(prev, next *FontsLock) map[string][]string {
+	updated := map[string][]string{}
+	for key, font := range next.Fonts {
+		old, ok := prev.Fonts[key]
+		if !ok {
+			continue
+		}
+		for variant, v := range font.Variants {
+			o, ok := old.Variants[variant]
+			if !ok || o.SHA256 == v.SHA256 && o.URL == v.URL {
+				continue
+			}
+			variant, _, _ = strings.Cut(variant, " ")
+			if !slices.Contains(updated[font.Family], variant) {
+				updated[font.Family] = append(updated[font.Family], variant)
+			}
+		}
+	}
+	return updated
+}
+
+// historyEntry renders the changes between two locks as a history entry,
+// or "" when the run changed nothing
+func historyEntry(now time.Time, prev, next *FontsLock, prevSize, nextSize int64) string {
+	diff := diffFontSets(lockedFontSet(prev), lockedFontSet(next))
+	updated := updatedVariants(prev, next)
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 && len(updated) == 0 {
+		return ""
+	}
+	lines := []string{"## " + now.UTC().Format("2006-01-02 15:04 MST"), ""}
+	for _, f := range diff.Added {
+		lines = append(lines, fmt.Sprintf("- Added %s (%s)", f.Family, strings.Join(f.Variants, ", ")))
+	}
+	for _, f := range diff.Removed {
+		lines = append(lines, fmt.Sprintf("- Removed %s (%s)", f.Family, strings.Join(f.Variants, ", ")))
+	}
+	for _, f := range diff.Changed {
+		changes := []string{}
+		for _, variant := range f.Added {
+			changes = append(changes, "+"+variant)
+		}
+		for _, variant := range f.Removed {
+			changes = append(changes, "-"+variant)
+		}
+		lines = append(lines, fmt.Sprintf("- Changed %s: %s", f.Family, strings.Join(changes, ", ")))
+	}
+	families := make([]string, 0, len(updated))
+	for family := range updated {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	for _, family := range families {
+		lines = append(lines, fmt.Sprintf("- Updated %s (%s)", family, strings.Join(sortedVariants(updated[family]), ", ")))
+	}
+	delta := "+" + formatBytes(nextSize-prevSize)
+	if nextSize < prevSize {
+		delta = "-" + formatBytes(prevSize-nextSize)
+	}
+	lines = append(lines, fmt.Sprintf("- Size: %s -> %s (%s)", formatBytes(prevSize), formatBytes(nextSize), delta))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// appendHistory appends entry to the history file at path, creating it
+// with a title. Earlier entries are never rewritten.
+func appendHistory(path, entry string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if info.Size() == 0 {
+		entry = "# Font history\n\n" + entry
+	} else {
+		entry = "\n" + entry
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
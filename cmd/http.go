@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var TLSMinVersion string
+var InsecureSkipVerify bool
+var ConnectTimeout time.Duration
+var ReadTimeout time.Duration
+
+// tlsVersions are the accepted --tls-min-version values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// httpLogLevel is the --verbose level at which HTTP round trips are logged
+const httpLogLevel = 2
+
+// httpClient is shared by every request hermes makes
+var httpClient = &http.Client{Transport: &loggingTransport{}}
+
+// sensitiveParams are query parameters redacted from logged URLs
+var sensitiveParams = []string{"key", "token", "signature"}
+
+// sensitiveHeaders are request headers redacted from logs
+var sensitiveHeaders = []string{"Authorization", "Cookie", "X-Goog-Api-Key"}
+
+// loggingTransport logs request and response details when running with -vv
+type loggingTransport struct {
+	// base defaults to http.DefaultTransport
+	base http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if Verbose < httpLogLevel {
+		return base.RoundTrip(req)
+	}
+	printDebug("%s %s", req.Method, redactURL(req.URL))
+	for _, h := range sensitiveHeaders {
+		if req.Header.Get(h) != "" {
+			printDebug("  %s: [REDACTED]", h)
+		}
+	}
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		printDebug("%s %s failed after %s: %v", req.Method, redactURL(req.URL), time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	printDebug("%s %s (%s)", res.Status, redactURL(req.URL), time.Since(start).Round(time.Millisecond))
+	for _, h := range []string{"Content-Type", "Content-Length", "ETag", "Last-Modified"} {
+		if v := res.Header.Get(h); v != "" {
+			printDebug("  %s: %s", h, v)
+		}
+	}
+	res.Body = &loggingBody{ReadCloser: res.Body, url: redactURL(req.URL), start: start}
+	return res, nil
+}
+
+// loggingBody reports how many bytes were read and how long the transfer took
+type loggingBody struct {
+	io.ReadCloser
+	url   string
+	start time.Time
+	n     int64
+}
+
+func (b *loggingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *loggingBody) Close() error {
+	printDebug("read %d bytes from %s in %s", b.n, b.url, time.Since(b.start).Round(time.Millisecond))
+	return b.ReadCloser.Close()
+}
+
+// redactURL hides credentials passed as query parameters
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	redacted := false
+	for _, p := range sensitiveParams {
+		if q.Has(p) {
+			q.Set(p, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+	c := *u
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+// redactError redacts the url that the errors of httpClient repeat, so
+// a signature or key in its query isn't printed
+func redactError(err error, u *url.URL) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL = redactURL(u)
+	}
+	return err
+}
+
+// printDebug writes HTTP diagnostics to stderr so they don't mix with regular output
+func printDebug(format string, a ...any) {
+	fmt.Fprintln(os.Stderr, colorize(os.Stderr, colorCyan, "debug:"), fmt.Sprintf(format, a...))
+}
+
+// readTimeoutKey holds a request-specific read timeout in its context,
+// such as a font entry's timeout
+type readTimeoutKey struct{}
+
+// withReadTimeout overrides --read-timeout for requests made with ctx
+func withReadTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, readTimeoutKey{}, timeout)
+}
+
+// readTimeoutTransport cancels a request once its response goes longer
+// than timeout without delivering any data. Unlike an overall deadline it
+// lets a slow but steady download run to completion.
+type readTimeoutTransport struct {
+	// base defaults to http.DefaultTransport
+	base http.RoundTripper
+	// timeout of 0 means no limit
+	timeout time.Duration
+}
+
+func (t *readTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	timeout := t.timeout
+	if d, ok := req.Context().Value(readTimeoutKey{}).(time.Duration); ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		return base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	body := &readTimeoutBody{timeout: timeout, cancel: cancel}
+	// the timer also covers the wait for the response headers
+	body.timer = time.AfterFunc(timeout, body.expire)
+	res, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		body.timer.Stop()
+		cancel()
+		if body.expired.Load() {
+			return nil, fmt.Errorf("no response within the read timeout of %s", timeout)
+		}
+		return nil, err
+	}
+	body.ReadCloser = res.Body
+	res.Body = body
+	return res, nil
+}
+
+// readTimeoutBody restarts its timer on every read that returns data
+type readTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+func (b *readTimeoutBody) expire() {
+	b.expired.Store(true)
+	b.cancel()
+}
+
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.expired.Load() {
+		return n, fmt.Errorf("no data received for the read timeout of %s", b.timeout)
+	}
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *readTimeoutBody) Close() error {
+	b.timer.Stop()
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// configureTransport applies the TLS and timeout flags to the shared
+// client. Without TLS flags the standard library's transport and its
+// strict defaults are used as is.
+func configureTransport() {
+	minVersion, ok := tlsVersions[TLSMinVersion]
+	if !ok {
+		printError("invalid --tls-min-version value %q (expected 1.2 or 1.3)", TLSMinVersion)
+		exit(1)
+	}
+	if ConnectTimeout < 0 || ReadTimeout < 0 {
+		printError("--connect-timeout and --read-timeout cannot be negative")
+		exit(1)
+	}
+	var base http.RoundTripper = http.DefaultTransport
+	if t, ok := http.DefaultTransport.(*http.Transport); ok && (minVersion != tls.VersionTLS12 || InsecureSkipVerify || ConnectTimeout > 0) {
+		t = t.Clone()
+		if minVersion != tls.VersionTLS12 || InsecureSkipVerify {
+			if t.TLSClientConfig == nil {
+				t.TLSClientConfig = &tls.Config{}
+			}
+			t.TLSClientConfig.MinVersion = minVersion
+			t.TLSClientConfig.InsecureSkipVerify = InsecureSkipVerify
+			// a custom TLS config disables HTTP/2 unless it is asked for explicitly
+			t.ForceAttemptHTTP2 = true
+		}
+		if ConnectTimeout > 0 {
+			dialer := &net.Dialer{Timeout: ConnectTimeout, KeepAlive: 30 * time.Second}
+			t.DialContext = dialer.DialContext
+			t.TLSHandshakeTimeout = ConnectTimeout
+		}
+		base = t
+	}
+	// installed even without --read-timeout, as font entries can set their own
+	base = &readTimeoutTransport{base: base, timeout: ReadTimeout}
+	httpClient.Transport = &loggingTransport{base: base}
+	if InsecureSkipVerify {
+		printStderrWarning("TLS certificate verification is disabled (--insecure-skip-verify). Only use this in development.")
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&TLSMinVersion, "tls-min-version", "1.2", "Minimum TLS version for HTTPS connections: 1.2 or 1.3")
+	rootCmd.PersistentFlags().BoolVar(&InsecureSkipVerify, "insecure-skip-verify", false, "DANGEROUS, development only: skip TLS certificate verification, allowing anyone on the network to tamper with downloads")
+	rootCmd.PersistentFlags().DurationVar(&ConnectTimeout, "connect-timeout", 10*time.Second, "How long to wait for a connection and TLS handshake before giving up on a host, 0 for no limit")
+	rootCmd.PersistentFlags().DurationVar(&ReadTimeout, "read-timeout", 30*time.Second, "How long a response may go without delivering any data before it is abandoned, 0 for no limit")
+	cobra.OnInitialize(configureTransport)
+}
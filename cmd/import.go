@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// flag variables
+var ImportOutput string
+
+var importCmd = &cobra.Command{
+	Use:   "import <dir>",
+	Short: "Generate a fonts.yaml skeleton from a directory of woff2 files",
+	Long: `Scans a directory of woff2 files and infers each file's family and variant
+from its name, the reverse of the default Family_variant.woff2 naming scheme.
+Source URLs cannot be recovered, so review the generated config before installing.
+Files whose names cannot be read unambiguously are reported as warnings.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			printError("could not read directory: %v", err)
+			exit(1)
+		}
+		names := []string{}
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(e.Name(), ".woff2") {
+				names = append(names, e.Name())
+			}
+		}
+		if len(names) == 0 {
+			printError("no woff2 files found in %s", dir)
+			exit(1)
+		}
+		// dir is resolved against the config file's directory on install
+		cfgDir := dir
+		if ImportOutput != "" {
+			if rel, err := relativeTo(filepath.Dir(ImportOutput), dir); err == nil {
+				cfgDir = rel
+			}
+		}
+		cfg := FontsYAML{Version: configVersion, Fonts: importFonts(names), Dir: cfgDir, Stylesheet: "./fonts.css"}
+		data, err := encodeConfig(cfg)
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if ImportOutput == "" {
+			fmt.Print(string(data))
+			return
+		}
+		if err := writeNewConfig(ImportOutput, data); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		printSuccess("Wrote", "%s with %d fonts", ImportOutput, len(cfg.Fonts))
+	},
+}
+
+// encodeConfig renders a generated config as YAML
+func encodeConfig(cfg FontsYAML) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeNewConfig writes a generated config to path, which must not exist
+func writeNewConfig(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists, not overwriting it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+var variantToken = regexp.MustCompile(`^(regular|italic|[1-9]00(italic)?)$`)
+
+// styleWeights maps the style names of foundry file names, e.g. Roboto-BoldItalic, to weights
+var styleWeights = map[string]string{
+	"thin": "100", "extralight": "200", "light": "300", "regular": "400", "medium": "500",
+	"semibold": "600", "bold": "700", "extrabold": "800", "black": "900",
+}
+
+// importFonts groups file names into font entries, warning about names it
+// can only guess at or cannot read
+func importFonts(names []string) []FontEntry {
+	byFamily := map[string]*FontEntry{}
+	for _, name := range names {
+		family, variant, kind, guessed := parseFontFileName(strings.TrimSuffix(name, ".woff2"))
+		if family == "" {
+			printStderrWarning("cannot infer a family and variant from %s, skipping it", name)
+			continue
+		}
+		if guessed {
+			printStderrWarning("guessed %s %s from %s, check the family name", family, variant, name)
+		}
+		entry, ok := byFamily[family]
+		if !ok {
+			entry = &FontEntry{Family: family}
+			byFamily[family] = entry
+		}
+		switch kind {
+		case "":
+		case kindStatic:
+			entry.VariableFallback = true
+		case kindText:
+			printStderrWarning("%s is a text subset. The text cannot be recovered, add it to %s by hand", name, family)
+		default:
+			if !slices.Contains(entry.Subsets, kind) {
+				entry.Subsets = append(entry.Subsets, kind)
+			}
+		}
+		if !slices.Contains(entry.Variants, variant) {
+			entry.Variants = append(entry.Variants, variant)
+		}
+	}
+	fonts := []FontEntry{}
+	for _, entry := range byFamily {
+		sort.Slice(entry.Variants, func(i, j int) bool { return variantLess(entry.Variants[i], entry.Variants[j]) })
+		sort.Strings(entry.Subsets)
+		fonts = append(fonts, *entry)
+	}
+	sort.Slice(fonts, func(i, j int) bool { return fonts[i].Family < fonts[j].Family })
+	return fonts
+}
+
+// parseFontFileName reads a file name written with the default naming,
+// Family_variant with an optional _kind suffix, falling back to guessing from
+// foundry names such as OpenSans-BoldItalic. It returns an empty family when
+// the name cannot be read.
+func parseFontFileName(name string) (family, variant, kind string, guessed bool) {
+	parts := strings.Split(name, "_")
+	if (len(parts) == 2 || len(parts) == 3) && parts[0] != "" && variantToken.MatchString(parts[1]) {
+		if len(parts) == 3 {
+			kind = parts[2]
+		}
+		return parts[0], parts[1], kind, false
+	}
+	base, style, ok := strings.Cut(name, "-")
+	if !ok || len(parts) > 1 {
+		return "", "", "", false
+	}
+	style = strings.ToLower(style)
+	italic := strings.HasSuffix(style, "italic")
+	style = strings.TrimSuffix(style, "italic")
+	if style == "" {
+		style = "regular"
+	}
+	weight, ok := styleWeights[style]
+	if !ok {
+		return "", "", "", false
+	}
+	return splitCamelCase(base), variantName(weight, italic), "", true
+}
+
+// splitCamelCase turns OpenSans into Open Sans
+func splitCamelCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && r >= 'A' && r <= 'Z' && s[i-1] >= 'a' && s[i-1] <= 'z' {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func relativeTo(base, path string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absBase, absPath)
+}
+
+// variantLess orders variants by weight, then upright before italic
+func variantLess(a, b string) bool {
+	as, aw := variantStyleWeight(a)
+	bs, bw := variantStyleWeight(b)
+	if aw != bw {
+		return aw < bw
+	}
+	return as == "normal" && bs != "normal"
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&ImportOutput, "output", "o", "", "Write the config to this path instead of stdout")
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var ImportURLsOutput string
+var ImportURLsSubsets []string
+
+var importURLsCmd = &cobra.Command{
+	Use:   "import-urls <file>",
+	Short: "Install the fonts of a list of Google Fonts css2 urls into one stylesheet and a fonts.yaml",
+	Long: `Reads a file of Google Fonts embed urls, one per line, such as
+https://fonts.googleapis.com/css2?family=Roboto:ital,wght@0,400;1,700, as
+copied from the pages being moved off hosted fonts. Blank lines and lines
+starting with # are skipped.
+
+The families and variants of every url are merged, so a family several pages
+embed is installed once, and written to a new config, fonts.yaml unless
+--output is set. The config is then installed, downloading every file into
+one localized stylesheet.
+
+Each url's families are reported, and so are the urls that can't be read.
+Urls that can't be expressed as variants, such as those with weight ranges,
+axes other than ital and wght, or text subsets, are written as css_url
+entries instead, installing the fonts their stylesheet lists. Other
+parameters of the urls read as variants, such as display, aren't carried
+over.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			printError("could not read %s: %v", args[0], err)
+			exit(1)
+		}
+		fonts := []FontEntry{}
+		byFamily := map[string]int{}
+		cssURLs := []FontEntry{}
+		parsed, failed := 0, 0
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			raw := strings.TrimSpace(scanner.Text())
+			if raw == "" || strings.HasPrefix(raw, "#") {
+				continue
+			}
+			if err := checkEmbedURL(raw); err != nil {
+				printWarning("line %d: could not read %s: %v", line, raw, err)
+				failed++
+				continue
+			}
+			parsed++
+			families, err := embedURLVariants(raw)
+			if err != nil {
+				if !slices.ContainsFunc(cssURLs, func(e FontEntry) bool { return e.CSSURL == raw }) {
+					cssURLs = append(cssURLs, FontEntry{CSSURL: raw})
+				}
+				printStatus(colorGreen, "Parsed", "line %d: %s as a css_url entry, %v", line, raw, err)
+				continue
+			}
+			names := make([]string, len(families))
+			for i, fam := range families {
+				names[i] = fmt.Sprintf("%s (%s)", fam.family, strings.Join(fam.variants, ", "))
+				key := parseFontFamily(fam.family)
+				j, ok := byFamily[key]
+				if !ok {
+					j = len(fonts)
+					byFamily[key] = j
+					fonts = append(fonts, FontEntry{Family: fam.family, Subsets: slices.Clone(ImportURLsSubsets)})
+				}
+				for _, v := range fam.variants {
+					if !slices.Contains(fonts[j].Variants, v) {
+						fonts[j].Variants = append(fonts[j].Variants, v)
+					}
+				}
+			}
+			printStatus(colorGreen, "Parsed", "line %d: %s", line, strings.Join(names, ", "))
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			printError("could not read %s: %v", args[0], err)
+			exit(1)
+		}
+		if parsed == 0 {
+			printError("no Google Fonts urls could be read from %s", args[0])
+			exit(1)
+		}
+		for i := range fonts {
+			sort.Slice(fonts[i].Variants, func(a, b int) bool { return variantLess(fonts[i].Variants[a], fonts[i].Variants[b]) })
+		}
+		sort.Slice(fonts, func(i, j int) bool { return fonts[i].Family < fonts[j].Family })
+		fonts = append(fonts, cssURLs...)
+		data, err := encodeConfig(FontsYAML{Version: configVersion, Fonts: fonts, Dir: "./fonts", Stylesheet: "./fonts.css"})
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if err := writeNewConfig(ImportURLsOutput, data); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		printSuccess("Wrote", "%s with %d fonts from %d urls", ImportURLsOutput, len(fonts), parsed)
+		if failed > 0 {
+			printWarning("%d urls could not be read, add their fonts to %s by hand", failed, ImportURLsOutput)
+		}
+		installCmd.Run(cmd, []string{ImportURLsOutput})
+	},
+}
+
+// checkEmbedURL refuses urls other than Google Fonts css and css2 embed urls
+func checkEmbedURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Host != "fonts.googleapis.com" || (u.Path != "/css" && u.Path != "/css2") {
+		return fmt.Errorf("not a Google Fonts css2 url")
+	}
+	return nil
+}
+
+// embedURLVariants reads the families and variants of an embed url,
+// refusing text subsets, which variants can't express
+func embedURLVariants(raw string) ([]googleURLFamily, error) {
+	if strings.Contains("&"+strings.SplitN(raw+"?", "?", 2)[1], "&text=") {
+		return nil, fmt.Errorf("text subsets are page-specific")
+	}
+	return parseGoogleURL(raw)
+}
+
+func init() {
+	rootCmd.AddCommand(importURLsCmd)
+
+	importURLsCmd.Flags().StringVarP(&ImportURLsOutput, "output", "o", defaultConfigFile, "Path of the config to write, which must not exist")
+	importURLsCmd.Flags().StringSliceVar(&ImportURLsSubsets, "subsets", nil, "Install each font as one file per subset, e.g. latin,latin-ext, like the urls' stylesheets, instead of whole files")
+}
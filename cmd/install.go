@@ -1,35 +1,54 @@
 package cmd
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
-// FontsYAML represents the schema of fonts.yaml
-// Example:
-// fonts:
-//   - family: "Roboto"
-//     variants: ["regular", "700italic"]
-//
-// dir: "./webfonts"
-// stylesheet: "./fonts.css"
-type FontsYAML struct {
-	Fonts      []FontEntry `yaml:"fonts"`
-	Dir        string      `yaml:"dir"`
-	Stylesheet string      `yaml:"stylesheet"`
-}
-
-type FontEntry struct {
-	Family   string   `yaml:"family"`
-	Variants []string `yaml:"variants"`
-}
+// flag variables
+var Force bool
+var OnlyFamily string
+var RelativeToCWD bool
+var OnDuplicate string
+var Strict bool
+var MaxShrink int
+var DeepVerify bool
+var MinFileSize string
+var Retries int
+var RetryJitter string
+var RetryMaxBackoff time.Duration
+var RetryBudget int
+var ParallelFamilies int
+var ParallelFiles int
+var MaxFamilies int
+var IfChanged bool
+var NoClean bool
+var DryRun bool
+var PrintCSS bool
+var PrintConfig bool
+var InstallJSON bool
+var CheckWritable bool
+var StylesheetFlag string
+var Watch bool
+var KeepTimestamps bool
 
 var installCmd = &cobra.Command{
 	Use:   "install",
@@ -37,153 +56,832 @@ var installCmd = &cobra.Command{
 	Long:  `Reads fonts.yaml and installs all specified fonts/variants, saving files and stylesheet as specified in the YAML.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		verbose := true // Always verbose for now
-		configPath := "fonts.yaml"
-		if len(args) > 0 {
-			configPath = args[0]
+		start := time.Now()
+		if PrintCSS && !DryRun {
+			printError("--print-css requires --dry-run")
+			exit(1)
 		}
-		if verbose {
-			fmt.Printf("Reading font configuration from %s...\n", configPath)
+		if InstallJSON && (Watch || Interactive || PrintCSS || PrintConfig) {
+			printError("--json cannot be combined with --watch, --interactive, --print-css or --print-config")
+			exit(1)
+		}
+		configPath := configFile(args)
+		if Interactive {
+			if Watch || DryRun {
+				printError("--interactive cannot be combined with --watch or --dry-run")
+				exit(1)
+			}
+			interactiveConfig(configPath)
 		}
-		cfg, err := readFontsYAML(configPath)
+		if Watch {
+			if DryRun || PrintConfig || StylesheetFlag == stdoutStylesheet {
+				printError("--watch cannot be combined with --dry-run, --print-config or a stylesheet on stdout")
+				exit(1)
+			}
+			watchInstall(configPath)
+			return
+		}
+		var in *installer
+		if ReportPath != "" || InstallJSON {
+			// a failed run is reported too, with what it got to
+			exitHook = func() {
+				var outcomes []variantOutcome
+				var emptyFamilies []string
+				if in != nil {
+					outcomes, emptyFamilies = in.outcomes, in.emptyFamilies
+				}
+				report := newInstallReport(configPath, start, outcomes, emptyFamilies, false)
+				report.OK = false
+				report.DryRun = DryRun
+				report.Errors = append(report.Errors, errorMessages()...)
+				finishReport(report)
+			}
+		}
+		cfg, err := loadFontsYAML(configPath)
 		if err != nil {
-			fmt.Printf("Error reading YAML: %v\n", err)
-			os.Exit(1)
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if StylesheetFlag != "" {
+			cfg.Stylesheet = StylesheetFlag
+		}
+		if OutputFormat != "" {
+			if err := applyOutputFormat(cfg, OutputFormat); err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+		}
+		toStdout := cfg.Stylesheet == stdoutStylesheet
+		if InstallJSON && toStdout {
+			printError("--json cannot be combined with a stylesheet on stdout")
+			exit(1)
+		}
+		if PrintCSS || PrintConfig || toStdout || InstallJSON {
+			// keep stdout for the stylesheet, config or report so it can be piped
+			os.Stdout = os.Stderr
 		}
 		if verbose {
+			fmt.Printf("Reading font configuration from %s...\n", configPath)
 			fmt.Printf("Installing fonts to directory: %s\n", cfg.Dir)
 		}
-		if cfg.Dir == "" {
-			fmt.Println("Error: `dir` not specified in YAML")
-			os.Exit(1)
-		}
-		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
-			fmt.Printf("Failed to create directory %s: %v\n", cfg.Dir, err)
-			os.Exit(1)
-		}
-		if cfg.Stylesheet == "" {
-			fmt.Println("Error: `stylesheet` not specified in YAML")
-			os.Exit(1)
-		}
-		if err := os.MkdirAll(filepath.Dir(cfg.Stylesheet), 0755); err != nil {
-			fmt.Printf("Failed to create directory %s: %v\n", cfg.Stylesheet, err)
-			os.Exit(1)
-		}
-		// Track all font files that should exist after install
-		wantedFiles := map[string]struct{}{}
-		cssRules := []string{}
-		if verbose && len(cfg.Fonts) == 0 {
-			fmt.Printf("No fonts specified in YAML\n")
-			os.Exit(1)
-		}
-		for _, entry := range cfg.Fonts {
-			parsedFamily := parseFontFamily(entry.Family)
-			fontResponse := getFontUrl(parsedFamily)
-			if len(fontResponse.Items) < 1 {
-				fmt.Printf("Warning: No font found for %s\n", entry.Family)
-				continue
-			}
-			item := fontResponse.Items[0]
-			files := item.Files
-			for _, variant := range entry.Variants {
-				url, ok := files[variant]
-				if !ok {
-					fmt.Printf("Variant %s not found for %s\n", variant, entry.Family)
-					fmt.Println("Available variants:", item.Variants)
-					os.Exit(1)
-				}
-				fileName := item.Family + "_" + variant + ".woff2"
-				filePath := filepath.Join(cfg.Dir, fileName)
-				if verbose {
-					fmt.Printf("Downloading %s (%s) -> %s\n", entry.Family, variant, filePath)
-				}
-				if err := downloadToFile(url, filePath); err != nil {
-					fmt.Printf("Failed to download %s: %v\n", fileName, err)
-					os.Exit(1)
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if ParallelFamilies < 1 || ParallelFiles < 1 {
+			printError("--parallel-families and --parallel-files must be at least 1")
+			exit(1)
+		}
+		if err := validateBackoff(); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		minSize, err := parseByteSize(MinFileSize)
+		if err != nil {
+			printError("invalid --min-file-size value %q: %v", MinFileSize, err)
+			exit(1)
+		}
+		if cfg.Fonts, err = mergeDuplicateFonts(cfg.Fonts, OnDuplicate); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		cfg.Fonts = applyOnlyVariants(cfg.Fonts, cfg.OnlyVariants)
+		if err := checkFileNameCollisions(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if err := validateTags(cfg.Fonts, Tags); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		var skipped []FontEntry
+		cfg.Fonts, skipped = selectTags(cfg.Fonts, Tags)
+		if OnlyFamily != "" {
+			if err := validateOnlyFamily(cfg.Fonts, OnlyFamily); err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+		}
+		// a guard against a config that accidentally pulls in a large part of the catalog
+		if MaxFamilies > 0 && OnlyFamily == "" && len(cfg.Fonts) > MaxFamilies {
+			printError("the config lists %d families, more than the --max-families cap of %d. Raise the cap, or pass --max-families 0 to remove it", len(cfg.Fonts), MaxFamilies)
+			exit(1)
+		}
+		if PrintConfig {
+			if err := writeResolvedConfig(stylesheetStdout, cfg); err != nil {
+				printError("could not encode config: %v", err)
+				exit(1)
+			}
+			return
+		}
+		if !DryRun {
+			if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+				printError("failed to create directory %s: %v", cfg.Dir, err)
+				exit(1)
+			}
+		}
+		if !DryRun && !toStdout {
+			if err := os.MkdirAll(filepath.Dir(cfg.Stylesheet), 0755); err != nil {
+				printError("failed to create directory %s: %v", cfg.Stylesheet, err)
+				exit(1)
+			}
+		}
+		if !DryRun && cfg.CriticalStylesheet != "" {
+			if err := os.MkdirAll(filepath.Dir(cfg.CriticalStylesheet), 0755); err != nil {
+				printError("failed to create directory %s: %v", cfg.CriticalStylesheet, err)
+				exit(1)
+			}
+		}
+		if cfg.StrictDir {
+			if err := checkStrictDir(cfg, configPath); err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+		}
+		// a dry run would otherwise only hit a permission problem on the real run
+		if DryRun || CheckWritable {
+			if err := checkOutputsWritable(cfg, configPath, toStdout); err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+		}
+		lockFile := lockPath(configPath)
+		lock, err := readLock(lockFile)
+		if err != nil {
+			printError("could not read lock file %s: %v", lockFile, err)
+			exit(1)
+		}
+		// measured before downloads replace the files
+		var prevSize int64
+		if recordsHistory() {
+			prevSize = lockedSize(lock, cfg.Dir)
+		}
+		hash, err := configHash(cfg)
+		if err != nil {
+			printError("could not hash config: %v", err)
+			exit(1)
+		}
+		// a stylesheet on stdout has to be written every time
+		if IfChanged && !toStdout && !Force && !Refresh && OnlyFamily == "" && len(Tags) == 0 && lock.ConfigHash == hash && lock.filesPresent(cfg.Dir) {
+			if _, err := os.Stat(cfg.Stylesheet); err == nil && (cfg.CriticalStylesheet == "" || fileExists(cfg.CriticalStylesheet)) {
+				fmt.Printf("%s is unchanged since the last install, nothing to do\n", configPath)
+				finishReport(newInstallReport(configPath, start, nil, nil, true))
+				return
+			}
+		}
+		in = newInstaller(cfg, lock, verbose)
+		in.minSize = minSize
+		in.budget = newRetryBudget(RetryBudget)
+		if in.signer, err = cfg.urlSigner(); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if Staged && !DryRun {
+			if in.stage, err = prepareStaging(cfg.Dir); err != nil {
+				printError("failed to create staging directory: %v", err)
+				exit(1)
+			}
+		}
+		in.newLock.ConfigHash = hash
+		if Refresh && UpdateSince != "" {
+			if in.catalogDates, err = catalogLastModified(); err != nil {
+				printError("could not look up catalog dates for --since: %v", err)
+				exit(1)
+			}
+		}
+		in.install(cfg.Fonts)
+		in.logDeduped()
+		// a family with nothing installed usually means its naming changed upstream
+		if len(in.emptyFamilies) > 0 {
+			msg := fmt.Sprintf("no variants were installed for %s", strings.Join(in.emptyFamilies, ", "))
+			if Strict {
+				printError("%s", msg)
+				exit(1)
+			}
+			printWarning("%s", msg)
+		}
+		// a provider outage can leave every family without rules
+		if len(in.cssRules) == 0 && len(in.criticalRules) == 0 && len(cfg.Fonts) > 0 {
+			switch cfg.OnEmpty {
+			case "error":
+				printError("no @font-face rules were generated, leaving the stylesheet and font files as they are")
+				exit(1)
+			case "", "keep":
+				if !toStdout {
+					printWarning("no @font-face rules were generated, keeping %s, its font files and the lock file as they are (set on_empty: write to empty it)", cfg.Stylesheet)
+					if in.stage != "" {
+						os.RemoveAll(in.stage)
+					}
+					return
 				}
-				wantedFiles[fileName] = struct{}{}
-				cssRules = append(cssRules, genCSS(item.Family, variant, fileName))
 			}
 		}
+		// Guard a good stylesheet against a flaky provider response, counting
+		// both stylesheets as rules may move between them
+		stylesheets := []string{}
+		if !toStdout {
+			stylesheets = append(stylesheets, cfg.Stylesheet)
+		}
+		if cfg.CriticalStylesheet != "" {
+			stylesheets = append(stylesheets, cfg.CriticalStylesheet)
+		}
+		if err := checkStylesheetShrink(cfg, stylesheets, append(in.criticalRules, in.cssRules...), MaxShrink); err != nil {
+			if Strict {
+				printError("%v", err)
+				exit(1)
+			}
+			printWarning("%v", err)
+		}
+		// every download succeeded, so the staged files can replace the old ones
+		if in.stage != "" {
+			if verbose {
+				fmt.Printf("Moving staged files into %s\n", cfg.Dir)
+			}
+			if err := commitStaging(in.stage, cfg.Dir); err != nil {
+				printError("failed to move staged files into %s: %v", cfg.Dir, err)
+				exit(1)
+			}
+		}
+		header, err := stylesheetHeader(cfg, time.Now())
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if FontMetadata {
+			if meta := metadataComment(cfg, in.newLock); meta != "" {
+				header = strings.TrimPrefix(header+"\n\n"+meta, "\n\n")
+			}
+		}
+		var origins []string
+		if cfg.ResourceHints {
+			origins = remoteOrigins(in.newLock.Fonts)
+			if hints := resourceHintsComment(origins); hints != "" {
+				header = strings.TrimPrefix(header+"\n\n"+hints, "\n\n")
+			}
+		}
+		rules, criticalRules := in.cssRules, in.criticalRules
+		if cfg.CSSVariables {
+			if vars := cssVariables(cfg, in.newLock); vars != "" && cfg.CriticalStylesheet != "" {
+				criticalRules = append([]string{vars}, criticalRules...)
+			} else if vars != "" {
+				rules = append([]string{vars}, rules...)
+			}
+		}
+		css := renderCSS(header, cfg.CSSLayer, rules)
+		if KeepTimestamps && !toStdout {
+			css = keepHeader(cfg, cfg.Stylesheet, css, renderCSS("", cfg.CSSLayer, rules))
+		}
 		// Remove any font files in dir not referenced in wantedFiles
-		removeUnreferencedFiles(cfg.Dir, wantedFiles, verbose)
+		switch {
+		case !cfg.clean():
+		case OnlyFamily != "":
+			// leave every other family's files as they are
+			removeFamilyFiles(cfg.Dir, lock, OnlyFamily, in.wantedFiles, verbose)
+		case len(skipped) > 0:
+			// leave the files of the fonts of other tags as they are
+			files, licenses := withSkipped(lock, skipped, in.wantedFiles, in.wantedLicenses)
+			removeUnreferencedFiles(cfg.Dir, files, convertedWOFFs(cfg, lock), verbose)
+			removeUnreferencedLicenses(cfg.Dir, licenses, verbose)
+			removeStaleCriticalStylesheet(cfg, lock.CriticalStylesheet, verbose)
+		default:
+			removeUnreferencedFiles(cfg.Dir, in.wantedFiles, convertedWOFFs(cfg, lock), verbose)
+			removeUnreferencedLicenses(cfg.Dir, in.wantedLicenses, verbose)
+			removeStaleCriticalStylesheet(cfg, lock.CriticalStylesheet, verbose)
+		}
+		if DryRun {
+			if err := writeTargets(cfg, header, append(criticalRules, rules...), in.wantedFiles, verbose); err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+			if cfg.Fontsource != "" {
+				printStatus(colorCyan, "Would write", "Fontsource packages to %s", cfg.Fontsource)
+			}
+			if PrintCSS {
+				fmt.Fprint(stylesheetStdout, css)
+			}
+			fmt.Println("\nDry run, nothing was written")
+			report := newInstallReport(configPath, start, in.outcomes, in.emptyFamilies, false)
+			report.DryRun = true
+			finishReport(report)
+			return
+		}
+		if cfg.Gitignore {
+			if err := writeGitignore(cfg.Dir, in.wantedFiles); err != nil {
+				printError("failed to write .gitignore: %v", err)
+				exit(1)
+			}
+		}
 		// Write CSS file
-		if verbose {
+		if verbose && toStdout {
+			fmt.Println("Writing CSS to stdout")
+		} else if verbose {
 			fmt.Printf("Writing CSS to %s\n", cfg.Stylesheet)
 		}
-		if err := writeCSS(cfg.Stylesheet, cssRules); err != nil {
-			fmt.Printf("Failed to write CSS: %v\n", err)
-			os.Exit(1)
+		if err := cfg.writeStylesheet(cfg.Stylesheet, css); err != nil {
+			printError("failed to write CSS: %v", err)
+			exit(1)
 		}
-		fmt.Println("\nInstall complete!")
+		if cfg.CriticalStylesheet != "" {
+			if verbose {
+				fmt.Printf("Writing critical CSS to %s\n", cfg.CriticalStylesheet)
+			}
+			critical := renderCSS(header, cfg.CSSLayer, criticalRules)
+			if KeepTimestamps {
+				critical = keepHeader(cfg, cfg.CriticalStylesheet, critical, renderCSS("", cfg.CSSLayer, criticalRules))
+			}
+			if err := cfg.writeStylesheet(cfg.CriticalStylesheet, critical); err != nil {
+				printError("failed to write critical CSS: %v", err)
+				exit(1)
+			}
+			in.newLock.CriticalStylesheet = cfg.CriticalStylesheet
+		}
+		if cfg.TSOutput != "" {
+			if verbose {
+				fmt.Printf("Writing TypeScript module to %s\n", cfg.TSOutput)
+			}
+			if err := writeTSModule(cfg, in.newLock.Fonts); err != nil {
+				printError("failed to write TypeScript module: %v", err)
+				exit(1)
+			}
+		}
+		if cfg.Manifest != "" {
+			if verbose {
+				fmt.Printf("Writing manifest to %s\n", cfg.Manifest)
+			}
+			if err := writeManifest(cfg.Manifest, in.newLock.Fonts, cfg.ManifestFormat, origins); err != nil {
+				printError("failed to write manifest: %v", err)
+				exit(1)
+			}
+		}
+		if cfg.GoEmbed != nil {
+			if verbose {
+				fmt.Printf("Writing Go embed file to %s\n", cfg.GoEmbed.Path)
+			}
+			if err := writeGoEmbed(cfg.GoEmbed, cfg.Dir, css, in.wantedFiles); err != nil {
+				printError("failed to write Go embed file: %v", err)
+				exit(1)
+			}
+		}
+		if cfg.ServerConfig != nil {
+			if verbose {
+				fmt.Printf("Writing %s config to %s\n", cfg.ServerConfig.Server, cfg.ServerConfig.Path)
+			}
+			if err := writeServerConfig(cfg, in.wantedFiles); err != nil {
+				printError("failed to write server config: %v", err)
+				exit(1)
+			}
+		}
+		if err := writeTargets(cfg, header, append(criticalRules, rules...), in.wantedFiles, verbose); err != nil {
+			printError("failed to write targets: %v", err)
+			exit(1)
+		}
+		if cfg.Fontsource != "" {
+			if err := writeFontsource(cfg, cfg.Fontsource, append(criticalRules, rules...), in.newLock.Fonts, verbose); err != nil {
+				printError("failed to write Fontsource packages: %v", err)
+				exit(1)
+			}
+		}
+		carrySkipped(lock, in.newLock, skipped)
+		in.newLock.CatalogRevision = catalogRevision(in.newLock.Fonts)
+		if err := writeLock(lockFile, in.newLock); err != nil {
+			printError("failed to write lock file %s: %v", lockFile, err)
+			exit(1)
+		}
+		in.checkCatalogDrift()
+		if recordsHistory() {
+			if entry := historyEntry(time.Now(), lock, in.newLock, prevSize, lockedSize(in.newLock, cfg.Dir)); entry != "" {
+				path := historyPath(cfg, configPath)
+				if verbose {
+					fmt.Printf("Recording the font changes in %s\n", path)
+				}
+				if err := appendHistory(path, entry); err != nil {
+					printError("failed to write history %s: %v", path, err)
+					exit(1)
+				}
+			}
+		}
+		finishReport(newInstallReport(configPath, start, in.outcomes, in.emptyFamilies, false))
+		// the table is meant for people, so it is left out of piped output
+		if Summary && isTerminal(os.Stdout) {
+			fmt.Println()
+			if OnlyFamily != "" {
+				printSummary(familyOutcomes(in.outcomes, OnlyFamily), in.emptyFamilies)
+			} else {
+				printSummary(in.outcomes, in.emptyFamilies)
+			}
+		}
+		if OnlyFamily != "" {
+			fmt.Printf("\nOnly %s was refreshed, the other fonts were left as they were\n", OnlyFamily)
+		}
+		if failed() {
+			fmt.Println("\n" + colorize(os.Stdout, colorRed, "Install failed: warnings are errors with --warnings-as-errors"))
+			exit(1)
+		}
+		fmt.Println("\n" + colorize(os.Stdout, colorGreen, "Install complete!"))
 	},
 }
 
-func readFontsYAML(path string) (*FontsYAML, error) {
-	f, err := os.Open(path)
+// checkFontURL rejects font urls that are not plain http(s) downloads, such
+// as malformed or file:// urls from a misbehaving provider
+func checkFontURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid font URL %q", rawURL)
+	}
+	return nil
+}
+
+// downloadOptions tune a download, e.g. for one font entry
+type downloadOptions struct {
+	// contentTypes are the accepted Content-Types; none accepts any
+	contentTypes []string
+	// timeout overrides --read-timeout when set
+	timeout time.Duration
+	// retries is how often a download failing with a network error or a
+	// server error is retried
+	retries int
+	// signer, when set, signs the url of every attempt, so a retry gets a
+	// fresh expiry
+	signer URLSigner
+	// budget, when set, is spent by every retry of the run
+	budget *retryBudget
+	// integrity, when set, receives the file's subresource integrity
+	// value, hashed with its SHA-256 as it is written, for sri
+	integrity *string
+	// verbose reports each retry and mirror tried. A download that
+	// succeeds after them isn't a problem, so they aren't warnings.
+	verbose bool
+}
+
+// partialDownload is what a failed attempt, or an earlier run, left in the
+// temp file, for the next attempt to resume from
+type partialDownload struct {
+	// resumable is set when the server accepts byte ranges and sent the
+	// file as is, so its bytes can be continued with a Range request
+	resumable bool
+	// validator is the strong ETag or the Last-Modified the bytes came
+	// with, sent as If-Range so a changed file is sent whole
+	validator string
+	// sum hashes the bytes in the temp file as they are written. It is nil
+	// when they are not known to match, such as after a failed write or for
+	// a temp file left by an earlier run, which is then hashed from disk.
+	sum hash.Hash
+	// sri hashes them for the integrity value, alongside sum
+	sri hash.Hash
+}
+
+// partialValidatorPath is the file beside a kept temp file recording the
+// url and validator of its bytes
+func partialValidatorPath(tmp string) string {
+	return tmp + ".validator"
+}
+
+// loadPartial resumes the temp file an earlier run left for url. Bytes
+// without a recorded validator, or downloaded from another url, could be
+// spliced with a different file, so they are discarded.
+func loadPartial(url, tmp string) partialDownload {
+	data, err := os.ReadFile(partialValidatorPath(tmp))
+	if err == nil {
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) == 2 && lines[0] == url && lines[1] != "" && fileExists(tmp) {
+			return partialDownload{resumable: true, validator: lines[1]}
+		}
+	}
+	os.Remove(tmp)
+	os.Remove(partialValidatorPath(tmp))
+	return partialDownload{}
+}
+
+// save records the validator of the bytes being downloaded from url, so a
+// later run can resume them when this one is interrupted
+func (p partialDownload) save(url, tmp string) {
+	if err := os.WriteFile(partialValidatorPath(tmp), []byte(url+"\n"+p.validator+"\n"), 0644); err != nil {
+		printWarning("failed to record the validator of %s: %v", tmp, err)
+	}
+}
+
+// downloadToFile downloads url into a temp file beside filePath and renames
+// it into place once complete. A retried attempt resumes from the bytes
+// already downloaded when the server supports ranges and validates them
+// with If-Range, and otherwise starts over. The temp file of a failed
+// download is kept with its validator, for the next run to resume. The
+// file's SHA-256 is computed while it is written and returned.
+func downloadToFile(url, filePath string, opts downloadOptions) (string, error) {
+	tmp := filepath.Join(filepath.Dir(filePath), "."+filepath.Base(filePath)+".part")
+	partial := loadPartial(url, tmp)
+	var err error
+	retried := 0
+	for attempt := 0; attempt <= opts.retries; attempt++ {
+		if attempt > 0 {
+			if !opts.budget.take() {
+				err = fmt.Errorf("%w (retry budget exhausted)", err)
+				break
+			}
+			delay := backoff(attempt - 1)
+			if opts.verbose {
+				printStatus(colorYellow, "Retrying", "download of %s failed (%v), retrying in %s", url, err, delay)
+			}
+			time.Sleep(delay)
+			retried++
+		}
+		var retry bool
+		if retry, err = downloadOnce(url, tmp, opts, &partial); err == nil || !retry {
+			break
+		}
+	}
+	if err != nil && retried > 0 {
+		err = fmt.Errorf("%w (after %d retries)", err, retried)
+	}
 	if err != nil {
-		return nil, err
+		if !partial.resumable {
+			os.Remove(tmp)
+			os.Remove(partialValidatorPath(tmp))
+		}
+		return "", err
 	}
-	defer f.Close()
-	var cfg FontsYAML
-	dec := yaml.NewDecoder(f)
-	if err := dec.Decode(&cfg); err != nil {
-		return nil, err
+	os.Remove(partialValidatorPath(tmp))
+	sum := hex.EncodeToString(partial.sum.Sum(nil))
+	if opts.integrity != nil {
+		*opts.integrity = sriValue(partial.sri)
 	}
-	return &cfg, nil
+	if KeepTimestamps {
+		if existing, err := fileSHA256(filePath); err == nil && existing == sum {
+			return sum, os.Remove(tmp)
+		}
+	}
+	return sum, os.Rename(tmp, filePath)
 }
 
-func downloadToFile(url, filePath string) error {
-	resp, err := http.Get(url)
+// downloadOnce makes one attempt at downloadToFile, reporting whether a
+// failure is worth retrying
+func downloadOnce(url, filePath string, opts downloadOptions, partial *partialDownload) (bool, error) {
+	if err := checkFontURL(url); err != nil {
+		return false, err
+	}
+	if opts.signer != nil {
+		signed, err := opts.signer.SignURL(url)
+		if err != nil {
+			return false, fmt.Errorf("could not sign %s: %w", url, err)
+		}
+		url = signed
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if opts.timeout > 0 {
+		req = req.WithContext(withReadTimeout(req.Context(), opts.timeout))
+	}
+	// ask for the bytes as is; woff2 is already compressed
+	req.Header.Set("Accept-Encoding", "identity")
+	var offset int64
+	if info, err := os.Stat(filePath); err == nil && partial.resumable && info.Size() > 0 {
+		offset = info.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", partial.validator)
+	}
+	resp, err := downloadGet(req)
 	if err != nil {
-		return err
+		return true, redactError(err, req.URL)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	resumed := resp.StatusCode == http.StatusPartialContent && offset > 0
+	if resp.StatusCode != 200 && !resumed {
+		return resp.StatusCode >= 500, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	if resumed && !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+		// not the range asked for, so start over on the next attempt
+		partial.resumable = false
+		return true, fmt.Errorf("server sent range %q, expected one from byte %d", resp.Header.Get("Content-Range"), offset)
+	}
+	if resumed {
+		printStatus(colorCyan, "Resuming", "download of %s at %s", redactURL(req.URL), formatBytes(offset))
+	} else {
+		enc := strings.ToLower(resp.Header.Get("Content-Encoding"))
+		// a weak ETag can't be sent as If-Range, and without a validator a
+		// changed file would be spliced onto the old bytes
+		partial.validator = resp.Header.Get("ETag")
+		if partial.validator == "" || strings.HasPrefix(partial.validator, "W/") {
+			partial.validator = resp.Header.Get("Last-Modified")
+		}
+		partial.resumable = resp.Header.Get("Accept-Ranges") == "bytes" && (enc == "" || enc == "identity") && partial.validator != ""
+		if partial.resumable {
+			partial.save(url, filePath)
+		} else {
+			os.Remove(partialValidatorPath(filePath))
+		}
+	}
+	if err := checkContentType(resp, opts.contentTypes); err != nil {
+		if Strict {
+			return false, err
+		}
+		printWarning("%v", err)
+	}
+	body, err := decodedBody(resp)
+	if err != nil {
+		return false, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resumed {
+		flags = os.O_WRONLY | os.O_APPEND
+		if partial.sum == nil {
+			if partial.sum, partial.sri, err = hashPartial(filePath); err != nil {
+				return false, err
+			}
+		}
+	} else {
+		partial.sum, partial.sri = sha256.New(), sha512.New384()
+	}
+	out, err := os.OpenFile(filePath, flags, 0644)
+	if err != nil {
+		return false, err
+	}
+	var sums io.Writer = partial.sum
+	if opts.integrity != nil {
+		sums = io.MultiWriter(partial.sum, partial.sri)
+	}
+	_, err = io.Copy(out, io.TeeReader(body, sums))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
 	}
-	out, err := os.Create(filePath)
 	if err != nil {
-		return err
+		// the hash may have taken bytes the file didn't
+		partial.sum = nil
 	}
-	defer out.Close()
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return true, err
 }
 
-func removeUnreferencedFiles(dir string, wanted map[string]struct{}, verbose bool) {
-	d, err := os.Open(dir)
+// defaultFontContentTypes are the Content-Types font servers are expected
+// to send, unless the config sets content_types: the type of every format
+// hermes downloads, as menu and format_order files aren't all woff2, and
+// the types older servers send instead
+var defaultFontContentTypes = func() []string {
+	types := []string{}
+	for _, format := range fontMIMETypes {
+		types = append(types, format.mime)
+	}
+	return append(types, "application/font-woff2", "application/font-woff", "application/x-font-ttf", "application/x-font-otf", "application/octet-stream")
+}()
+
+// checkContentType reports a response whose Content-Type is not one of
+// types, such as the HTML error page of a misbehaving mirror. A response
+// without a Content-Type, or an empty types, is not checked.
+func checkContentType(resp *http.Response, types []string) error {
+	header := resp.Header.Get("Content-Type")
+	if header == "" || len(types) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
 	if err != nil {
-		fmt.Printf("Failed to open directory for cleanup: %v\n", err)
-		return
+		mediaType = header
+	}
+	for _, t := range types {
+		if strings.EqualFold(mediaType, t) {
+			return nil
+		}
 	}
-	defer d.Close()
-	files, err := d.Readdirnames(-1)
+	return fmt.Errorf("%s was served as %s rather than a font (expected %s); if the server is right, add its type to content_types", redactURL(resp.Request.URL), mediaType, strings.Join(types, ", "))
+}
+
+// decodedBody undoes a Content-Encoding that misconfigured servers apply
+// to font files even when asked for identity, which would otherwise be
+// written to disk still compressed
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	switch enc := strings.ToLower(resp.Header.Get("Content-Encoding")); enc {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return zlib.NewReader(resp.Body)
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", enc)
+	}
+}
+
+// removeUnreferencedFiles removes the unreferencedFiles of dir, and the
+// subdirectories they leave empty; other directories are left alone
+func removeUnreferencedFiles(dir string, wanted, converted map[string]struct{}, verbose bool) {
+	files, err := unreferencedFiles(dir, wanted, converted)
 	if err != nil {
-		fmt.Printf("Failed to list directory: %v\n", err)
+		printWarning("failed to list directory for cleanup: %v", err)
 		return
 	}
-	for _, f := range files {
-		if !strings.HasSuffix(f, ".woff2") {
+	dirs := map[string]struct{}{}
+	for _, path := range files {
+		if DryRun {
+			printStatus(colorCyan, "Would remove", "unreferenced font file: %s", path)
 			continue
 		}
-		if _, ok := wanted[f]; !ok {
-			fullPath := filepath.Join(dir, f)
-			if verbose {
-				fmt.Printf("Removing unreferenced font file: %s\n", fullPath)
+		if verbose {
+			printStatus(colorYellow, "Removing", "unreferenced font file: %s", path)
+		}
+		os.Remove(path)
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	for sub := range dirs {
+		// os.Remove fails, leaving the directory, unless it is empty
+		for sub != dir && os.Remove(sub) == nil {
+			sub = filepath.Dir(sub)
+		}
+	}
+}
+
+// unreferencedFiles lists the font files and sidecars in dir, and in the
+// subdirectories of the hashed layout and split_by_format, that aren't in
+// wanted, along with the converted woffs, see convertedWOFFs. Other
+// files, and those matching the .hermesignore, are never listed.
+func unreferencedFiles(dir string, wanted, converted map[string]struct{}) ([]string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// nothing installed yet, as on a first --dry-run
+		return nil, nil
+	}
+	ig := readHermesIgnore(dir)
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		f := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if isFormatDir(f) || (strings.Count(f, "/") < 2 && hashedDirName.MatchString(d.Name())) {
+				return nil
 			}
-			os.Remove(fullPath)
+			return filepath.SkipDir
+		}
+		_, isConverted := converted[f]
+		if _, ok := wanted[f]; !ok && (isManagedFile(d.Name()) || isConverted) && !ig.ignored(f) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// checkStylesheetShrink returns an error when writing rules would drop more
+// than maxShrink percent of the @font-face rules in the existing stylesheet
+func checkStylesheetShrink(cfg *FontsYAML, paths []string, rules []string, maxShrink int) error {
+	oldRules := 0
+	for _, path := range paths {
+		if css, err := cfg.readStylesheet(path); err == nil {
+			oldRules += strings.Count(css, "@font-face")
 		}
 	}
+	newRules := strings.Count(strings.Join(rules, "\n"), "@font-face")
+	if oldRules == 0 || newRules >= oldRules {
+		return nil
+	}
+	if drop := (oldRules - newRules) * 100 / oldRules; drop > maxShrink {
+		return fmt.Errorf("stylesheet %s would shrink from %d to %d @font-face rules (%d%%)", strings.Join(paths, " and "), oldRules, newRules, drop)
+	}
+	return nil
+}
+
+// keepHeader returns the stylesheet at path as it is, without its @charset
+// rule, when its rules are body, so a new header time alone doesn't rewrite
+// it, and otherwise css
+func keepHeader(cfg *FontsYAML, path, css, body string) string {
+	data, err := cfg.readStylesheet(path)
+	if err != nil {
+		return css
+	}
+	existing := stripCharset(data)
+	if existing == body || (strings.HasPrefix(existing, "/*") && strings.HasSuffix(existing, "\n\n"+body)) {
+		return existing
+	}
+	return css
 }
 
-func writeCSS(path string, rules []string) error {
+// renderCSS joins the rules into the stylesheet, wrapped in @layer when
+// layer is set and below header when it is not empty
+func renderCSS(header, layer string, rules []string) string {
 	css := strings.Join(rules, "\n\n")
-	return os.WriteFile(path, []byte(css), 0644)
+	if layer != "" {
+		lines := strings.Split(css, "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = "  " + line
+			}
+		}
+		css = "@layer " + layer + " {\n" + strings.Join(lines, "\n") + "\n}"
+	}
+	if header != "" {
+		css = header + "\n\n" + css
+	}
+	return css
 }
 
-func genCSS(family, variant, fileName string) string {
-	style := "normal"
-	weight := "400"
+// variantStyleWeight splits a Google Fonts variant token such as "700italic"
+// into its CSS font-style and font-weight
+func variantStyleWeight(variant string) (style, weight string) {
+	style = "normal"
+	weight = "400"
 	if variant == "italic" {
 		style = "italic"
 	} else if strings.HasSuffix(variant, "italic") {
@@ -192,14 +890,129 @@ func genCSS(family, variant, fileName string) string {
 	} else if variant != "regular" {
 		weight = variant
 	}
+	return style, weight
+}
+
+// fontSrc is one entry of an @font-face src list
+type fontSrc struct {
+	// Local names an installed font to use instead of URL, see local_postscript
+	Local  string
+	URL    string
+	Format string
+	// Tech lists optional tech() hints, e.g. "variations"
+	Tech []string
+	// ext is the file's extension, which format_order sorts by
+	ext string
+}
+
+// writeGitignore lists the generated files in dir/.gitignore so they can be
+// kept out of version control and regenerated with hermes install
+func writeGitignore(dir string, wanted map[string]struct{}) error {
+	files := make([]string, 0, len(wanted))
+	for f := range wanted {
+		// escape characters gitignore would treat as syntax
+		if strings.HasPrefix(f, "#") || strings.HasPrefix(f, "!") {
+			f = `\` + f
+		}
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	content := "# Generated by hermes install, do not edit\n" + strings.Join(files, "\n") + "\n"
+	return writeOutput(filepath.Join(dir, ".gitignore"), []byte(content))
+}
+
+// supportsVariations nests a rule in an @supports block so only browsers with
+// variable font support use it. Being later in the stylesheet, it overrides
+// the static @font-face rule with the same descriptors.
+func supportsVariations(rule string) string {
+	return "@supports (font-variation-settings: normal) {\n" + indentCSS(rule) + "\n}"
+}
+
+// indentCSS indents every non-empty line of css by two spaces
+func indentCSS(css string) string {
+	lines := strings.Split(css, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func genCSS(family, variant string, srcs []fontSrc) string {
+	style, weight := variantStyleWeight(variant)
+	src := make([]string, len(srcs))
+	for i, s := range srcs {
+		if s.Local != "" {
+			src[i] = fmt.Sprintf("local('%s')", s.Local)
+			continue
+		}
+		src[i] = fmt.Sprintf("url('%s') format('%s')", s.URL, s.Format)
+		if len(s.Tech) > 0 {
+			src[i] += " tech(" + strings.Join(s.Tech, ", ") + ")"
+		}
+	}
 	return fmt.Sprintf(`@font-face {
   font-family: '%s';
   font-style: %s;
   font-weight: %s;
-  src: url('%s') format('woff2');
-}`, family, style, weight, fileName)
+  src: %s;
+}`, family, style, weight, strings.Join(src, ", "))
+}
+
+// genSubsetCSS is genCSS for a file holding one subset of the font
+func genSubsetCSS(family, variant string, srcs []fontSrc, unicodeRange string) string {
+	return addDescriptor(genCSS(family, variant, srcs), "unicode-range", unicodeRange)
+}
+
+// addDescriptor adds a descriptor to an @font-face rule, unless value is empty
+func addDescriptor(rule, descriptor, value string) string {
+	if value == "" {
+		return rule
+	}
+	return strings.TrimSuffix(rule, "}") + "  " + descriptor + ": " + value + ";\n}"
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
+
+	installCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	installCmd.Flags().BoolVarP(&Force, "force", "f", false, "Re-download every variant, ignoring the lock file")
+	installCmd.Flags().StringSliceVar(&Tags, "tags", nil, "Install only the fonts tagged with one of these tags, e.g. web,email, and the untagged ones, leaving the other fonts' files alone")
+	installCmd.Flags().StringVar(&OnlyFamily, "only-family", "", "Re-download just this family, ignoring its lock entry, and leave the other fonts' files alone")
+	installCmd.Flags().StringVar(&OnDuplicate, "on-duplicate", "merge", "How to handle a font listed more than once: merge its variants or error")
+	installCmd.Flags().BoolVar(&Strict, "strict", false, "Treat safety warnings, such as a shrinking stylesheet, a family with no installed variants or a font served with an unexpected Content-Type, as errors")
+	installCmd.Flags().IntVar(&MaxShrink, "max-shrink", 50, "Warn when the stylesheet would lose more than this percentage of its rules")
+	installCmd.Flags().BoolVar(&Summary, "summary", false, "Print a table of every variant's status and size when run in a terminal")
+	installCmd.Flags().BoolVar(&RecordHistory, "record-history", false, "Append the families and variants added, removed and updated and the size change to the history file, as update always does")
+	installCmd.Flags().StringVar(&ReportPath, "report", "", "Write a JSON report of every variant's status, size and duration to this path")
+	installCmd.Flags().BoolVar(&InstallJSON, "json", false, "Print the report of --report on stdout, described by hermes schema --result install, moving progress to stderr")
+	installCmd.Flags().BoolVar(&Staged, "staged", false, "Download into a staging directory beside dir and move the files into dir only once every download succeeded")
+	installCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Resolve the fonts and report what would be downloaded and removed, without writing anything")
+	installCmd.Flags().StringVar(&StylesheetFlag, "stylesheet", "", "Write the stylesheet to this path instead of the config's, - for stdout")
+	installCmd.Flags().BoolVar(&PrintCSS, "print-css", false, "With --dry-run, print the stylesheet that would be written to stdout")
+	installCmd.Flags().BoolVar(&CheckWritable, "check-writable", false, "Test-create a file in dir and the stylesheet and lock file directories before any download, as --dry-run does")
+	installCmd.Flags().StringVar(&OutputFormat, "format", "", "Output preset setting the output fields: css, scss, css+preload (adds a manifest of the files to preload), json (adds a full manifest), inline (adds a stylesheet of data: urls) or email (adds an inlined stylesheet for HTML email)")
+	installCmd.Flags().BoolVar(&PrintConfig, "print-config", false, "Print the fully resolved config as YAML, after presets, defaults, environment overrides and flags, and exit without installing")
+	installCmd.Flags().BoolVar(&FlatStylesheet, "flat-stylesheet", false, "Store files with identical content once, pointing every rule that uses them at the shared file, and report how much was saved")
+	installCmd.Flags().BoolVar(&NoClean, "no-clean", false, "Leave files in dir that are no longer referenced by the config")
+	installCmd.Flags().BoolVar(&NoHeader, "no-header", false, "Leave out the generated-file banner at the top of the stylesheet")
+	installCmd.Flags().BoolVar(&ComputeMetrics, "compute-metrics", false, "Add ascent, descent and line gap overrides read from each downloaded file's hhea and OS/2 tables to the font's rules, css_url and axes ones included, where its metrics leave them out")
+	installCmd.Flags().BoolVar(&FontMetadata, "font-metadata", false, "Add a comment at the top of the stylesheet listing each family's version, license and source")
+	installCmd.Flags().IntVar(&Retries, "retries", 0, "Retry a font download that fails with a network or server error this many times")
+	installCmd.Flags().StringVar(&RetryJitter, "retry-jitter", jitterFull, "Randomize retry delays so concurrent downloads don't retry in lockstep: full, equal or none")
+	installCmd.Flags().DurationVar(&RetryMaxBackoff, "retry-max-backoff", defaultMaxBackoff, "Longest delay between retries, however many attempts have failed")
+	installCmd.Flags().IntVar(&RetryBudget, "retry-budget", 0, "Most download retries of the whole run, across all files; once spent, failed downloads are not retried. 0 for no limit")
+	installCmd.Flags().StringVar(&MinFileSize, "min-file-size", "0", "Fail when a downloaded font file is smaller than this, e.g. 1KB, as it is likely truncated. Text subsets can be small, so keep it low")
+	installCmd.Flags().BoolVar(&DeepVerify, "deep-verify", false, "Parse each downloaded woff2 header and table directory instead of only checking its signature")
+	installCmd.Flags().BoolVar(&VerifyFamilyName, "verify-family-name", false, "Check that the family each downloaded file names in its name table matches the requested family, warning on a mismatch (an error with --strict)")
+	installCmd.Flags().IntVar(&ParallelFamilies, "parallel-families", 1, "Number of font families installed at once, each looking up its metadata and downloading its variants")
+	installCmd.Flags().BoolVar(&GroupOutput, "group-output", false, "Print each family's lines as one block once the family is done, instead of interleaving them with other families' downloads; run-wide lines such as rate limits print as they happen")
+	installCmd.Flags().IntVar(&ParallelFiles, "parallel-files", 4, "Number of font files downloaded at once across all families")
+	installCmd.Flags().IntVar(&MaxFamilies, "max-families", 100, "Abort when the config lists more than this many families, 0 for no limit")
+	installCmd.Flags().BoolVar(&IfChanged, "if-changed", false, "Exit without doing anything when the config is unchanged since the last install and its files exist")
+	installCmd.Flags().BoolVar(&KeepTimestamps, "keep-timestamps", false, "Leave files whose content is unchanged untouched, keeping their modification times, and keep the stylesheet's header when its rules are unchanged")
+	installCmd.Flags().BoolVarP(&Interactive, "interactive", "i", false, "Prompt for fonts from the catalog, add them to the config, creating it if needed, then install")
+	installCmd.Flags().BoolVar(&Watch, "watch", false, "Install, then reinstall whenever the config changes until interrupted")
+	installCmd.Flags().BoolVar(&RelativeToCWD, "relative-to-cwd", false, "Resolve dir and stylesheet relative to the working directory instead of the config file")
 }
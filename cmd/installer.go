@@ -0,0 +1,764 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// installer holds the state of a single install run
+type installer struct {
+	cfg     *FontsYAML
+	verbose bool
+	// resolver looks up each family's metadata, staticResolver the static
+	// files of variable fonts
+	resolver       Resolver
+	staticResolver Resolver
+	// lock is the lock written by the previous run, newLock records this one
+	lock    *FontsLock
+	newLock *FontsLock
+	// wantedFiles tracks all font files that should exist after install
+	wantedFiles map[string]struct{}
+	// wantedLicenses tracks the license files that should exist in dir/licenses
+	wantedLicenses map[string]struct{}
+	cssRules       []string
+	// criticalRules are the rules written to critical_stylesheet
+	criticalRules []string
+	outcomes      []variantOutcome
+	// emptyFamilies are the entries that ended up with no installed variant
+	emptyFamilies []string
+	// originalNames maps each file name kept from a url to that url, to
+	// detect two urls with the same last path segment
+	originalNames map[string]string
+	// catalogDates are the current catalog dates by family, when they were
+	// looked up for update --since
+	catalogDates map[string]string
+	// lockedFamilies are the entries installed from the lock without a
+	// metadata lookup, whose catalog revision may be stale
+	lockedFamilies []string
+
+	// stage is the staging directory downloads go to with --staged
+	stage string
+	// minSize is the --min-file-size of a download in bytes
+	minSize int64
+	// signer signs the download urls with url_signing
+	signer URLSigner
+	// budget is the --retry-budget every download spends its retries from
+	budget *retryBudget
+
+	// files limits concurrent downloads to ParallelFiles
+	files chan struct{}
+	// mu guards newLock, wantedFiles, wantedLicenses, lockedFamilies, originalNames, fetching and filesBySum, which entries and
+	// variants installing concurrently share
+	mu       sync.Mutex
+	fetching map[string]*fetchResult
+	// filesBySum names the file holding each checksum with
+	// --flat-stylesheet, and deduped* count the downloads it saved
+	filesBySum   map[string]string
+	dedupedFiles int
+	dedupedBytes int64
+	// woffs converts each woff2 file to woff once, with convert_woff
+	woffs map[string]func() bool
+}
+
+// fetchResult is shared by every variant that needs the same file this run,
+// e.g. two weights that nearest_weight maps to one file
+type fetchResult struct {
+	url string
+	// owner is the family and variant that first claimed the file
+	owner      string
+	done       chan struct{}
+	v          *LockedVariant
+	downloaded bool
+}
+
+func newInstaller(cfg *FontsYAML, lock *FontsLock, verbose bool) *installer {
+	return &installer{
+		cfg:            cfg,
+		verbose:        verbose,
+		resolver:       googleResolver{},
+		staticResolver: googleResolver{static: true},
+		lock:           lock,
+		newLock:        &FontsLock{Fonts: map[string]*LockedFont{}},
+		wantedFiles:    map[string]struct{}{},
+		wantedLicenses: map[string]struct{}{},
+		cssRules:       []string{},
+		files:          make(chan struct{}, ParallelFiles),
+		fetching:       map[string]*fetchResult{},
+		woffs:          map[string]func() bool{},
+		originalNames:  map[string]string{},
+	}
+}
+
+// entryResult is what installing one entry adds to the stylesheet and summary
+type entryResult struct {
+	rules []string
+	// critical are the rules of the entry's critical variants
+	critical []string
+	outcomes []variantOutcome
+}
+
+// install installs up to ParallelFamilies entries at once. Rules and outcomes
+// keep the config's order whatever order the entries finish in.
+func (in *installer) install(entries []FontEntry) {
+	results := make([]entryResult, len(entries))
+	families := make(chan struct{}, ParallelFamilies)
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		families <- struct{}{}
+		wg.Add(1)
+		go func(i int, entry FontEntry) {
+			defer wg.Done()
+			results[i] = in.installEntry(entry)
+			flushFamilyOutput(entry.name())
+			<-families
+		}(i, entry)
+	}
+	wg.Wait()
+	for _, i := range stylesheetOrder(entries) {
+		r := results[i]
+		// the fallback and feature values rules go with the font's first rules
+		extra := []string{}
+		if len(r.critical) > 0 || len(r.rules) > 0 {
+			family := in.cfg.cssFamily(in.newLock.Fonts[entries[i].name()].Family)
+			if fallback := entries[i].Fallback; fallback != nil {
+				extra = append(extra, fallback.rule(family))
+			}
+			if values := entries[i].featureValuesRule(family); values != "" {
+				extra = append(extra, values)
+			}
+		}
+		if len(r.critical) > 0 {
+			r.critical = append(r.critical, extra...)
+		} else {
+			r.rules = append(r.rules, extra...)
+		}
+		in.cssRules = append(in.cssRules, r.rules...)
+		in.criticalRules = append(in.criticalRules, r.critical...)
+		in.outcomes = append(in.outcomes, r.outcomes...)
+		if len(r.rules) == 0 && len(r.critical) == 0 {
+			in.emptyFamilies = append(in.emptyFamilies, entries[i].name())
+		}
+	}
+}
+
+// stylesheetOrder returns the indexes of entries in the order their rules
+// are written: entries with an order first, lowest first, then the rest by name
+func stylesheetOrder(entries []FontEntry) []int {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ea, eb := entries[order[a]], entries[order[b]]
+		switch {
+		case ea.Order != nil && eb.Order != nil && *ea.Order != *eb.Order:
+			return *ea.Order < *eb.Order
+		case (ea.Order == nil) != (eb.Order == nil):
+			return ea.Order != nil
+		}
+		return ea.name() < eb.name()
+	})
+	return order
+}
+
+func (in *installer) installEntry(entry FontEntry) entryResult {
+	if entry.CSSURL != "" {
+		return in.installCSSURL(entry)
+	}
+	var res entryResult
+	// nothing to look up when only_variants filtered out every variant
+	if len(entry.Variants) == 0 && len(entry.only) == 0 && len(entry.Axes) == 0 {
+		return res
+	}
+	// Skip the metadata lookup entirely when every variant is already on disk
+	if !in.forced(entry.name()) && !in.refresh(entry) {
+		if locked, ok := in.lockedEntry(entry); ok {
+			for _, variant := range entry.Variants {
+				v := locked.Variants[variant]
+				in.logSkipped(entry, variant, v)
+				rule, outcome := in.addVariant(locked.Family, variant, v, entry, statusUpToDate)
+				switch {
+				case rule == "":
+				case entry.critical(variant):
+					res.critical = append(res.critical, rule)
+				default:
+					res.rules = append(res.rules, rule)
+				}
+				res.outcomes = append(res.outcomes, outcome)
+			}
+			if in.cfg.Licenses && entry.selfHosted() {
+				in.wantLicense(locked.License)
+			} else {
+				locked.License = ""
+			}
+			in.lockFont(entry.Family, locked)
+			in.mu.Lock()
+			in.lockedFamilies = append(in.lockedFamilies, entry.Family)
+			in.mu.Unlock()
+			return res
+		}
+	}
+	resolved, err := in.resolver.Resolve(entry.Family)
+	if err != nil && !errors.Is(err, errFontNotFound) {
+		printError("%v", err)
+		exit(1)
+	}
+	if err != nil {
+		familyWarning(entry.name(), "no font found for %s", entry.Family)
+		for _, variant := range entry.Variants {
+			o := in.outcome(entry.Family, variant, statusNotFound)
+			o.Error = "no font found for " + entry.Family
+			res.outcomes = append(res.outcomes, o)
+		}
+		return res
+	}
+	item := aliasVariants(*resolved, in.cfg.VariantAliases)
+	if entry.stretchRange() && !hasAxis(item, "wdth") {
+		familyWarning(entry.name(), "%s has no wdth axis, a font-stretch range has no effect on it", entry.Family)
+	}
+	if len(entry.Variants) == 0 && len(entry.only) > 0 {
+		entry.Variants = intersectVariants(item.Variants, entry.only)
+	}
+	// without files every variant would look misspelled, but the provider's
+	// data is at fault; axes are fetched from the CSS API instead
+	if len(item.Files) == 0 && len(entry.Axes) == 0 {
+		familyWarning(entry.name(), "no downloadable files for %s from the provider", entry.Family)
+		for _, variant := range entry.Variants {
+			o := in.outcome(entry.Family, variant, statusNoFiles)
+			o.Error = "the provider listed no downloadable files for " + entry.Family
+			res.outcomes = append(res.outcomes, o)
+		}
+		return res
+	}
+	// Static files are only needed as a fallback for variable fonts
+	var staticFiles map[string]string
+	if entry.wantsStaticFallback() {
+		if len(item.Axes) == 0 {
+			familyWarning(entry.name(), "%s is not a variable font, ignoring its variable font options", entry.Family)
+		} else if static, err := in.staticResolver.Resolve(entry.Family); err == nil {
+			staticFiles = aliasVariants(*static, in.cfg.VariantAliases).Files
+		} else if !errors.Is(err, errFontNotFound) {
+			printError("%v", err)
+			exit(1)
+		}
+	}
+	prev := in.lock.Fonts[entry.Family]
+	locked := &LockedFont{Family: item.Family, Variants: map[string]*LockedVariant{}, Version: item.Version, LastModified: item.LastModified, Category: item.Category}
+	switch {
+	case len(entry.Axes) > 0:
+		res = in.installAxes(entry, item, prev, locked)
+	case len(entry.Subsets) > 0:
+		res = in.installSubsets(entry, item, prev, locked)
+	default:
+		res = in.installVariants(entry, item, staticFiles, prev, locked)
+	}
+	if in.cfg.Licenses && entry.selfHosted() {
+		locked.License = in.license(item.Family, prev)
+	}
+	in.lockFont(entry.Family, locked)
+	return res
+}
+
+// installVariants installs each requested variant of entry into locked.
+// Variants download concurrently, limited by the file-level semaphore in fetch.
+func (in *installer) installVariants(entry FontEntry, item FontItem, staticFiles map[string]string, prev, locked *LockedFont) entryResult {
+	var res entryResult
+	vs := make([]*LockedVariant, len(entry.Variants))
+	res.rules = make([]string, len(entry.Variants))
+	res.outcomes = make([]variantOutcome, len(entry.Variants))
+	italics, slant := combinedItalics(entry, item)
+	uprights := map[string]bool{}
+	for _, upright := range italics {
+		uprights[upright] = true
+	}
+	var wg sync.WaitGroup
+	for i, variant := range entry.Variants {
+		if _, ok := italics[variant]; ok {
+			continue
+		}
+		var prevVariant *LockedVariant
+		if prev != nil {
+			prevVariant = prev.Variants[variant]
+		}
+		wg.Add(1)
+		go func(i int, variant string) {
+			defer wg.Done()
+			start := time.Now()
+			v, status := in.installVariant(entry, item, staticFiles, variant, prevVariant)
+			if v == nil {
+				res.outcomes[i] = in.outcome(item.Family, variant, status)
+				res.outcomes[i].Error = "the provider returned an invalid font URL"
+			} else {
+				if uprights[variant] {
+					v.Slant = slant
+				}
+				vs[i] = v
+				res.rules[i], res.outcomes[i] = in.addVariant(item.Family, variant, v, entry, status)
+			}
+			res.outcomes[i].Duration = time.Since(start)
+		}(i, variant)
+	}
+	wg.Wait()
+	// combined italics share the upright's file and rule
+	for i, variant := range entry.Variants {
+		upright, ok := italics[variant]
+		if !ok {
+			continue
+		}
+		j := slices.Index(entry.Variants, upright)
+		if vs[j] == nil {
+			res.outcomes[i] = res.outcomes[j]
+			res.outcomes[i].Variant = variant
+			continue
+		}
+		v := *vs[j]
+		v.Source = upright
+		vs[i] = &v
+		res.rules[i], res.outcomes[i] = in.addVariant(item.Family, variant, &v, entry, res.outcomes[j].Status+" (combined with "+upright+")")
+	}
+	critical := make([]bool, len(entry.Variants))
+	for i, variant := range entry.Variants {
+		if vs[i] != nil {
+			locked.Variants[variant] = vs[i]
+		}
+		critical[i] = entry.critical(variant)
+	}
+	// skipped variants have no rule
+	res.rules, res.critical = splitCritical(res.rules, critical)
+	return res
+}
+
+// installVariant downloads a variant's files unless prev shows they are up
+// to date, returning its lock record and summary status. The record is nil
+// when the variant was skipped.
+func (in *installer) installVariant(entry FontEntry, item FontItem, staticFiles map[string]string, variant string, prev *LockedVariant) (*LockedVariant, string) {
+	// source is the variant whose file is installed for the requested variant
+	source := variant
+	url, ok := item.Files[variant]
+	if !ok && entry.NearestWeight {
+		if sub, found := nearestVariant(variant, item.Files); found {
+			familyWarning(entry.name(), "%s has no %s variant, using %s instead", entry.Family, variant, sub)
+			source, url, ok = sub, item.Files[sub], true
+		}
+	}
+	if !ok {
+		printError("variant %s not found for %s", variant, entry.Family)
+		fmt.Fprintln(os.Stderr, "Available variants:", item.Variants)
+		exit(1)
+	}
+	fileName := in.sourceFileName(url, item.Family, source, "")
+	if entry.Text != "" {
+		url = textSubsetURL(item.Family, source, entry.Text)
+		// label subset files so they aren't mistaken for the full font
+		fileName = in.fileName(item.Family, source, kindText)
+	}
+	v, downloaded := in.fetch(entry, variant, url, fileName, prev)
+	if v == nil {
+		return nil, statusInvalidURL
+	}
+	if staticURL, ok := staticFiles[source]; ok {
+		var prevFallback *LockedVariant
+		if prev != nil {
+			prevFallback = prev.Fallback
+		}
+		var fallbackDownloaded bool
+		v.Fallback, fallbackDownloaded = in.fetch(entry, variant, staticURL, in.sourceFileName(staticURL, item.Family, source, kindStatic), prevFallback)
+		downloaded = downloaded || fallbackDownloaded
+	}
+	status := statusUpToDate
+	switch {
+	case v.Remote:
+		status = statusRemote
+	case downloaded:
+		status = statusDownloaded
+	}
+	if source != variant {
+		v.Source = source
+		status += " (as " + source + ")"
+	}
+	return v, status
+}
+
+func (in *installer) lockFont(key string, locked *LockedFont) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.newLock.Fonts[key] = locked
+}
+
+// lockedEntry returns the locked font when every requested variant is up to
+// date on disk under the name the current config would give it, rebuilt
+// with just those variants so ones removed from the config leave the lock
+func (in *installer) lockedEntry(entry FontEntry) (*LockedFont, bool) {
+	locked, ok := in.lock.Fonts[entry.Family]
+	// subset and axis files are only known once the CSS API has been asked
+	if !ok || len(entry.Variants) == 0 || len(entry.Subsets) > 0 || len(entry.Axes) > 0 {
+		return nil, false
+	}
+	if in.cfg.Licenses && entry.selfHosted() {
+		if _, err := os.Stat(filepath.Join(in.cfg.Dir, locked.License)); locked.License == "" || err != nil {
+			return nil, false
+		}
+	}
+	// locks written before categories were recorded need a lookup for the fallback stack
+	if in.cfg.CSSVariables && entry.FallbackStack == "" && locked.Category == "" {
+		return nil, false
+	}
+	for _, variant := range entry.Variants {
+		v := locked.Variants[variant]
+		if v == nil || v.Text != entry.Text || v.Remote == entry.selfHosted() {
+			return nil, false
+		}
+		// combined_italic was turned on or off
+		if entry.combinesItalic(variant) != (v.Slant != "") {
+			return nil, false
+		}
+		source := variant
+		if v.Source != "" {
+			source = v.Source
+		}
+		kind := ""
+		if entry.Text != "" {
+			kind = kindText
+		}
+		if !v.Remote && (!in.lockedAs(v, in.sourceFileName(v.URL, locked.Family, source, kind)) || !v.upToDate(in.cfg.Dir)) {
+			return nil, false
+		}
+		if entry.wantsStaticFallback() != (v.Fallback != nil) {
+			return nil, false
+		}
+		// sri was turned on since
+		if in.cfg.SRI && (v.Integrity == "" || v.Fallback != nil && v.Fallback.Integrity == "") {
+			return nil, false
+		}
+		if v.Fallback != nil && !v.Fallback.Remote && (!in.lockedAs(v.Fallback, in.sourceFileName(v.Fallback.URL, locked.Family, source, kindStatic)) || !v.Fallback.upToDate(in.cfg.Dir)) {
+			return nil, false
+		}
+	}
+	rebuilt := *locked
+	rebuilt.Variants = map[string]*LockedVariant{}
+	for _, variant := range entry.Variants {
+		rebuilt.Variants[variant] = locked.Variants[variant]
+	}
+	return &rebuilt, true
+}
+
+// fetch downloads url to fileName in cfg.Dir, unless prev shows the same
+// source is already on disk, and returns the lock record for the file and
+// whether it was downloaded. The record is nil when the url was invalid.
+func (in *installer) fetch(entry FontEntry, variant, url, fileName string, prev *LockedVariant) (*LockedVariant, bool) {
+	if !entry.selfHosted() {
+		if err := checkFontURL(url); err != nil {
+			familyWarning(entry.name(), "%v for %s (%s), skipping", err, entry.Family, variant)
+			return nil, false
+		}
+		v := &LockedVariant{URL: url, Remote: true}
+		in.addIntegrity(entry, variant, v, prev)
+		return v, false
+	}
+	// Only download variants that are new or whose source changed
+	if !in.forced(entry.Family) && prev != nil && prev.URL == url && in.lockedAs(prev, fileName) && prev.upToDate(in.cfg.Dir) {
+		v := &LockedVariant{File: prev.File, URL: prev.URL, SHA256: prev.SHA256, Text: prev.Text, Mirror: prev.Mirror, Shared: prev.Shared}
+		in.addIntegrity(entry, variant, v, prev)
+		in.logSkipped(entry, variant, v)
+		return v, false
+	}
+	in.mu.Lock()
+	if r, ok := in.fetching[fileName]; ok {
+		in.mu.Unlock()
+		if r.url != url {
+			// the second download would overwrite the first
+			printError("%s and %s (%s) would both be saved as %s, adjust naming or variant_aliases", r.owner, entry.Family, variant, fileName)
+			exit(1)
+		}
+		<-r.done
+		return r.copy()
+	}
+	r := &fetchResult{url: url, owner: fmt.Sprintf("%s (%s)", entry.Family, variant), done: make(chan struct{})}
+	in.fetching[fileName] = r
+	in.mu.Unlock()
+	r.v, r.downloaded = in.download(entry, variant, url, fileName)
+	close(r.done)
+	return r.copy()
+}
+
+// copy gives each variant sharing the file its own lock record
+func (r *fetchResult) copy() (*LockedVariant, bool) {
+	if r.v == nil {
+		return nil, false
+	}
+	v := *r.v
+	return &v, r.downloaded
+}
+
+// download fetches url to fileName in cfg.Dir once a file-level slot is
+// free, then moves it to its checksum's path with the hashed layout. It
+// returns nil when the provider gave an invalid font url.
+func (in *installer) download(entry FontEntry, variant, url, fileName string) (*LockedVariant, bool) {
+	if DryRun {
+		familyStatus(entry.name(), colorCyan, "Would download", "%s (%s) -> %s", entry.Family, variant, filepath.Join(in.cfg.Dir, fileName))
+		return &LockedVariant{File: fileName, URL: url, Text: entry.Text}, true
+	}
+	in.files <- struct{}{}
+	defer func() { <-in.files }()
+	filePath := filepath.Join(in.writeDir(), fileName)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		printError("failed to create directory for %s: %v", fileName, err)
+		exit(1)
+	}
+	src := url
+	if entry.Text != "" {
+		var err error
+		if src, err = resolveCSS2FontURL(url); err != nil {
+			printError("failed to resolve text subset for %s (%s): %v", entry.Family, variant, err)
+			exit(1)
+		}
+	}
+	if err := checkFontURL(src); err != nil {
+		familyWarning(entry.name(), "%v for %s (%s), skipping", err, entry.Family, variant)
+		return nil, false
+	}
+	opts := in.downloadOptions(entry)
+	var integrity string
+	if in.cfg.SRI {
+		opts.integrity = &integrity
+	}
+	mirror, sum, err := downloadFromMirrors(src, in.cfg.Mirrors, filePath, opts)
+	if err != nil {
+		printError("failed to download %s: %v", fileName, err)
+		exit(1)
+	}
+	if err := verifyWOFF2(filePath, DeepVerify); err != nil {
+		os.Remove(filePath)
+		printError("downloaded file failed verification: %v", err)
+		exit(1)
+	}
+	if err := checkMinSize(filePath, in.minSize); err != nil {
+		os.Remove(filePath)
+		printError("downloaded file failed verification: %v", err)
+		exit(1)
+	}
+	if err := checkFamilyName(entry.Family, fileName, filePath); err != nil {
+		if Strict {
+			os.Remove(filePath)
+			printError("downloaded file failed verification: %v", err)
+			exit(1)
+		}
+		familyWarning(entry.name(), "%v", err)
+	}
+	shared := false
+	if in.sharesFiles() {
+		if fileName, shared = in.shareFile(fileName, filePath, sum); shared {
+			filePath = in.filePath(fileName)
+		}
+	}
+	if in.cfg.Layout == layoutHashed {
+		if fileName, err = in.moveToHashed(filePath, sum); err != nil {
+			printError("could not move %s to its hashed path: %v", filePath, err)
+			exit(1)
+		}
+		filePath = filepath.Join(in.writeDir(), filepath.FromSlash(fileName))
+	}
+	if in.verbose {
+		if mirror != "" {
+			familyStatus(entry.name(), colorGreen, "Downloaded", "%s (%s) -> %s via mirror %s", entry.Family, variant, filePath, mirror)
+		} else {
+			familyStatus(entry.name(), colorGreen, "Downloaded", "%s (%s) -> %s", entry.Family, variant, filePath)
+		}
+	}
+	v := &LockedVariant{File: fileName, URL: url, SHA256: sum, Text: entry.Text, Mirror: mirror, Shared: shared, Integrity: integrity}
+	return v, true
+}
+
+func (in *installer) fileName(family, variant, kind string) string {
+	name, err := renderFileName(in.cfg.naming, family, variant, kind)
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	return in.formatPath(name)
+}
+
+// sourceFileName names the file downloaded from url: its last path segment
+// with keep_original_name, otherwise the naming template. Text subsets are
+// always templated, as their font url is only known once downloaded.
+func (in *installer) sourceFileName(url, family, variant, kind string) string {
+	if !in.cfg.KeepOriginalName || kind == kindText {
+		return in.fileName(family, variant, kind)
+	}
+	name, ok := originalFileName(url)
+	if !ok {
+		return in.fileName(family, variant, kind)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if claimed, ok := in.originalNames[name]; ok && claimed != url {
+		// a distinct url with the same name gets a suffix from its own hash
+		renamed := strings.TrimSuffix(name, ".woff2") + "_" + urlHash(url) + ".woff2"
+		printWarning("%s (%s) has the same file name as %s, saving it as %s", family, variant, claimed, renamed)
+		name = renamed
+	}
+	in.originalNames[name] = url
+	return in.formatPath(name)
+}
+
+// addVariant marks a variant's files as wanted, adds its CSS rule and
+// records its outcome
+func (in *installer) addVariant(family, variant string, v *LockedVariant, entry FontEntry, status string) (string, variantOutcome) {
+	files := []string{}
+	for f := v; f != nil; f = f.Fallback {
+		if !f.Remote {
+			in.want(f.File)
+			files = append(files, f.File)
+		}
+	}
+	return in.fontRule(family, variant, v, entry), in.outcome(family, variant, status, files...)
+}
+
+// want marks a font file as wanted, writing its precompressed sidecar if enabled
+func (in *installer) want(fileName string) {
+	in.mu.Lock()
+	_, seen := in.wantedFiles[fileName]
+	in.wantedFiles[fileName] = struct{}{}
+	in.mu.Unlock()
+	// a file shared by several variants is only compressed once
+	if seen || in.cfg.Precompress == "" {
+		return
+	}
+	if DryRun {
+		// keep the sidecar a real run would write out of the cleanup
+		in.mu.Lock()
+		in.wantedFiles[fileName+sidecarExts[in.cfg.Precompress]] = struct{}{}
+		in.mu.Unlock()
+		return
+	}
+	sidecar, err := precompressFile(in.filePath(fileName), in.cfg.Precompress)
+	if err != nil {
+		printError("failed to precompress %s: %v", fileName, err)
+		exit(1)
+	}
+	in.mu.Lock()
+	in.wantedFiles[fileName+filepath.Ext(sidecar)] = struct{}{}
+	in.mu.Unlock()
+}
+
+// fontRule renders the CSS for one installed variant of entry
+func (in *installer) fontRule(family, variant string, v *LockedVariant, entry FontEntry) string {
+	if combinedItalic(variant, v) {
+		return ""
+	}
+	gen := func(srcs ...fontSrc) string {
+		rule := addDescriptor(genCSS(in.cfg.cssFamily(family), variant, in.orderSrcs(srcs)), "font-stretch", entry.stretch(variant))
+		if w := entry.weight(variant); w != "" {
+			rule = setDescriptor(rule, "font-weight", w)
+		}
+		rule = in.addMetrics(rule, entry, v)
+		return addDescriptor(rule, "font-feature-settings", entry.featureSettings())
+	}
+	var rule string
+	switch {
+	case v.Fallback != nil && entry.VariableSupportsGuard:
+		rule = gen(append(in.localSrcs(v.Fallback), in.variantSrcs(v.Fallback)...)...) + "\n\n" + supportsVariations(gen(in.variantSrcs(v)...))
+	case v.Fallback != nil:
+		// an installed static copy would shadow the variable font
+		rule = gen(append(in.variantSrcs(v, "variations"), in.variantSrcs(v.Fallback)...)...)
+	default:
+		rule = gen(append(in.localSrcs(v), in.variantSrcs(v)...)...)
+	}
+	if v.Slant != "" {
+		rule = setDescriptor(rule, "font-style", "oblique "+v.Slant)
+	}
+	if v.Fallback == nil {
+		rule = strings.Join(append([]string{rule}, in.namedInstanceRules(entry, in.cfg.cssFamily(family), rule, v)...), "\n\n")
+	}
+	if entry.Text != "" {
+		rule = textSubsetComment(entry.Text) + "\n" + rule
+	}
+	if v.Remote {
+		rule = entry.preloadNote(in.variantSrc(v), v.Integrity) + "\n" + rule
+	}
+	return rule
+}
+
+// downloadOptions are the download settings of entry's files, its own
+// timeout and retries taking precedence over the flags
+func (in *installer) downloadOptions(entry FontEntry) downloadOptions {
+	opts := downloadOptions{contentTypes: in.cfg.fontContentTypes(), retries: Retries, signer: in.signer, budget: in.budget, verbose: in.verbose}
+	if entry.Timeout != nil {
+		opts.timeout = *entry.Timeout
+	}
+	if entry.Retries != nil {
+		opts.retries = *entry.Retries
+	}
+	return opts
+}
+
+// srcURL is the url the stylesheet uses to reference a font file
+func (in *installer) srcURL(fileName string) string {
+	if in.cfg.BaseURL == "" {
+		return fileName
+	}
+	return strings.TrimSuffix(in.cfg.BaseURL, "/") + "/" + fileName
+}
+
+// fileURL is srcURL for a locked file, with the cache_bust query when set.
+// Dry runs have no checksum and so no query.
+func (in *installer) fileURL(v *LockedVariant) string {
+	u := in.srcURL(v.File)
+	if in.cfg.CacheBust && len(v.SHA256) >= 8 {
+		u += "?v=" + v.SHA256[:8]
+	}
+	return u
+}
+
+func (in *installer) logSkipped(entry FontEntry, variant string, v *LockedVariant) {
+	if in.verbose && v.Remote {
+		familyStatus(entry.name(), colorYellow, "Skipped", "%s (%s) is not self-hosted, using %s", entry.Family, variant, v.URL)
+	} else if in.verbose {
+		familyStatus(entry.name(), colorYellow, "Skipped", "%s (%s) -> %s is up to date", entry.Family, variant, filepath.Join(in.cfg.Dir, v.File))
+	}
+}
+
+// nearestVariant finds the available variant of the same style whose weight
+// is closest to the requested numeric variant. Ties follow the CSS font
+// matching preference: lighter weights below 500, heavier ones from 500 up.
+func nearestVariant(variant string, files map[string]string) (string, bool) {
+	style, weight := variantStyleWeight(variant)
+	want, err := strconv.Atoi(weight)
+	if err != nil {
+		return "", false
+	}
+	best, bestDist := "", -1
+	for candidate := range files {
+		s, w := variantStyleWeight(candidate)
+		got, err := strconv.Atoi(w)
+		if err != nil || s != style {
+			continue
+		}
+		dist := got - want
+		if dist < 0 {
+			dist = -dist
+		}
+		better := bestDist < 0 || dist < bestDist
+		if dist == bestDist {
+			_, bw := variantStyleWeight(best)
+			b, _ := strconv.Atoi(bw)
+			if want >= 500 {
+				better = got > b
+			} else {
+				better = got < b
+			}
+		}
+		if better {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best, bestDist >= 0
+}
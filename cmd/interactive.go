@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flag variables
+var Interactive bool
+
+// interactiveMatches is how many search results are offered at once
+const interactiveMatches = 10
+
+// prompter reads answers to questions asked on stdout
+type prompter struct {
+	in *bufio.Reader
+}
+
+// ask prints question and returns the trimmed answer, or def when the
+// answer is empty. End of input cancels the run.
+func (p *prompter) ask(question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		fmt.Println()
+		printError("cancelled, the config was not changed")
+		exit(1)
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+// confirm asks a yes/no question, defaulting to no
+func (p *prompter) confirm(question string) bool {
+	answer := strings.ToLower(p.ask(question+" (y/N)", ""))
+	return answer == "y" || answer == "yes"
+}
+
+// family searches the catalog, in popularity order, until one family is
+// picked, by its exact name or by its number among the matches
+func (p *prompter) family(items []FontItem) FontItem {
+	query := p.ask("Font family (type part of a name to search)", "")
+	for {
+		var matches []FontItem
+		for _, item := range items {
+			if strings.EqualFold(item.Family, query) {
+				return item
+			}
+			if strings.Contains(strings.ToLower(item.Family), strings.ToLower(query)) {
+				matches = append(matches, item)
+			}
+		}
+		if len(matches) == 0 {
+			query = p.ask(fmt.Sprintf("No family matches %q, search again", query), "")
+			continue
+		}
+		shown := matches[:min(len(matches), interactiveMatches)]
+		for i, item := range shown {
+			fmt.Printf("  %2d) %s\n", i+1, item.Family)
+		}
+		if len(matches) > len(shown) {
+			fmt.Printf("  ... and %d more, type more of the name to narrow them down\n", len(matches)-len(shown))
+		}
+		answer := p.ask("Pick a number, or search again", "1")
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(shown) {
+			return shown[n-1]
+		}
+		query = answer
+	}
+}
+
+// choose lists options and returns those picked by number or name, "all"
+// for every option, or def for an empty answer
+func (p *prompter) choose(question string, options, def []string) []string {
+	for i, option := range options {
+		fmt.Printf("  %2d) %s\n", i+1, option)
+	}
+	for {
+		answer := p.ask(question+", separated by commas or spaces, or all", strings.Join(def, ", "))
+		if answer == strings.Join(def, ", ") {
+			return def
+		}
+		if answer == "all" {
+			return options
+		}
+		picked, err := pickOptions(answer, options)
+		if err == nil {
+			return picked
+		}
+		fmt.Println(err)
+	}
+}
+
+// pickOptions reads a list of option numbers or names
+func pickOptions(answer string, options []string) ([]string, error) {
+	picked := []string{}
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+		option := field
+		if n, err := strconv.Atoi(field); err == nil && n >= 1 && n <= len(options) {
+			option = options[n-1]
+		} else if !slices.Contains(options, field) {
+			return nil, fmt.Errorf("%q is not one of the options", field)
+		}
+		if !slices.Contains(picked, option) {
+			picked = append(picked, option)
+		}
+	}
+	return picked, nil
+}
+
+// interactiveConfig prompts for fonts from the catalog and adds them to
+// the config at path, creating it when missing. An existing config keeps
+// its other fields and comments.
+func interactiveConfig(path string) {
+	if !isTerminal(os.Stdin) {
+		printError("--interactive needs a terminal, edit %s instead when scripting", path)
+		exit(1)
+	}
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		printError("could not read YAML: %v", err)
+		exit(1)
+	default:
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+	}
+	p := &prompter{in: bufio.NewReader(os.Stdin)}
+	if len(doc.Content) == 0 {
+		fmt.Printf("Creating %s\n", path)
+		cfg := FontsYAML{
+			Version:    configVersion,
+			Fonts:      []FontEntry{},
+			Dir:        p.ask("Directory for the font files", "./webfonts"),
+			Stylesheet: p.ask("Stylesheet to write", "./fonts.css"),
+		}
+		var root yaml.Node
+		if err := root.Encode(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&root}}
+	}
+	root := doc.Content[0]
+	fonts := mappingValue(root, "fonts")
+	if fonts == nil {
+		fonts = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "fonts"}, fonts)
+	}
+	if root.Kind != yaml.MappingNode || fonts.Kind != yaml.SequenceNode {
+		printError("%s is not a fonts.yaml config", path)
+		exit(1)
+	}
+	// a flow-style list from the new config's empty fonts reads badly once filled
+	fonts.Style = 0
+
+	catalog := queryWebfonts("&sort=popularity", "could not fetch the font catalog")
+	for {
+		item := p.family(catalog.Items)
+		def := []string{"regular"}
+		if !slices.Contains(item.Variants, "regular") {
+			def = item.Variants[:1]
+		}
+		fmt.Printf("Variants of %s:\n", item.Family)
+		entry := FontEntry{Family: item.Family, Variants: p.choose("Variants to install", item.Variants, def)}
+		if len(item.Subsets) > 1 {
+			fmt.Println("Subsets, each installed as its own file with a unicode-range:")
+			entry.Subsets = p.choose("Subsets, or Enter for a single file with all of them", item.Subsets, nil)
+		}
+		var node yaml.Node
+		if err := node.Encode(entry); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		fonts.Content = append(fonts.Content, &node)
+		printSuccess("Added", "%s (%s)", entry.Family, strings.Join(entry.Variants, ", "))
+		if !p.confirm("Add another family?") {
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		printError("failed to create directory for %s: %v", path, err)
+		exit(1)
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		printError("failed to write %s: %v", path, err)
+		exit(1)
+	}
+	printSuccess("Wrote", "%s", path)
+}
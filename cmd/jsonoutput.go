@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// resultVersion is the version of the --json result shapes, raised when
+// one of them changes in a way scripts reading it would notice
+const resultVersion = 1
+
+// resultTypes are the --json results of the commands, by command, which
+// hermes schema --result describes
+var resultTypes = map[string]reflect.Type{
+	"check":       reflect.TypeOf(checkResult{}),
+	"diff-config": reflect.TypeOf(fontSetDiff{}),
+	"install":     reflect.TypeOf(installReport{}),
+	"orphans":     reflect.TypeOf(orphansResult{}),
+	"prune":       reflect.TypeOf(pruneResult{}),
+	"verify":      reflect.TypeOf(verifyResult{}),
+}
+
+// resultSchema names the shape of a --json result, e.g. hermes.verify.v1.
+// Result types embed it, so it is the first field of each.
+type resultSchema struct {
+	Schema string `json:"schema"`
+}
+
+func (s *resultSchema) setSchema(name string) { s.Schema = name }
+
+// jsonResult is a --json result, tagged with its schema when printed
+type jsonResult interface {
+	setSchema(name string)
+}
+
+// resultSchemaName is the schema of the --json result of command
+func resultSchemaName(command string) string {
+	return fmt.Sprintf("hermes.%s.v%d", command, resultVersion)
+}
+
+// resultCommands are the commands with a --json result, sorted
+func resultCommands() []string {
+	commands := make([]string, 0, len(resultTypes))
+	for c := range resultTypes {
+		commands = append(commands, c)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// startJSON moves what a command prints for people to stderr, keeping
+// stdout for its --json result
+func startJSON() {
+	os.Stdout = os.Stderr
+}
+
+// printResult prints the --json result of command on the process's stdout
+func printResult(command string, r jsonResult) {
+	r.setSchema(resultSchemaName(command))
+	encodeJSON(stylesheetStdout, r)
+}
+
+// checkResult is the --json result of check
+type checkResult struct {
+	resultSchema
+	OK bool `json:"ok"`
+	// Fonts is the number of fonts checked
+	Fonts    int      `json:"fonts"`
+	Problems []string `json:"problems"`
+	// Errors are the errors that ended a failed run before it checked
+	// every font
+	Errors []string `json:"errors,omitempty"`
+}
+
+// verifyResult is the --json result of verify
+type verifyResult struct {
+	resultSchema
+	OK       bool     `json:"ok"`
+	Problems []string `json:"problems"`
+	// Pruned are the rules --prune-stylesheet removed, by stylesheet
+	Pruned map[string]int `json:"pruned"`
+	// Errors are the errors that ended a failed run before it verified
+	// everything
+	Errors []string `json:"errors,omitempty"`
+}
+
+// orphansResult is the --json result of orphans
+type orphansResult struct {
+	resultSchema
+	OK  bool   `json:"ok"`
+	Dir string `json:"dir"`
+	// Files are the unreferenced files, in dir
+	Files []string `json:"files"`
+	// Clean is whether install removes them
+	Clean  bool     `json:"clean"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// pruneResult is the --json result of prune
+type pruneResult struct {
+	resultSchema
+	DryRun bool `json:"dry_run"`
+	// Fonts is the number of fonts removed as a whole
+	Fonts int `json:"fonts"`
+	// Variants are the removed variants, e.g. "Roboto: 700"
+	Variants []string `json:"variants"`
+	// Files are the removed files, in dir
+	Files []string `json:"files"`
+	// Rules are the @font-face rules removed, by stylesheet
+	Rules map[string]int `json:"rules"`
+}
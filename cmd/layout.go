@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// layoutHashed stores each font file at a path derived from its checksum
+const layoutHashed = "hashed"
+
+// hashedDirName matches the two-hex-digit directories of the hashed layout
+var hashedDirName = regexp.MustCompile(`^[0-9a-f]{2}$`)
+
+// formatDirs are the subdirectories files go to with split_by_format
+var formatDirs = []string{"woff2", "woff", "ttf", "otf"}
+
+// isFormatDir reports whether f, relative to dir, is a split_by_format
+// subdirectory
+func isFormatDir(f string) bool {
+	return slices.Contains(formatDirs, f)
+}
+
+// formatPath puts a file name in the subdirectory of its format with
+// split_by_format, e.g. woff2/Roboto_regular.woff2
+func (in *installer) formatPath(name string) string {
+	if !in.cfg.SplitByFormat {
+		return name
+	}
+	return strings.TrimPrefix(path.Ext(name), ".") + "/" + name
+}
+
+func validateLayout(cfg *FontsYAML) error {
+	switch cfg.Layout {
+	case "", "flat":
+	case layoutHashed:
+		if cfg.KeepOriginalName || cfg.Naming != "" || cfg.SplitByFormat {
+			return fmt.Errorf("`layout: hashed` names files by their checksum and cannot be combined with naming, keep_original_name or split_by_format")
+		}
+	default:
+		return fmt.Errorf("`layout` must be flat or hashed, got %q", cfg.Layout)
+	}
+	return nil
+}
+
+// hashedFileName is the content-addressed path in dir of a file with the
+// sha256 sum, e.g. ab/cd/abcd1234ef567890.woff2. A changed file gets a new
+// path, so the files can be served as immutable.
+func hashedFileName(sum string) string {
+	return sum[0:2] + "/" + sum[2:4] + "/" + sum[:16] + ".woff2"
+}
+
+// lockedAs reports whether the locked file is stored under the name the
+// current config gives it: fileName, its checksum's path with the hashed
+// layout, or the file it shares with --flat-stylesheet
+func (in *installer) lockedAs(v *LockedVariant, fileName string) bool {
+	if in.cfg.Layout == layoutHashed {
+		return len(v.SHA256) >= 16 && v.File == hashedFileName(v.SHA256)
+	}
+	if v.Shared && in.sharesFiles() {
+		return true
+	}
+	return v.File == fileName
+}
+
+// moveToHashed moves a downloaded file to its checksum's path in the write
+// directory and returns that path relative to it. With --keep-timestamps
+// a file already at that path, which has the same content, is kept.
+func (in *installer) moveToHashed(filePath, sum string) (string, error) {
+	name := hashedFileName(sum)
+	target := filepath.Join(in.writeDir(), filepath.FromSlash(name))
+	if KeepTimestamps && sameContent(filePath, target) {
+		return name, os.Remove(filePath)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	return name, os.Rename(filePath, target)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// googleFontsRepo hosts the license of every family, since the Developer API
+// does not expose one
+const googleFontsRepo = "https://raw.githubusercontent.com/google/fonts/main"
+
+// licensesDir is the subdirectory of dir license files are written to
+const licensesDir = "licenses"
+
+// licenseFiles are the license directories of the google/fonts repository
+// and the file each keeps its license text in, most common first
+var licenseFiles = [][2]string{
+	{"ofl", "OFL.txt"},
+	{"apache", "LICENSE.txt"},
+	{"ufl", "UFL.txt"},
+}
+
+// licenseRepoDir is a family's directory name in the repository, e.g. opensans
+func licenseRepoDir(family string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(family))
+}
+
+// licenseIDs are the SPDX identifiers of the licenses in licenseFiles
+var licenseIDs = map[string]string{
+	"OFL.txt":     "OFL-1.1",
+	"LICENSE.txt": "Apache-2.0",
+	"UFL.txt":     "UFL-1.0",
+}
+
+// licenseID is the SPDX identifier of a license file written by install
+func licenseID(file string) string {
+	for name, id := range licenseIDs {
+		if strings.HasSuffix(file, "_"+name) {
+			return id
+		}
+	}
+	return "unknown"
+}
+
+// isLicenseFile reports whether name is a license file written by install
+func isLicenseFile(name string) bool {
+	for _, l := range licenseFiles {
+		if strings.HasSuffix(name, "_"+l[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// license downloads the family's license into dir/licenses and returns its
+// path relative to dir, reusing the file prev recorded when it still exists.
+// It returns an empty string when no license could be found.
+func (in *installer) license(family string, prev *LockedFont) string {
+	if prev != nil && prev.License != "" && !in.forced(family) {
+		if _, err := os.Stat(filepath.Join(in.cfg.Dir, prev.License)); err == nil {
+			in.wantLicense(prev.License)
+			return prev.License
+		}
+	}
+	if DryRun {
+		printStatus(colorCyan, "Would download", "%s license -> %s", family, filepath.Join(in.cfg.Dir, licensesDir))
+		return ""
+	}
+	if err := os.MkdirAll(filepath.Join(in.writeDir(), licensesDir), 0755); err != nil {
+		printWarning("could not create licenses directory: %v", err)
+		return ""
+	}
+	in.files <- struct{}{}
+	defer func() { <-in.files }()
+	for _, l := range licenseFiles {
+		file := filepath.Join(licensesDir, family+"_"+l[1])
+		url := googleFontsRepo + "/" + l[0] + "/" + licenseRepoDir(family) + "/" + l[1]
+		if _, err := downloadToFile(url, filepath.Join(in.writeDir(), file), downloadOptions{retries: Retries, budget: in.budget, verbose: in.verbose}); err == nil {
+			if in.verbose {
+				printSuccess("Downloaded", "%s license -> %s", family, filepath.Join(in.cfg.Dir, file))
+			}
+			in.wantLicense(file)
+			return file
+		}
+	}
+	printWarning("no license file found for %s", family)
+	return ""
+}
+
+func (in *installer) wantLicense(file string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.wantedLicenses[filepath.Base(file)] = struct{}{}
+}
+
+// removeUnreferencedLicenses deletes license files of families no longer installed
+func removeUnreferencedLicenses(dir string, wanted map[string]struct{}, verbose bool) {
+	for _, fullPath := range unreferencedLicenses(dir, wanted) {
+		if DryRun {
+			printStatus(colorCyan, "Would remove", "unreferenced license file: %s", fullPath)
+			continue
+		}
+		if verbose {
+			printStatus(colorYellow, "Removing", "unreferenced license file: %s", fullPath)
+		}
+		os.Remove(fullPath)
+	}
+}
+
+// unreferencedLicenses lists the license files in dir/licenses that aren't
+// in wanted
+func unreferencedLicenses(dir string, wanted map[string]struct{}) []string {
+	entries, err := os.ReadDir(filepath.Join(dir, licensesDir))
+	if err != nil {
+		return nil
+	}
+	ig := readHermesIgnore(dir)
+	files := []string{}
+	for _, e := range entries {
+		if _, ok := wanted[e.Name()]; !ok && isLicenseFile(e.Name()) && !ig.ignored(licensesDir+"/"+e.Name()) {
+			files = append(files, filepath.Join(dir, licensesDir, e.Name()))
+		}
+	}
+	return files
+}
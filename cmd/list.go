@@ -8,33 +8,58 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 )
 
+// flag variables
+var ListJSON bool
+
+// FamilyInfo is the --json representation of a listed font family
+type FamilyInfo struct {
+	Family   string   `json:"family"`
+	Variants []string `json:"variants"`
+	Subsets  []string `json:"subsets"`
+}
+
 type FontList struct {
-	Items []struct {
-		Family string `json:"family"`
-	} `json:"items"`
+	Items []FontItem `json:"items"`
 }
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [font]",
 	Short: "Lists the 10 most trending Google Fonts",
 	Long: `Lists the 10 most trending Google Fonts,
-providing inspiration for your next project.`,
+providing inspiration for your next project.
+
+When a font family is given, lists its available variants and subsets instead.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			listFamily(args[0])
+			return
+		}
+		if Offline {
+			printTrending(loadCatalogSnapshot().Items)
+			return
+		}
 		key := viper.Get("GFONTS_KEY")
 		if key == nil {
-			fmt.Println(`Error: required variable "GFONTS_KEY" not found. Get a key at: https://console.cloud.google.com/apis/credentials`)
-			os.Exit(1)
+			printError(`required variable "GFONTS_KEY" not found. Get a key at: https://console.cloud.google.com/apis/credentials`)
+			exit(1)
 		}
-		url := "https://www.googleapis.com/webfonts/v1/webfonts?key=" + fmt.Sprint(key) + "&sort=trending"
+		url := webfontsAPI + "?key=" + fmt.Sprint(key) + "&sort=trending"
 
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
 		// Make the GET request
-		res, err := http.Get(url)
+		res, err := apiGet(req)
 		if err != nil {
-			fmt.Println("Error: failed to create connection to remote host", err)
-			os.Exit(1)
+			printError("failed to create connection to remote host: %v", err)
+			exit(1)
 		}
 		defer res.Body.Close()
 
@@ -43,39 +68,100 @@ providing inspiration for your next project.`,
 			// Read the response body
 			body, err := io.ReadAll(res.Body)
 			if err != nil {
-				fmt.Println("Error: Could not read response body", err)
-				os.Exit(1)
+				printError("could not read response body: %v", err)
+				exit(1)
 			}
 
 			// parse the response body into the FontList object struct
 			var listResponse FontList
 			err = json.Unmarshal(body, &listResponse)
 			if err != nil {
-				fmt.Println("Error: could not parse json response", err)
-				os.Exit(1)
+				printError("could not parse json response: %v", err)
+				exit(1)
 			}
 
-			// Grab the first 10 items
-			for i, font := range listResponse.Items {
-				if i >= 10 {
-					break
-				}
-				parsedFontFamily := parseFontFamily(font.Family)
-				fontUrl := "https://fonts.google.com/?query=" + parsedFontFamily
-				fmt.Println(font.Family + ": " + fontUrl)
-			}
+			printTrending(listResponse.Items)
 		} else if res.StatusCode == 400 {
-			fmt.Println("Error: Could not complete request")
-			os.Exit(1)
+			printError("could not complete request")
+			exit(1)
 			return
 		} else {
-			fmt.Println("An unexpected error occured")
-			os.Exit(1)
+			printError("an unexpected error occured")
+			exit(1)
 			return
 		}
 	},
 }
 
+// listFamily prints the variants and subsets of a single family
+func listFamily(fontFamily string) {
+	fontResponse := getFontUrl(parseFontFamily(fontFamily))
+	if len(fontResponse.Items) < 1 {
+		printError("could not find specified font: %s", fontFamily)
+		exit(1)
+	}
+	info := familyInfo(fontResponse.Items[0])
+	if ListJSON {
+		printJSON(info)
+		return
+	}
+	fmt.Println(info.Family)
+	fmt.Println("Variants:", strings.Join(info.Variants, ", "))
+	fmt.Println("Subsets:", strings.Join(info.Subsets, ", "))
+}
+
+func familyInfo(item FontItem) FamilyInfo {
+	info := FamilyInfo{Family: item.Family, Variants: item.Variants, Subsets: item.Subsets}
+	// keep the JSON output arrays even when the API omits a field
+	if info.Variants == nil {
+		info.Variants = []string{}
+	}
+	if info.Subsets == nil {
+		info.Subsets = []string{}
+	}
+	return info
+}
+
+func printJSON(v any) {
+	encodeJSON(os.Stdout, v)
+}
+
+// encodeJSON writes v to w as indented JSON, the encoding of every --json
+// output
+func encodeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		printError("could not encode json: %v", err)
+		exit(1)
+	}
+}
+
+// printTrending prints the first 10 items, which are sorted by trend
+func printTrending(items []FontItem) {
+	if ListJSON {
+		infos := []FamilyInfo{}
+		for i, font := range items {
+			if i >= 10 {
+				break
+			}
+			infos = append(infos, familyInfo(font))
+		}
+		printJSON(infos)
+		return
+	}
+	for i, font := range items {
+		if i >= 10 {
+			break
+		}
+		parsedFontFamily := parseFontFamily(font.Family)
+		fontUrl := "https://fonts.google.com/?query=" + parsedFontFamily
+		fmt.Println(font.Family + ": " + fontUrl)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolVar(&ListJSON, "json", false, "Output the listed fonts as JSON")
 }
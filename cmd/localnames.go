@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"os"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// localSrcs are the local() srcs naming the font in v's file by its full
+// and PostScript names, with local_postscript. Files that can't be read,
+// such as those a --dry-run didn't download, get none.
+func (in *installer) localSrcs(v *LockedVariant) []fontSrc {
+	if !in.cfg.LocalPostscript || v == nil || v.Remote || v.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(in.filePath(v.File))
+	if err != nil {
+		return nil
+	}
+	names, err := fontLocalNames(data)
+	if err != nil {
+		printWarning("could not read the name table of %s: %v", v.File, err)
+		return nil
+	}
+	srcs := make([]fontSrc, len(names))
+	for i, name := range names {
+		srcs[i] = fontSrc{Local: name}
+	}
+	return srcs
+}
+
+// fontLocalNames returns the full name and the PostScript name of a
+// woff2, ttf or otf font, without names that are missing, repeated or
+// unsafe to quote in CSS
+func fontLocalNames(data []byte) ([]string, error) {
+	data, err := sfntData(data)
+	if err != nil {
+		return nil, err
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, id := range []sfnt.NameID{sfnt.NameIDFull, sfnt.NameIDPostScript} {
+		name, err := f.Name(nil, id)
+		if err != nil || !localNameSafe(name) || (len(names) > 0 && names[0] == name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// localNameSafe reports whether name can be quoted in local('...') as is
+func localNameSafe(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || r == '\'' || r == '\\' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FontsLock records the files written by the last successful install so
+// unchanged entries can be skipped without touching the network.
+// It is stored next to the config file, e.g. fonts.yaml -> fonts.lock
+type FontsLock struct {
+	// ConfigHash identifies the resolved config the lock was written for
+	ConfigHash string `json:"config_hash,omitempty"`
+	// CatalogRevision is the newest catalog date of the locked families
+	CatalogRevision string `json:"catalog_revision,omitempty"`
+	// CriticalStylesheet is the critical stylesheet the install wrote, so
+	// it can be removed once the config no longer names it
+	CriticalStylesheet string                 `json:"critical_stylesheet,omitempty"`
+	Fonts              map[string]*LockedFont `json:"fonts"`
+}
+
+// LockedFont is keyed in FontsLock by the family name as written in the config
+type LockedFont struct {
+	Family   string                    `json:"family"`
+	Variants map[string]*LockedVariant `json:"variants"`
+	// License is the family's license file, relative to dir
+	License string `json:"license,omitempty"`
+	// Version and LastModified are the family's catalog revision when it was
+	// resolved, used to tell when the catalog has advanced
+	Version      string `json:"version,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// Category is the family's catalog category, which picks the generic
+	// family of its css_variables fallback stack
+	Category string `json:"category,omitempty"`
+}
+
+type LockedVariant struct {
+	File   string `json:"file"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	// Text is set for files subsetted to a text
+	Text string `json:"text,omitempty"`
+	// WOFF is the woff converted from File with convert_woff
+	WOFF string `json:"woff,omitempty"`
+	// Fallback is the static file installed alongside a variable font
+	Fallback *LockedVariant `json:"fallback,omitempty"`
+	// Source is the substitute variant whose file was installed, if any
+	Source string `json:"source,omitempty"`
+	// Slant is the oblique range of a variable file drawing both the
+	// upright and italic variants, see combined_italic
+	Slant string `json:"slant,omitempty"`
+	// Mirror is the mirror that served the file when the provider failed
+	Mirror string `json:"mirror,omitempty"`
+	// Shared is set when File is another variant's file with the same
+	// content, stored once with --flat-stylesheet
+	Shared bool `json:"shared,omitempty"`
+	// Integrity is the file's subresource integrity value, e.g.
+	// sha384-..., recorded with sri
+	Integrity string `json:"integrity,omitempty"`
+	// Remote is set for fonts that aren't self-hosted, whose stylesheet
+	// rule points at URL and which have no File
+	Remote bool `json:"remote,omitempty"`
+}
+
+func lockPath(configPath string) string {
+	return strings.TrimSuffix(configPath, filepath.Ext(configPath)) + ".lock"
+}
+
+// readLock returns an empty lock when the file does not exist yet
+func readLock(path string) (*FontsLock, error) {
+	lock := &FontsLock{Fonts: map[string]*LockedFont{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, err
+	}
+	if lock.Fonts == nil {
+		lock.Fonts = map[string]*LockedFont{}
+	}
+	return lock, nil
+}
+
+func writeLock(path string, lock *FontsLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	if Staged {
+		return writeFileAtomic(path, append(data, '\n'))
+	}
+	return writeOutput(path, append(data, '\n'))
+}
+
+// configHash hashes the resolved config together with the provider, the
+// hermes version and the flags that change the output outside the config,
+// so upgrading or passing any of them also counts as a change
+func configHash(cfg *FontsYAML) (string, error) {
+	type outputFlags struct {
+		ComputeMetrics, FlatStylesheet, FontMetadata, NoHeader bool
+	}
+	data, err := json.Marshal(struct {
+		Provider string
+		Version  string
+		Config   *FontsYAML
+		Flags    outputFlags
+	}{webfontsAPI, Version, cfg, outputFlags{ComputeMetrics, FlatStylesheet, FontMetadata, NoHeader}})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// filesPresent reports whether every file recorded in the lock exists in dir
+func (l *FontsLock) filesPresent(dir string) bool {
+	for _, font := range l.Fonts {
+		for _, v := range font.Variants {
+			for ; v != nil; v = v.Fallback {
+				if v.Remote {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(dir, v.File)); err != nil {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// upToDate reports whether the locked variant's file exists in dir with the recorded checksum
+func (v *LockedVariant) upToDate(dir string) bool {
+	if v == nil || v.SHA256 == "" {
+		return false
+	}
+	sum, err := fileSHA256(filepath.Join(dir, v.File))
+	return err == nil && sum == v.SHA256
+}
+
+func fileSHA256(path string) (string, error) {
+	h, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile returns a SHA-256 hash that has read the file at path, for
+// more bytes to be added to
+func hashFile(path string) (hash.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestFormat sets the layout and fields of the manifest.
+// Example:
+//
+//	manifest: ./webfonts/manifest.json
+//	manifest_format:
+//	  style: compact
+//	  checksums: false
+type ManifestFormat struct {
+	// Style is indented, the default, or compact for a single line
+	Style string `yaml:"style,omitempty"`
+	// URLs and Checksums include each file's source url and sha256. Both
+	// default to true
+	URLs      *bool `yaml:"urls,omitempty"`
+	Checksums *bool `yaml:"checksums,omitempty"`
+}
+
+func (f *ManifestFormat) validate() error {
+	if f.Style != "" && f.Style != "indented" && f.Style != "compact" {
+		return fmt.Errorf("`manifest_format.style` must be indented or compact, got %q", f.Style)
+	}
+	return nil
+}
+
+// Manifest lists the installed font files for other tools, e.g. to
+// preload them or audit where they came from
+type Manifest struct {
+	Fonts []ManifestFont `json:"fonts"`
+	// Preconnect lists the origins of the fonts that aren't self-hosted,
+	// with resource_hints
+	Preconnect []string `json:"preconnect,omitempty"`
+}
+
+// ManifestFont is one installed family of the manifest
+type ManifestFont struct {
+	Family string         `json:"family"`
+	Files  []ManifestFile `json:"files"`
+}
+
+// ManifestFile is one installed file. File is empty for fonts that aren't
+// self-hosted.
+type ManifestFile struct {
+	Variant string `json:"variant"`
+	File    string `json:"file,omitempty"`
+	URL     string `json:"url,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	// Integrity is the file's integrity attribute value, with sri
+	Integrity string `json:"integrity,omitempty"`
+}
+
+// buildManifest lists the locked fonts by family, with the fields format
+// leaves in
+func buildManifest(fonts map[string]*LockedFont, format ManifestFormat) Manifest {
+	manifest := Manifest{Fonts: []ManifestFont{}}
+	for _, font := range fonts {
+		mf := ManifestFont{Family: font.Family, Files: []ManifestFile{}}
+		for key, v := range font.Variants {
+			for ; v != nil; v = v.Fallback {
+				file := ManifestFile{Variant: key, File: v.File, Integrity: v.Integrity}
+				if format.URLs == nil || *format.URLs {
+					file.URL = v.URL
+				}
+				if format.Checksums == nil || *format.Checksums {
+					file.SHA256 = v.SHA256
+				}
+				mf.Files = append(mf.Files, file)
+			}
+		}
+		sort.Slice(mf.Files, func(i, j int) bool {
+			a, b := mf.Files[i], mf.Files[j]
+			if a.Variant != b.Variant {
+				return a.Variant < b.Variant
+			}
+			return a.File < b.File
+		})
+		manifest.Fonts = append(manifest.Fonts, mf)
+	}
+	sort.Slice(manifest.Fonts, func(i, j int) bool { return manifest.Fonts[i].Family < manifest.Fonts[j].Family })
+	return manifest
+}
+
+// writeManifest writes the JSON manifest of the locked fonts to path,
+// with the origins to preconnect to
+func writeManifest(path string, fonts map[string]*LockedFont, format *ManifestFormat, preconnect []string) error {
+	if format == nil {
+		format = &ManifestFormat{}
+	}
+	manifest := buildManifest(fonts, *format)
+	manifest.Preconnect = preconnect
+	var data []byte
+	var err error
+	if format.Style == "compact" {
+		data, err = json.Marshal(manifest)
+	} else {
+		data, err = json.MarshalIndent(manifest, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeOutput(path, append(data, '\n'))
+}
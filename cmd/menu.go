@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// kindMenu marks a family's menu font file
+const kindMenu = "menu"
+
+// MenuOptions configures the menu fonts written by hermes menu.
+// Example:
+//
+//	menu:
+//	  dir: "./picker/fonts"
+//	  stylesheet: "./picker/fonts.css"
+type MenuOptions struct {
+	// Dir and Stylesheet work like the top-level fields, and must differ
+	// from them so menu fonts never mix with the installed fonts
+	Dir        string `yaml:"dir"`
+	Stylesheet string `yaml:"stylesheet"`
+	// BaseURL is prepended to file names in the menu stylesheet's src urls
+	BaseURL string `yaml:"base_url,omitempty"`
+}
+
+func (o *MenuOptions) validate(cfg *FontsYAML) error {
+	if o.Dir == "" || o.Stylesheet == "" {
+		return fmt.Errorf("`menu` needs a `dir` and a `stylesheet`")
+	}
+	if filepath.Clean(o.Dir) == filepath.Clean(cfg.Dir) {
+		return fmt.Errorf("`menu.dir` must differ from `dir`")
+	}
+	if filepath.Clean(o.Stylesheet) == filepath.Clean(cfg.Stylesheet) {
+		return fmt.Errorf("`menu.stylesheet` must differ from `stylesheet`")
+	}
+	return nil
+}
+
+var menuCmd = &cobra.Command{
+	Use:   "menu [config]",
+	Short: "Download each family's menu font for font pickers",
+	Long: `Downloads the menu font of every family in the config, a tiny subset
+holding just the glyphs of the family's own name, as used to render names in
+font pickers. The files and their stylesheet go to the config's menu dir and
+stylesheet, apart from the installed fonts; neither the main stylesheet nor
+the lock file is touched.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if cfg.Menu == nil {
+			printError("no `menu` section in %s, add one with a dir and stylesheet", configPath)
+			exit(1)
+		}
+		signer, err := cfg.urlSigner()
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		menu := cfg.Menu
+		if err := os.MkdirAll(menu.Dir, 0755); err != nil {
+			printError("failed to create directory %s: %v", menu.Dir, err)
+			exit(1)
+		}
+		if err := os.MkdirAll(filepath.Dir(menu.Stylesheet), 0755); err != nil {
+			printError("failed to create directory %s: %v", menu.Stylesheet, err)
+			exit(1)
+		}
+		rules := []string{}
+		wanted := map[string]struct{}{}
+		seen := map[string]bool{}
+		for _, entry := range cfg.Fonts {
+			if entry.CSSURL != "" {
+				printWarning("%s is not a Google Fonts family, it has no menu font", entry.CSSURL)
+				continue
+			}
+			if seen[entry.key()] {
+				continue
+			}
+			seen[entry.key()] = true
+			item, err := googleResolver{}.Resolve(entry.Family)
+			if errors.Is(err, errFontNotFound) {
+				printWarning("no font found for %s", entry.Family)
+				continue
+			}
+			if err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+			if item.Menu == "" {
+				printWarning("%s has no menu font", item.Family)
+				continue
+			}
+			fileName, err := renderFileName(cfg.naming, item.Family, "regular", kindMenu)
+			if err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+			path := filepath.Join(menu.Dir, fileName)
+			if _, _, err := downloadFromMirrors(item.Menu, cfg.Mirrors, path, downloadOptions{contentTypes: cfg.fontContentTypes(), signer: signer, verbose: true}); err != nil {
+				printError("failed to download the menu font of %s: %v", item.Family, err)
+				exit(1)
+			}
+			if err := verifyWOFF2(path, DeepVerify); err != nil {
+				os.Remove(path)
+				printError("downloaded file failed verification: %v", err)
+				exit(1)
+			}
+			printSuccess("Downloaded", "%s (menu) -> %s", item.Family, path)
+			wanted[fileName] = struct{}{}
+			src := fileName
+			if menu.BaseURL != "" {
+				src = strings.TrimSuffix(menu.BaseURL, "/") + "/" + fileName
+			}
+			rules = append(rules, genCSS(cfg.cssFamily(item.Family), "regular", []fontSrc{{URL: src, Format: "woff2"}}))
+		}
+		if cfg.clean() {
+			removeUnreferencedFiles(menu.Dir, wanted, nil, true)
+		}
+		header, err := stylesheetHeader(cfg, time.Now())
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if err := writeCSS(menu.Stylesheet, cfg.cssCharset(), renderCSS(header, cfg.CSSLayer, rules)); err != nil {
+			printError("failed to write %s: %v", menu.Stylesheet, err)
+			exit(1)
+		}
+		printSuccess("Wrote", "%s with %d menu fonts", menu.Stylesheet, len(rules))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(menuCmd)
+
+	menuCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	menuCmd.Flags().BoolVar(&NoHeader, "no-header", false, "Leave out the generated-file banner at the top of the stylesheet")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// cachedMetadata is a Developer API response kept with its validators, so
+// a later lookup of the same query can ask the API whether it changed and
+// skip the full response when it hasn't
+type cachedMetadata struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// metadataCachePath names the cache file of a query. The API key is not
+// part of the query, so it never ends up on disk.
+func metadataCachePath(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return filepath.Join(cacheDir(), "metadata", hex.EncodeToString(sum[:8])+".json")
+}
+
+// readCachedMetadata returns nil when query has no usable cache entry,
+// making the lookup a full fetch
+func readCachedMetadata(query string) *cachedMetadata {
+	data, err := os.ReadFile(metadataCachePath(query))
+	if err != nil {
+		return nil
+	}
+	var cached cachedMetadata
+	if err := json.Unmarshal(data, &cached); err != nil || len(cached.Body) == 0 || cached.ETag == "" && cached.LastModified == "" {
+		return nil
+	}
+	return &cached
+}
+
+// setConditional makes req conditional on the cached response's validators
+func (c *cachedMetadata) setConditional(req *http.Request) {
+	if c.ETag != "" {
+		req.Header.Set("If-None-Match", c.ETag)
+	}
+	if c.LastModified != "" {
+		req.Header.Set("If-Modified-Since", c.LastModified)
+	}
+}
+
+// writeCachedMetadata caches a response that carries validators. The cache
+// only saves bandwidth, so failing to write it is not an error.
+func writeCachedMetadata(query string, res *http.Response, body []byte) {
+	cached := cachedMetadata{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified"), Body: body}
+	if cached.ETag == "" && cached.LastModified == "" {
+		return
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	path := metadataCachePath(query)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	writeFileAtomic(path, data)
+}
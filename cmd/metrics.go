@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// flag variables
+var ComputeMetrics bool
+
+// FontMetrics are the size-adjust and metric overrides of a font's own
+// rules, as percentages of the font size written as plain numbers, e.g.
+//
+//	metrics:
+//	  ascent: 92.8
+//	  descent: 24.4
+//	  line_gap: 0
+//
+// Pinning them makes the font lay out lines the same on every platform.
+// With install --compute-metrics, those left out are read from each
+// downloaded file's hhea and OS/2 tables.
+type FontMetrics struct {
+	SizeAdjust *float64 `yaml:"size_adjust,omitempty"`
+	Ascent     *float64 `yaml:"ascent,omitempty"`
+	Descent    *float64 `yaml:"descent,omitempty"`
+	LineGap    *float64 `yaml:"line_gap,omitempty"`
+}
+
+func (m *FontMetrics) validate() error {
+	if m.SizeAdjust != nil && *m.SizeAdjust <= 0 {
+		return fmt.Errorf("`metrics.size_adjust` must be positive, e.g. 100.3")
+	}
+	for _, f := range []struct {
+		name  string
+		value *float64
+	}{{"ascent", m.Ascent}, {"descent", m.Descent}, {"line_gap", m.LineGap}} {
+		if f.value != nil && *f.value < 0 {
+			return fmt.Errorf("`metrics.%s` cannot be negative", f.name)
+		}
+	}
+	return nil
+}
+
+// maxMetricPrecision is the most decimals metric_precision may ask for,
+// past what font units can tell apart
+const maxMetricPrecision = 6
+
+// metricPrecision is the number of decimals of the metric overrides
+func (cfg *FontsYAML) metricPrecision() int {
+	if cfg.MetricPrecision == nil {
+		return 2
+	}
+	return *cfg.MetricPrecision
+}
+
+// cssPercent renders a metric as a CSS percentage, rounded to precision
+// decimals without trailing zeros, e.g. 106.88%
+func cssPercent(value *float64, precision int) string {
+	if value == nil {
+		return ""
+	}
+	scale := math.Pow(10, float64(precision))
+	return strconv.FormatFloat(math.Round(*value*scale)/scale, 'f', -1, 64) + "%"
+}
+
+// addMetrics adds the entry's metric overrides to a rule for v, filling
+// those it leaves out from v's file with --compute-metrics
+func (in *installer) addMetrics(rule string, entry FontEntry, v *LockedVariant) string {
+	for _, d := range in.metricDescriptors(entry, v) {
+		rule = addDescriptor(rule, d[0], d[1])
+	}
+	return rule
+}
+
+// setMetrics is addMetrics for the body of a provider's rule, such as one
+// of a css_url stylesheet, replacing the overrides it declares itself
+func (in *installer) setMetrics(body string, entry FontEntry, v *LockedVariant) string {
+	for _, d := range in.metricDescriptors(entry, v) {
+		if d[1] != "" {
+			body = setDescriptor(body, d[0], d[1])
+		}
+	}
+	return body
+}
+
+// metricDescriptors are the names and values of the metric overrides of
+// entry's rule for v, with empty values for those it has none of
+func (in *installer) metricDescriptors(entry FontEntry, v *LockedVariant) [][2]string {
+	var m FontMetrics
+	if entry.Metrics != nil {
+		m = *entry.Metrics
+	}
+	if ComputeMetrics && (m.Ascent == nil || m.Descent == nil || m.LineGap == nil) {
+		if computed, ok := in.fileMetrics(v); ok {
+			if m.Ascent == nil {
+				m.Ascent = computed.Ascent
+			}
+			if m.Descent == nil {
+				m.Descent = computed.Descent
+			}
+			if m.LineGap == nil {
+				m.LineGap = computed.LineGap
+			}
+		}
+	}
+	precision := in.cfg.metricPrecision()
+	return [][2]string{
+		{"size-adjust", cssPercent(m.SizeAdjust, precision)},
+		{"ascent-override", cssPercent(m.Ascent, precision)},
+		{"descent-override", cssPercent(m.Descent, precision)},
+		{"line-gap-override", cssPercent(m.LineGap, precision)},
+	}
+}
+
+// fileMetrics reads the line metrics of v's file. Files that can't be
+// read, such as those a --dry-run didn't download, have none.
+func (in *installer) fileMetrics(v *LockedVariant) (FontMetrics, bool) {
+	if v == nil || v.Remote || v.File == "" {
+		return FontMetrics{}, false
+	}
+	data, err := os.ReadFile(in.filePath(v.File))
+	if err != nil {
+		return FontMetrics{}, false
+	}
+	m, err := fontLineMetrics(data)
+	if err != nil {
+		printWarning("could not compute the metrics of %s: %v", v.File, err)
+		return FontMetrics{}, false
+	}
+	return m, true
+}
+
+// fontLineMetrics computes the ascent, descent and line gap of a woff2,
+// ttf or otf font as percentages of its em. They come from the hhea table,
+// or from OS/2's typographic metrics when its USE_TYPO_METRICS bit is set,
+// as browsers read them.
+func fontLineMetrics(data []byte) (FontMetrics, error) {
+	data, err := sfntData(data)
+	if err != nil {
+		return FontMetrics{}, err
+	}
+	head, hhea, os2 := sfntTableData(data, "head"), sfntTableData(data, "hhea"), sfntTableData(data, "OS/2")
+	if len(head) < 20 || len(hhea) < 10 {
+		return FontMetrics{}, fmt.Errorf("the font has no head or hhea table")
+	}
+	unitsPerEm := float64(binary.BigEndian.Uint16(head[18:]))
+	if unitsPerEm == 0 {
+		return FontMetrics{}, fmt.Errorf("the font's unitsPerEm is 0")
+	}
+	metrics := hhea[4:10]
+	const useTypoMetrics = 1 << 7
+	if len(os2) >= 74 && binary.BigEndian.Uint16(os2[62:])&useTypoMetrics != 0 {
+		metrics = os2[68:74]
+	}
+	// the overrides can't be negative: the descender, below the baseline,
+	// is negative, though some fonts store its size, and a negative
+	// ascender or line gap is taken as none
+	percent := func(units int32) *float64 {
+		p := float64(max(units, 0)) / unitsPerEm * 100
+		return &p
+	}
+	field := func(off int) int32 { return int32(int16(binary.BigEndian.Uint16(metrics[off:]))) }
+	descender := field(2)
+	if descender < 0 {
+		descender = -descender
+	}
+	return FontMetrics{
+		Ascent:  percent(field(0)),
+		Descent: percent(descender),
+		LineGap: percent(field(4)),
+	}, nil
+}
+
+// sfntTableData finds a table in an sfnt font, or nil when it has none
+func sfntTableData(sfnt []byte, tag string) []byte {
+	if len(sfnt) < 12 {
+		return nil
+	}
+	n := int(binary.BigEndian.Uint16(sfnt[4:]))
+	for i := 0; i < n && 12+16*(i+1) <= len(sfnt); i++ {
+		rec := sfnt[12+16*i:]
+		if string(rec[:4]) != tag {
+			continue
+		}
+		offset, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		if uint64(offset)+uint64(length) > uint64(len(sfnt)) {
+			return nil
+		}
+		return sfnt[offset : offset+length]
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configVersion is the schema version hermes writes and reads
+const configVersion = 1
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [config]",
+	Short: "Rewrite a config in the current canonical form",
+	Long: `Rewrites a config in the canonical form of the current schema: fields in
+schema order, variant tokens normalized (400 becomes regular, 700i becomes
+700italic) and the schema version filled in. Comments and values are kept.
+Unknown or misplaced fields are reported rather than dropped.
+The original is backed up next to it as <config>.bak.
+
+Only what the config sets is rewritten: defaults are left unfilled and
+presets, environments and flags unapplied, so the result differs from
+install --print-config, which prints the config as resolved for a run.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := configFile(args)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			printError("could not read %s: %v", path, err)
+			exit(1)
+		}
+		migrated, err := migrateConfig(data)
+		if err != nil {
+			printError("cannot migrate %s: %v", path, err)
+			exit(1)
+		}
+		if bytes.Equal(migrated, data) {
+			printSuccess("Up to date", "%s is already in canonical form", path)
+			return
+		}
+		backup := path + ".bak"
+		if err := os.WriteFile(backup, data, 0644); err != nil {
+			printError("failed to back up %s: %v", path, err)
+			exit(1)
+		}
+		if err := writeFileAtomic(path, migrated); err != nil {
+			printError("failed to write %s: %v", path, err)
+			exit(1)
+		}
+		printSuccess("Migrated", "%s, the original is saved as %s", path, backup)
+	},
+}
+
+// migrateConfig rewrites a config in canonical form. It works on the yaml
+// node tree rather than FontsYAML so comments and quoting survive.
+func migrateConfig(data []byte) ([]byte, error) {
+	// a strict decode catches fields the schema doesn't know, which a
+	// rewrite would otherwise carry over silently
+	var cfg FontsYAML
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Version > configVersion {
+		return nil, fmt.Errorf("config schema version %d is newer than this hermes supports (%d)", cfg.Version, configVersion)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config is not a mapping")
+	}
+	root := doc.Content[0]
+	setMappingValue(root, "version", strconv.Itoa(configVersion))
+	canonicalize(root, reflect.TypeOf(cfg))
+	if fonts := mappingValue(root, "fonts"); fonts != nil {
+		for _, entry := range fonts.Content {
+			if variants := mappingValue(entry, "variants"); variants != nil {
+				normalizeVariants(variants)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalize orders the keys of a mapping node by the field order of the
+// struct type it decodes into, recursing into nested structs
+func canonicalize(node *yaml.Node, t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		canonicalize(node, t.Elem())
+		return
+	case reflect.Slice:
+		if node.Kind == yaml.SequenceNode {
+			for _, item := range node.Content {
+				canonicalize(item, t.Elem())
+			}
+		}
+		return
+	case reflect.Map:
+		if node.Kind == yaml.MappingNode {
+			for i := 1; i < len(node.Content); i += 2 {
+				canonicalize(node.Content[i], t.Elem())
+			}
+		}
+		return
+	}
+	if t.Kind() != reflect.Struct || node.Kind != yaml.MappingNode {
+		return
+	}
+	fields := map[string]reflect.StructField{}
+	order := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f
+		order[name] = i
+	}
+	type pair struct{ key, value *yaml.Node }
+	pairs := make([]pair, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, pair{node.Content[i], node.Content[i+1]})
+	}
+	// a comment above the first key belongs to the whole mapping
+	var head string
+	if len(pairs) > 0 {
+		head, pairs[0].key.HeadComment = pairs[0].key.HeadComment, ""
+	}
+	slices.SortStableFunc(pairs, func(a, b pair) int { return order[a.key.Value] - order[b.key.Value] })
+	if len(pairs) > 0 && head != "" {
+		pairs[0].key.HeadComment = strings.TrimSpace(head + "\n" + pairs[0].key.HeadComment)
+	}
+	node.Content = node.Content[:0]
+	for _, p := range pairs {
+		node.Content = append(node.Content, p.key, p.value)
+		if f, ok := fields[p.key.Value]; ok {
+			canonicalize(p.value, f.Type)
+		}
+	}
+}
+
+// normalizeVariants rewrites variant tokens in the Developer API form and
+// drops repeats. Tokens that don't parse are left for validation to report.
+func normalizeVariants(seq *yaml.Node) {
+	if seq.Kind != yaml.SequenceNode {
+		return
+	}
+	seen := map[string]bool{}
+	kept := seq.Content[:0]
+	for _, n := range seq.Content {
+		if n.Kind == yaml.ScalarNode {
+			if v, err := parseCSSVariants(n.Value); err == nil && len(v) == 1 && n.Value != "" {
+				n.Value, n.Tag = v[0], ""
+			}
+			if seen[n.Value] {
+				continue
+			}
+			seen[n.Value] = true
+		}
+		kept = append(kept, n)
+	}
+	seq.Content = kept
+}
+
+// mappingValue returns the value node of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key to a scalar value, adding the key when missing
+func setMappingValue(node *yaml.Node, key, value string) {
+	if v := mappingValue(node, key); v != nil {
+		v.Kind, v.Tag, v.Value, v.Style = yaml.ScalarNode, "", value, 0
+		return
+	}
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: value})
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
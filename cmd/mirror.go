@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+func validateMirrors(mirrors []string) error {
+	for _, m := range mirrors {
+		u, err := url.Parse(m)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid mirror %q (expected an http or https base url)", m)
+		}
+	}
+	return nil
+}
+
+// mirrorURL rewrites src to be served from the mirror base, keeping its path
+// and query, e.g. https://fonts.gstatic.com/s/a.woff2 with the mirror
+// https://mirror.example.com/gstatic becomes https://mirror.example.com/gstatic/s/a.woff2
+func mirrorURL(src, mirror string) (string, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	base, err := url.Parse(mirror)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	u.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// downloadFromMirrors downloads src, failing over to each mirror in order when
+// the provider's host fails. It returns the mirror that served the file, or
+// an empty string when the provider did, and the file's SHA-256.
+func downloadFromMirrors(src string, mirrors []string, filePath string, opts downloadOptions) (string, string, error) {
+	sum, err := downloadToFile(src, filePath, opts)
+	if err == nil {
+		return "", sum, nil
+	}
+	errs := []error{err}
+	for _, mirror := range mirrors {
+		if opts.verbose {
+			printStatus(colorYellow, "Retrying", "download of %s failed, trying mirror %s", src, mirror)
+		}
+		u, err := mirrorURL(src, mirror)
+		if err == nil {
+			sum, err = downloadToFile(u, filePath, opts)
+		}
+		if err == nil {
+			return mirror, sum, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", mirror, err))
+	}
+	return "", "", errors.Join(errs...)
+}
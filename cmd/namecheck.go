@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// flag variables
+var VerifyFamilyName bool
+
+// checkFamilyName compares the family the downloaded file fileName, at
+// filePath, names in its name table with the one requested, with
+// --verify-family-name, to catch a mirror or provider serving the wrong
+// font under a variant's url
+func checkFamilyName(family, fileName, filePath string) error {
+	if !VerifyFamilyName {
+		return nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	names, err := fontFamilyNames(data)
+	if err != nil {
+		return fmt.Errorf("could not read the name table of %s: %w", fileName, err)
+	}
+	if names.typographic == "" && names.legacy == "" {
+		return fmt.Errorf("%s names no family in its name table", fileName)
+	}
+	want := familyNameKey(family)
+	if names.typographic != "" {
+		// the whole family, e.g. Roboto, never Roboto Slab
+		if familyNameKey(names.typographic) == want {
+			return nil
+		}
+		return fmt.Errorf("%s was requested as %q, but its name table names the family %q", fileName, family, names.typographic)
+	}
+	// the legacy name of a static file may name its style too, e.g. Open
+	// Sans SemiBold
+	if strings.HasPrefix(familyNameKey(names.legacy), want) {
+		return nil
+	}
+	return fmt.Errorf("%s was requested as %q, but its name table names the family %q", fileName, family, names.legacy)
+}
+
+// familyNames are the family names of a font's name table
+type familyNames struct {
+	// typographic is name ID 16, set by fonts whose legacy name includes
+	// a style
+	typographic string
+	// legacy is name ID 1
+	legacy string
+}
+
+// fontFamilyNames returns the typographic and legacy family names of a
+// woff2, woff, ttf or otf font, which are empty when missing
+func fontFamilyNames(data []byte) (familyNames, error) {
+	data, err := sfntData(data)
+	if err != nil {
+		return familyNames{}, err
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return familyNames{}, err
+	}
+	var names familyNames
+	names.typographic, _ = f.Name(nil, sfnt.NameIDTypographicFamily)
+	names.legacy, _ = f.Name(nil, sfnt.NameIDFamily)
+	return names, nil
+}
+
+// familyNameKey folds a family name for a rough comparison, ignoring case,
+// spaces and punctuation, so Open Sans matches OpenSans
+func familyNameKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
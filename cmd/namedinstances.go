@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// namedInstance is one of the instances a variable font's fvar table
+// names, e.g. Condensed Bold, at its axis coordinates
+type namedInstance struct {
+	name   string
+	coords []axisCoord
+}
+
+type axisCoord struct {
+	tag   string
+	value float64
+}
+
+// namedInstanceRules renders a rule of its own for each named instance of
+// the variable font in v's file, with named_instances. Each is a copy of
+// rule under the family "<family> <instance>", pinned to the instance with
+// font-variation-settings and font-named-instance. Files that can't be
+// read, such as those a --dry-run didn't download, have none.
+func (in *installer) namedInstanceRules(entry FontEntry, family, rule string, v *LockedVariant) []string {
+	if !entry.NamedInstances || v == nil || v.Remote || v.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(in.filePath(v.File))
+	if err != nil {
+		return nil
+	}
+	instances, err := fontNamedInstances(data)
+	if err != nil {
+		familyWarning(entry.name(), "could not read the named instances of %s: %v", v.File, err)
+		return nil
+	}
+	if len(instances) == 0 {
+		familyWarning(entry.name(), "%s defines no named instances, it may not be a variable font", v.File)
+		return nil
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(rule, "@font-face {"), "}")
+	rules := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		b := setDescriptor(body, "font-family", fmt.Sprintf("'%s %s'", family, inst.name))
+		settings := make([]string, len(inst.coords))
+		for i, c := range inst.coords {
+			settings[i] = fmt.Sprintf("'%s' %s", c.tag, formatAxisValue(c.value))
+			switch {
+			case c.tag == "wght":
+				b = setDescriptor(b, "font-weight", formatAxisValue(c.value))
+			case c.tag == "wdth":
+				b = setDescriptor(b, "font-stretch", formatAxisValue(c.value)+"%")
+			case c.tag == "slnt" && c.value != 0:
+				// the slnt axis slants the opposite way to oblique
+				b = setDescriptor(b, "font-style", "oblique "+formatAxisValue(-c.value)+"deg")
+			}
+		}
+		b = setDescriptor(b, "font-variation-settings", strings.Join(settings, ", "))
+		b = setDescriptor(b, "font-named-instance", "'"+inst.name+"'")
+		rules = append(rules, "@font-face {"+b+"}")
+	}
+	return rules
+}
+
+// fontNamedInstances reads the named instances of a woff2, ttf or otf
+// variable font from its fvar table, with their names from the name
+// table. Fonts without an fvar table have none.
+// https://learn.microsoft.com/en-us/typography/opentype/spec/fvar
+func fontNamedInstances(data []byte) ([]namedInstance, error) {
+	data, err := sfntData(data)
+	if err != nil {
+		return nil, err
+	}
+	fvar := sfntTableData(data, "fvar")
+	if fvar == nil {
+		return nil, nil
+	}
+	if len(fvar) < 16 {
+		return nil, fmt.Errorf("the fvar table is truncated")
+	}
+	axesOffset := int(binary.BigEndian.Uint16(fvar[4:]))
+	axisCount := int(binary.BigEndian.Uint16(fvar[8:]))
+	axisSize := int(binary.BigEndian.Uint16(fvar[10:]))
+	instanceCount := int(binary.BigEndian.Uint16(fvar[12:]))
+	instanceSize := int(binary.BigEndian.Uint16(fvar[14:]))
+	instancesOffset := axesOffset + axisCount*axisSize
+	if axisSize < 20 || instanceSize < 4+4*axisCount || len(fvar) < instancesOffset+instanceCount*instanceSize {
+		return nil, fmt.Errorf("the fvar table is truncated")
+	}
+	tags := make([]string, axisCount)
+	for i := range tags {
+		tags[i] = string(fvar[axesOffset+i*axisSize:][:4])
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	instances := []namedInstance{}
+	for i := 0; i < instanceCount; i++ {
+		rec := fvar[instancesOffset+i*instanceSize:]
+		name, err := f.Name(nil, sfnt.NameID(binary.BigEndian.Uint16(rec)))
+		if err != nil || !localNameSafe(name) {
+			continue
+		}
+		inst := namedInstance{name: name, coords: make([]axisCoord, axisCount)}
+		for j, tag := range tags {
+			// coordinates are 16.16 fixed-point numbers
+			fixed := int32(binary.BigEndian.Uint32(rec[4+4*j:]))
+			inst.coords[j] = axisCoord{tag: tag, value: math.Round(float64(fixed)/65536*1000) / 1000}
+		}
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
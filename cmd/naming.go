@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// defaultNaming reproduces the original Family_variant.woff2 scheme
+const defaultNaming = "{{.Family}}_{{.Variant}}.{{.Ext}}"
+
+// file kinds that need a name distinct from the variant's main file
+const (
+	kindText   = "text"
+	kindStatic = "static"
+)
+
+// fileNameData is the data available to naming templates
+type fileNameData struct {
+	Family  string
+	Variant string
+	Weight  string
+	Style   string
+	Subset  string
+	Ext     string
+}
+
+var namingFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"kebab": func(s string) string { return strings.ToLower(strings.ReplaceAll(s, " ", "-")) },
+	"snake": func(s string) string { return strings.ToLower(strings.ReplaceAll(s, " ", "_")) },
+}
+
+func parseNaming(naming string) (*template.Template, error) {
+	if naming == "" {
+		naming = defaultNaming
+	}
+	tmpl, err := template.New("naming").Funcs(namingFuncs).Option("missingkey=error").Parse(naming)
+	if err != nil {
+		return nil, fmt.Errorf("invalid naming template: %v", err)
+	}
+	// render a sample so mistakes like unknown fields fail before any download
+	if _, err := renderFileName(tmpl, "Sample Family", "700italic", ""); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderFileName names a variant's file. Text subsets and static fallbacks
+// get a _text or _static suffix before the extension so they never share a
+// name with the variant's main file. Any other kind is a subset, set as
+// .Subset, and suffixed the same way unless the template uses it.
+func renderFileName(tmpl *template.Template, family, variant, kind string) (string, error) {
+	style, weight := variantStyleWeight(variant)
+	data := fileNameData{
+		Family:  family,
+		Variant: variant,
+		Weight:  weight,
+		Style:   style,
+		Ext:     "woff2",
+	}
+	name, err := executeNaming(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	if kind != "" && kind != kindText && kind != kindStatic && kind != kindMenu {
+		data.Subset = kind
+		withSubset, err := executeNaming(tmpl, data)
+		if err != nil {
+			return "", err
+		}
+		if withSubset != name {
+			return withSubset, nil
+		}
+	}
+	if kind != "" {
+		ext := "." + data.Ext
+		name = strings.TrimSuffix(name, ext) + "_" + kind + ext
+	}
+	return name, nil
+}
+
+// executeNaming renders a file name from the naming template
+func executeNaming(tmpl *template.Template, data fileNameData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid naming template: %v", err)
+	}
+	name := b.String()
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("naming template produced an invalid file name %q", name)
+	}
+	return name, nil
+}
+
+// originalFileName is the last path segment of a font url, e.g. the
+// content-hashed KFOmCnqEu92Fr1Mu4mxK.woff2 of a gstatic url
+func originalFileName(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || strings.ContainsAny(name, `\`) {
+		return "", false
+	}
+	// cleanup only manages woff2 files
+	if !strings.HasSuffix(name, ".woff2") {
+		name += ".woff2"
+	}
+	return name, true
+}
+
+// urlHash is a short, stable hash of a url for telling apart files that
+// share an original name
+func urlHash(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:4])
+}
+
+// checkFileNameCollisions renders the file name of every requested variant
+// before anything is downloaded and reports names two variants would share,
+// which would make one overwrite the other. The subsets of subsets: [all]
+// are only known after the lookup, so its latin files stand in for them.
+func checkFileNameCollisions(cfg *FontsYAML) error {
+	if cfg.KeepOriginalName {
+		// names come from the urls, which are only known after the lookup
+		return nil
+	}
+	owners := map[string]string{}
+	collisions := []string{}
+	for _, entry := range cfg.Fonts {
+		if entry.CSSURL != "" || len(entry.Axes) > 0 || !entry.selfHosted() {
+			continue
+		}
+		kinds := []string{""}
+		switch {
+		case entry.Text != "":
+			kinds = []string{kindText}
+		case len(entry.Subsets) > 0:
+			kinds = kinds[:0]
+			for i, subset := range entry.Subsets {
+				if subset == "all" {
+					subset = "latin"
+				}
+				if kind := subsetKind(subset, i); !slices.Contains(kinds, kind) {
+					kinds = append(kinds, kind)
+				}
+			}
+		case entry.wantsStaticFallback():
+			kinds = append(kinds, kindStatic)
+		}
+		for _, variant := range entry.Variants {
+			for _, kind := range kinds {
+				name, err := renderFileName(cfg.naming, entry.Family, variant, kind)
+				if err != nil {
+					return err
+				}
+				owner := fmt.Sprintf("%s (%s)", entry.Family, variant)
+				if other, ok := owners[name]; ok && other != owner {
+					collisions = append(collisions, fmt.Sprintf("%s and %s would both be saved as %s", other, owner, name))
+					continue
+				}
+				owners[name] = owner
+			}
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("file names collide, adjust naming:\n  %s", strings.Join(collisions, "\n  "))
+	}
+	return nil
+}
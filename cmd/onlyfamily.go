@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// sameFamily compares family names the way lookups normalize them
+func sameFamily(a, b string) bool {
+	return parseFontFamily(a) == parseFontFamily(b)
+}
+
+// forced reports whether family is re-downloaded regardless of the lock,
+// with --force or when it is the --only-family
+func (in *installer) forced(family string) bool {
+	return Force || (OnlyFamily != "" && sameFamily(family, OnlyFamily))
+}
+
+// validateOnlyFamily checks that --only-family names a font in the config.
+// css_url entries only learn their families once fetched, so any name is
+// accepted when the config has one.
+func validateOnlyFamily(fonts []FontEntry, family string) error {
+	if slices.ContainsFunc(fonts, func(entry FontEntry) bool {
+		return entry.CSSURL != "" || sameFamily(entry.Family, family)
+	}) {
+		return nil
+	}
+	return fmt.Errorf("--only-family %q does not match any font in the config", family)
+}
+
+// removeFamilyFiles removes the files the previous lock recorded for the
+// --only-family that this run no longer references, leaving every other
+// file in dir alone
+func removeFamilyFiles(dir string, lock *FontsLock, family string, wanted map[string]struct{}, verbose bool) {
+	ig := readHermesIgnore(dir)
+	for key, font := range lock.Fonts {
+		if !sameFamily(key, family) && !sameFamily(font.Family, family) {
+			continue
+		}
+		for _, file := range font.files() {
+			if _, ok := wanted[file]; ok || ig.ignored(file) {
+				continue
+			}
+			fullPath := filepath.Join(dir, file)
+			if _, err := os.Stat(fullPath); err != nil {
+				continue
+			}
+			if DryRun {
+				printStatus(colorCyan, "Would remove", "unreferenced font file: %s", fullPath)
+				continue
+			}
+			if verbose {
+				printStatus(colorYellow, "Removing", "unreferenced font file: %s", fullPath)
+			}
+			os.Remove(fullPath)
+		}
+	}
+}
+
+// files lists the font files and sidecars a locked font may have written
+func (f *LockedFont) files() []string {
+	files := []string{}
+	for _, v := range f.Variants {
+		for ; v != nil; v = v.Fallback {
+			if v.Remote {
+				continue
+			}
+			files = append(files, v.File)
+			for _, ext := range sidecarExts {
+				files = append(files, v.File+ext)
+			}
+		}
+	}
+	return files
+}
+
+// familyOutcomes keeps the outcomes of family
+func familyOutcomes(outcomes []variantOutcome, family string) []variantOutcome {
+	return slices.DeleteFunc(slices.Clone(outcomes), func(o variantOutcome) bool {
+		return !sameFamily(o.Family, family)
+	})
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var OpenCSS bool
+
+var openCmd = &cobra.Command{
+	Use:   "open [config]",
+	Short: "Open the fonts directory, or the stylesheet with --css",
+	Long: `Prints the config's fonts directory and opens it in the file manager, or
+with --css opens the stylesheet, using open on macOS, start on Windows and
+xdg-open elsewhere.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := loadFontsYAML(configFile(args))
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		path := cfg.Dir
+		if OpenCSS {
+			path = cfg.Stylesheet
+		}
+		if path == stdoutStylesheet {
+			printError("the stylesheet is written to stdout, there is no file to open")
+			exit(1)
+		}
+		if _, err := os.Stat(path); err != nil {
+			printError("%v, run hermes install first", err)
+			exit(1)
+		}
+		fmt.Println(path)
+		name, openArgs := opener(path)
+		if _, err := exec.LookPath(name); err != nil {
+			printError("no way to open files was found (%s is not available), open %s yourself", name, path)
+			exit(1)
+		}
+		if out, err := exec.Command(name, openArgs...).CombinedOutput(); err != nil {
+			printError("%s failed: %v %s", name, err, out)
+			exit(1)
+		}
+	},
+}
+
+// opener is the platform's command for opening path in its default app
+func opener(path string) (string, []string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open", []string{path}
+	case "windows":
+		// start is a cmd builtin; its first quoted argument is the window title
+		return "cmd", []string{"/c", "start", "", path}
+	}
+	return "xdg-open", []string{path}
+}
+
+func init() {
+	rootCmd.AddCommand(openCmd)
+
+	openCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	openCmd.Flags().BoolVar(&OpenCSS, "css", false, "Open the stylesheet instead of the fonts directory")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var OrphansJSON bool
+
+var orphansCmd = &cobra.Command{
+	Use:   "orphans [config]",
+	Short: "List the files in dir that install's cleanup would remove",
+	Long: `Lists, without deleting anything, the font files, sidecars and license
+files in dir that the current config doesn't reference, which is what
+install removes unless clean is false. The config is resolved as by
+install --dry-run, skipping the lookup of fonts the lock file shows as up
+to date, so the list matches what the next install would remove.
+--json prints the files as JSON for scripts. prune, not this command,
+removes fonts from the config along with their files.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if OrphansJSON {
+			startJSON()
+			// a run ended by an error still prints a result
+			exitHook = func() {
+				printResult("orphans", &orphansResult{Files: []string{}, Errors: errorMessages()})
+			}
+		}
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if cfg.Fonts, err = mergeDuplicateFonts(cfg.Fonts, "merge"); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		cfg.Fonts = applyOnlyVariants(cfg.Fonts, cfg.OnlyVariants)
+		lockFile := lockPath(configPath)
+		lock, err := readLock(lockFile)
+		if err != nil {
+			printError("could not read lock file %s: %v", lockFile, err)
+			exit(1)
+		}
+
+		files := orphanedFiles(cfg, lock)
+		if OrphansJSON {
+			exitHook = nil
+			printResult("orphans", &orphansResult{OK: true, Dir: cfg.Dir, Files: files, Clean: cfg.clean()})
+			return
+		}
+		if len(files) == 0 {
+			fmt.Printf("No unreferenced files in %s\n", cfg.Dir)
+			return
+		}
+		for _, f := range files {
+			fmt.Println(filepath.Join(cfg.Dir, f))
+		}
+		if !cfg.clean() {
+			fmt.Printf("\n%d unreferenced file(s); clean is false, so install leaves them\n", len(files))
+			return
+		}
+		fmt.Printf("\n%d unreferenced file(s) would be removed by install\n", len(files))
+	},
+}
+
+// dryRunInstall runs a dry-run install of cfg against lock with its output
+// discarded, for the commands reporting what install would do
+func dryRunInstall(cfg *FontsYAML, lock *FontsLock) *installer {
+	DryRun = true
+	stdout := os.Stdout
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+		defer devNull.Close()
+	}
+	in := newInstaller(cfg, lock, false)
+	in.install(cfg.Fonts)
+	os.Stdout = stdout
+	return in
+}
+
+// orphanedFiles runs a dry-run install of cfg to find the wanted files,
+// and lists the others in dir relative to it
+func orphanedFiles(cfg *FontsYAML, lock *FontsLock) []string {
+	in := dryRunInstall(cfg, lock)
+	paths, err := unreferencedFiles(cfg.Dir, in.wantedFiles, convertedWOFFs(cfg, lock))
+	if err != nil {
+		printError("failed to list %s: %v", cfg.Dir, err)
+		exit(1)
+	}
+	files := []string{}
+	for _, path := range append(paths, unreferencedLicenses(cfg.Dir, in.wantedLicenses)...) {
+		rel, err := filepath.Rel(cfg.Dir, path)
+		if err != nil {
+			rel = path
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	sort.Strings(files)
+	return files
+}
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+	orphansCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	orphansCmd.Flags().BoolVar(&OrphansJSON, "json", false, "Print the files as JSON, described by hermes schema --result orphans")
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var ColorMode string
+var Verbose int
+var WarningsAsErrors bool
+
+// ANSI escape codes used for status prefixes
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&ColorMode, "color", "auto", "When to colorize output: auto, always or never")
+	rootCmd.PersistentFlags().CountVarP(&Verbose, "verbose", "v", "Increase output detail; -vv (or --verbose=2) also logs HTTP requests")
+	rootCmd.PersistentFlags().BoolVar(&WarningsAsErrors, "warnings-as-errors", false, "Report every warning as an error and exit with status 1 once the command has finished")
+	cobra.OnInitialize(validateColor)
+}
+
+func validateColor() {
+	switch ColorMode {
+	case "auto", "always", "never":
+	default:
+		printError("invalid --color value %q (expected auto, always or never)", ColorMode)
+		exit(1)
+	}
+}
+
+// useColor reports whether output written to f should be colorized.
+// In auto mode color is only used for terminals and is disabled by NO_COLOR.
+func useColor(f *os.File) bool {
+	switch ColorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the given color when output to f supports it
+func colorize(f *os.File, color, s string) string {
+	if !useColor(f) {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// printStatus prints a line starting with a colored status prefix
+func printStatus(color, prefix, format string, a ...any) {
+	fprintStatus(os.Stdout, color, prefix, format, a...)
+}
+
+// outputMu keeps a --group-output block from being split by other lines
+var outputMu sync.Mutex
+
+func fprintStatus(f *os.File, color, prefix, format string, a ...any) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprintln(f, colorize(f, color, prefix), fmt.Sprintf(format, a...))
+}
+
+func printSuccess(prefix, format string, a ...any) {
+	printStatus(colorGreen, prefix, format, a...)
+}
+
+// printWarning reports a warning, or an error with --warnings-as-errors
+func printWarning(format string, a ...any) {
+	if WarningsAsErrors {
+		printError(format, a...)
+		return
+	}
+	printStatus(colorYellow, "Warning:", format, a...)
+}
+
+// printStderrWarning is printWarning for commands whose stdout is data
+func printStderrWarning(format string, a ...any) {
+	if WarningsAsErrors {
+		printError(format, a...)
+		return
+	}
+	fprintStatus(os.Stderr, colorYellow, "Warning:", format, a...)
+}
+
+// errorCount is the number of errors reported this run
+var errorCount atomic.Int32
+
+// reportedErrors are the messages of the errors printError reported, for
+// the results of failed runs
+var reportedErrors struct {
+	sync.Mutex
+	messages []string
+}
+
+// printError reports an error on stderr, keeping stdout for progress and data
+func printError(format string, a ...any) {
+	errorCount.Add(1)
+	reportedErrors.Lock()
+	reportedErrors.messages = append(reportedErrors.messages, fmt.Sprintf(format, a...))
+	reportedErrors.Unlock()
+	fprintStatus(os.Stderr, colorRed, "Error:", format, a...)
+}
+
+// errorMessages are the errors reported so far this run
+func errorMessages() []string {
+	reportedErrors.Lock()
+	defer reportedErrors.Unlock()
+	return append([]string{}, reportedErrors.messages...)
+}
+
+// exitHook, when set, runs once when a run fails through exit, such as to
+// write the install report of a run that didn't get to it
+var exitHook func()
+
+// failed reports whether --warnings-as-errors turned the run into a
+// failure, the errors reported so far including its warnings
+func failed() bool {
+	return WarningsAsErrors && errorCount.Load() > 0
+}
+
+// exit ends the run with a last stderr line counting the errors reported,
+// e.g. "hermes: 2 errors", for scripts to check
+func exit(code int) {
+	if hook := exitHook; hook != nil && code != 0 {
+		exitHook = nil
+		hook()
+	}
+	flushAllOutput()
+	if n := errorCount.Load(); n == 1 {
+		fmt.Fprintln(os.Stderr, "hermes: 1 error")
+	} else if n > 1 {
+		fmt.Fprintf(os.Stderr, "hermes: %d errors\n", n)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// flag variables
+var OutputFormat string
+
+// outputFormats are the presets of install --format. Each sets the output
+// fields of a common setup, leaving paths the config already sets alone.
+var outputFormats = map[string]func(cfg *FontsYAML){
+	// the stylesheet alone, as without --format
+	"css": func(cfg *FontsYAML) {},
+	// a partial for Sass builds, with a custom property per family
+	"scss": func(cfg *FontsYAML) {
+		if cfg.Stylesheet != stdoutStylesheet && filepath.Ext(cfg.Stylesheet) != ".scss" {
+			cfg.Stylesheet = siblingPath(cfg.Stylesheet, ".scss")
+		}
+		cfg.CSSVariables = true
+	},
+	// the stylesheet and a manifest of the files to preload
+	"css+preload": func(cfg *FontsYAML) {
+		if cfg.Manifest == "" {
+			cfg.Manifest = outputSibling(cfg, ".preload.json")
+			no := false
+			cfg.ManifestFormat = &ManifestFormat{URLs: &no, Checksums: &no}
+		}
+	},
+	// the stylesheet and a manifest of the files, with urls and checksums
+	"json": func(cfg *FontsYAML) {
+		if cfg.Manifest == "" {
+			cfg.Manifest = outputSibling(cfg, ".json")
+		}
+	},
+	// the stylesheet and a second one with the files as data: urls
+	"inline": func(cfg *FontsYAML) {
+		for _, t := range cfg.Targets {
+			if t.Inline {
+				return
+			}
+		}
+		cfg.Targets = append(cfg.Targets, InstallTarget{Name: "inline", Stylesheet: outputSibling(cfg, ".inline.css"), Inline: true})
+	},
+	// the stylesheet and a second one for HTML email, see emailRule
+	"email": func(cfg *FontsYAML) {
+		for _, t := range cfg.Targets {
+			if t.Email {
+				return
+			}
+		}
+		cfg.Targets = append(cfg.Targets, InstallTarget{Name: "email", Stylesheet: outputSibling(cfg, ".email.css"), Email: true})
+	},
+}
+
+// applyOutputFormat sets the fields of the --format preset on cfg
+func applyOutputFormat(cfg *FontsYAML, format string) error {
+	apply, ok := outputFormats[format]
+	if !ok {
+		names := make([]string, 0, len(outputFormats))
+		for name := range outputFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("--format must be one of %s, got %q", strings.Join(names, ", "), format)
+	}
+	apply(cfg)
+	return nil
+}
+
+// outputSibling is the path of an output written beside the stylesheet,
+// or in dir when the stylesheet goes to stdout, e.g. fonts.json for
+// fonts.css
+func outputSibling(cfg *FontsYAML, suffix string) string {
+	if cfg.Stylesheet == stdoutStylesheet {
+		return filepath.Join(cfg.Dir, "fonts"+suffix)
+	}
+	return siblingPath(cfg.Stylesheet, suffix)
+}
+
+// siblingPath replaces the extension of path with suffix
+func siblingPath(path, suffix string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + suffix
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Check that the Google Fonts API is reachable and accepts GFONTS_KEY",
+	Long: `Makes a single small request to the Google Fonts Developer API, with
+GFONTS_KEY when it is set, and reports how long the API took to answer.
+A rejected key and an unreachable API are reported as different failures,
+so the fastest check before an install tells which one to fix.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		key := viper.GetString("GFONTS_KEY")
+		res, err := ping(key)
+		switch {
+		case err != nil:
+			printError("network error, the Google Fonts API is unreachable: %v", err)
+			fmt.Println("Check your network connection and proxy settings")
+			exit(1)
+		case key == "":
+			// the API refuses requests without a key, but it answered
+			printSuccess("Reachable", "the Google Fonts API answered in %s", formatLatency(res.latency))
+			printWarning("GFONTS_KEY is not set, so it wasn't checked. Get a key at https://console.cloud.google.com/apis/credentials")
+		case res.status == http.StatusOK:
+			printSuccess("OK", "the Google Fonts API accepted GFONTS_KEY in %s", formatLatency(res.latency))
+		case res.status == http.StatusBadRequest || res.status == http.StatusForbidden:
+			printError("authentication error, the Google Fonts API rejected GFONTS_KEY: %s", res.message)
+			fmt.Println("Check the key, and that the Web Fonts Developer API is enabled for its project, at https://console.cloud.google.com/apis/credentials")
+			exit(1)
+		case res.status == http.StatusTooManyRequests:
+			printError("the Google Fonts API is rate limiting this key: %s", res.message)
+			exit(1)
+		default:
+			printError("the Google Fonts API answered with an error: %s", res.message)
+			exit(1)
+		}
+	},
+}
+
+// pingResult is the API's answer to ping
+type pingResult struct {
+	status  int
+	latency time.Duration
+	// message is the status with the API's explanation, if it gave one
+	message string
+}
+
+// ping asks the Developer API for a single family, the smallest request
+// that checks the key. Only a failure to get an answer is an error.
+func ping(key string) (pingResult, error) {
+	req, err := http.NewRequest(http.MethodGet, webfontsAPI+"?key="+key+"&family=Roboto", nil)
+	if err != nil {
+		return pingResult{}, err
+	}
+	client := &http.Client{Transport: httpClient.Transport, Timeout: 10 * time.Second}
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return pingResult{}, redactError(err, req.URL)
+	}
+	defer res.Body.Close()
+	result := pingResult{status: res.StatusCode, latency: time.Since(start), message: res.Status}
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		result.message += " (" + body.Error.Message + ")"
+	}
+	return result, nil
+}
+
+// formatLatency rounds a latency to the millisecond, e.g. 84ms
+func formatLatency(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func init() {
+	rootCmd.AddCommand(pingCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// sidecarExts maps each precompress method to the extension of the
+// compressed copy written next to every font file
+var sidecarExts = map[string]string{
+	"gzip":   ".gz",
+	"brotli": ".br",
+}
+
+func validatePrecompress(method string) error {
+	if method == "" {
+		return nil
+	}
+	if _, ok := sidecarExts[method]; !ok {
+		return fmt.Errorf("unsupported precompress method %q (expected gzip or brotli)", method)
+	}
+	return nil
+}
+
+// isManagedFile reports whether name is a font file or a sidecar that
+// install may have written, and so may be cleaned up. The woffs of
+// convert_woff are only managed as recorded, see convertedWOFFs.
+func isManagedFile(name string) bool {
+	if strings.HasSuffix(name, ".woff2") {
+		return true
+	}
+	for _, ext := range sidecarExts {
+		if strings.HasSuffix(name, ".woff2"+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// precompressFile writes a compressed copy of path next to it and returns
+// the sidecar's path. An existing sidecar newer than path is kept as is.
+func precompressFile(path, method string) (string, error) {
+	sidecar := path + sidecarExts[method]
+	src, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if dst, err := os.Stat(sidecar); err == nil && !dst.ModTime().Before(src.ModTime()) {
+		return sidecar, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	// compressed in memory and written atomically, so an interrupted run
+	// never leaves a truncated sidecar next to the font
+	var out bytes.Buffer
+	var zw io.WriteCloser
+	switch method {
+	case "brotli":
+		zw = brotli.NewWriterLevel(&out, brotli.BestCompression)
+	default:
+		if zw, err = gzip.NewWriterLevel(&out, gzip.BestCompression); err != nil {
+			return "", err
+		}
+	}
+	if _, err := zw.Write(data); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return sidecar, writeFileAtomic(sidecar, out.Bytes())
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyPresets merges each font entry's `extends` preset into it. A field
+// the entry sets replaces the preset's whole, lists and maps included, so
+// an entry can also turn off a flag the preset turns on.
+// Example:
+//
+//	presets:
+//	  heading:
+//	    variants: ["700", "900"]
+//	    subsets: [latin]
+//	fonts:
+//	  - family: Roboto
+//	    extends: heading
+//	    variants: ["700"]
+//
+// The merge works on the yaml node tree, before decoding, as a decoded
+// entry can't tell a field set to false from one left out.
+func applyPresets(doc *yaml.Node) error {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	fonts := mappingValue(root, "fonts")
+	if fonts == nil || fonts.Kind != yaml.SequenceNode {
+		return nil
+	}
+	presets := mappingValue(root, "presets")
+	for i, entry := range fonts.Content {
+		extends := mappingValue(entry, "extends")
+		if extends == nil {
+			continue
+		}
+		name := fmt.Sprintf("font entry %d", i+1)
+		if family := mappingValue(entry, "family"); family != nil {
+			name = "font " + family.Value
+		}
+		var preset *yaml.Node
+		if presets != nil {
+			preset = mappingValue(presets, extends.Value)
+		}
+		if preset == nil {
+			return fmt.Errorf("%s: `extends` names an unknown preset %q", name, extends.Value)
+		}
+		if preset.Kind != yaml.MappingNode {
+			return fmt.Errorf("preset %s is not a mapping", extends.Value)
+		}
+		if mappingValue(preset, "extends") != nil {
+			return fmt.Errorf("preset %s: presets cannot extend other presets", extends.Value)
+		}
+		merged := []*yaml.Node{}
+		for j := 0; j+1 < len(preset.Content); j += 2 {
+			if mappingValue(entry, preset.Content[j].Value) == nil {
+				merged = append(merged, preset.Content[j], preset.Content[j+1])
+			}
+		}
+		entry.Content = append(merged, entry.Content...)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var PruneDryRun bool
+var PruneJSON bool
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [config]",
+	Short: "Remove the files of fonts that were removed from the config",
+	Long: `Compares the config with the lock file and removes only what the last
+install wrote for families, and variants of families, that are no longer
+in the config: their font files, with any precompressed or converted
+copies and license files, their @font-face rules in the stylesheets, and
+their lock entries. Unlike the cleanup of install, files hermes didn't
+record are never touched, so dir can be shared with other tools. The
+variants of axes and css_url entries are only pruned with their family.
+Nothing is downloaded; run install afterwards to refresh other outputs
+such as the manifest. --json prints what was removed as JSON for scripts.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if PruneJSON {
+			startJSON()
+		}
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if cfg.Fonts, err = mergeDuplicateFonts(cfg.Fonts, "merge"); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		cfg.Fonts = applyOnlyVariants(cfg.Fonts, cfg.OnlyVariants)
+		if cfg.StrictDir {
+			if err := checkStrictDir(cfg, configPath); err != nil {
+				printError("%v", err)
+				exit(1)
+			}
+		}
+		lockFile := lockPath(configPath)
+		lock, err := readLock(lockFile)
+		if err != nil {
+			printError("could not read lock file %s: %v", lockFile, err)
+			exit(1)
+		}
+
+		removed, families := pruneLock(cfg, lock)
+		result := &pruneResult{DryRun: PruneDryRun, Fonts: families, Variants: []string{}, Files: []string{}, Rules: map[string]int{}}
+		if len(removed) == 0 && families == 0 {
+			fmt.Println("Nothing to prune")
+			if PruneJSON {
+				printResult("prune", result)
+			}
+			return
+		}
+		for key := range removed {
+			result.Variants = append(result.Variants, key)
+		}
+		sort.Strings(result.Variants)
+		kept := lockedFileSet(lock)
+		gone := map[string]struct{}{}
+		for _, v := range removed {
+			for _, name := range lockedFiles(v) {
+				if _, ok := kept[name]; !ok {
+					gone[name] = struct{}{}
+				}
+			}
+		}
+		// every stylesheet is parsed before anything is removed, so one
+		// that can't be edited leaves dir and the lock as they were
+		type prunedStylesheet struct {
+			path, css string
+			n         int
+		}
+		var pruned []prunedStylesheet
+		stylesheets := []string{cfg.Stylesheet}
+		if cfg.CriticalStylesheet != "" {
+			stylesheets = append(stylesheets, cfg.CriticalStylesheet)
+		}
+		for _, path := range stylesheets {
+			data, err := cfg.readStylesheet(path)
+			if path == stdoutStylesheet || os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				printError("could not read stylesheet: %v", err)
+				exit(1)
+			}
+			css, n, err := removeRules(data, func(r fontFaceBlock) bool {
+				// the local() names of local_postscript don't keep a rule
+				names, ok := ruleFiles(r, cfg.BaseURL, true)
+				if !ok {
+					return false
+				}
+				for _, name := range names {
+					if _, ok := gone[name]; !ok {
+						return false
+					}
+				}
+				return true
+			})
+			if err != nil {
+				printError("could not parse %s, nothing was pruned: %v", path, err)
+				exit(1)
+			}
+			if n > 0 {
+				pruned = append(pruned, prunedStylesheet{path, css, n})
+				result.Rules[path] = n
+			}
+		}
+
+		files := prunedFiles(cfg.Dir, kept, removed)
+		verb, color := "Removing", colorYellow
+		if PruneDryRun {
+			verb, color = "Would remove", colorCyan
+		}
+		for _, name := range files {
+			printStatus(color, verb, "%s", filepath.Join(cfg.Dir, name))
+			result.Files = append(result.Files, filepath.Join(cfg.Dir, name))
+			if !PruneDryRun {
+				if err := os.Remove(filepath.Join(cfg.Dir, name)); err != nil && !os.IsNotExist(err) {
+					printWarning("failed to remove %s: %v", name, err)
+				}
+				removeEmptyDirs(cfg.Dir, filepath.Dir(filepath.Join(cfg.Dir, name)))
+			}
+		}
+		for _, p := range pruned {
+			if PruneDryRun {
+				printStatus(colorCyan, "Would remove", "%d rule(s) from %s", p.n, p.path)
+				continue
+			}
+			if err := cfg.writeStylesheet(p.path, p.css); err != nil {
+				printError("failed to write %s: %v", p.path, err)
+				exit(1)
+			}
+			printSuccess("Pruned", "%d rule(s) from %s", p.n, p.path)
+		}
+
+		if PruneDryRun {
+			if PruneJSON {
+				printResult("prune", result)
+			}
+			return
+		}
+		if err := writeLock(lockFile, lock); err != nil {
+			printError("failed to write lock file: %v", err)
+			exit(1)
+		}
+		printSuccess("Pruned", "%d font(s) and %d variant(s) no longer in %s", families, len(removed), configPath)
+		if PruneJSON {
+			printResult("prune", result)
+		}
+	},
+}
+
+// pruneLock removes the fonts and variants the config no longer names from
+// lock. It returns the removed variants, keyed by font and variant, with a
+// removed font's license file among them, and how many fonts were removed
+// as a whole.
+func pruneLock(cfg *FontsYAML, lock *FontsLock) (map[string]*LockedVariant, int) {
+	entries := map[string]FontEntry{}
+	for _, entry := range cfg.Fonts {
+		entries[entry.name()] = entry
+	}
+	removed := map[string]*LockedVariant{}
+	families := 0
+	for name, locked := range lock.Fonts {
+		entry, ok := entries[name]
+		if !ok {
+			for key, v := range locked.Variants {
+				removed[name+": "+key] = v
+			}
+			if locked.License != "" {
+				removed[name+": license"] = &LockedVariant{File: locked.License}
+			}
+			delete(lock.Fonts, name)
+			families++
+			continue
+		}
+		// the variant keys of axes and css_url entries are their faces,
+		// which the config doesn't list
+		if entry.CSSURL != "" || len(entry.Axes) > 0 {
+			continue
+		}
+		for key, v := range locked.Variants {
+			variant, _, _ := strings.Cut(key, " ")
+			if !slices.Contains(entry.Variants, variant) {
+				removed[name+": "+key] = v
+				delete(locked.Variants, key)
+			}
+		}
+	}
+	return removed, families
+}
+
+// lockedFiles are the files in dir a locked variant wrote, without the
+// copies derived from them
+func lockedFiles(v *LockedVariant) []string {
+	files := []string{}
+	if v.File != "" {
+		files = append(files, v.File)
+	}
+	if v.Fallback != nil && v.Fallback.File != "" {
+		files = append(files, v.Fallback.File)
+	}
+	return files
+}
+
+// lockedFileSet is every file the lock still records, including licenses
+func lockedFileSet(lock *FontsLock) map[string]struct{} {
+	files := map[string]struct{}{}
+	for _, locked := range lock.Fonts {
+		if locked.License != "" {
+			files[locked.License] = struct{}{}
+		}
+		for _, v := range locked.Variants {
+			for _, name := range lockedFiles(v) {
+				files[name] = struct{}{}
+			}
+		}
+	}
+	return files
+}
+
+// prunedFiles lists the files of the removed variants present in dir,
+// with their precompressed and woff copies, leaving out the files kept
+// for another font, as variants with the same url share a file
+func prunedFiles(dir string, kept map[string]struct{}, removed map[string]*LockedVariant) []string {
+	seen := map[string]struct{}{}
+	files := []string{}
+	for _, v := range removed {
+		for _, name := range lockedFiles(v) {
+			if _, ok := kept[name]; ok {
+				continue
+			}
+			candidates := []string{name}
+			if strings.HasSuffix(name, ".woff2") {
+				candidates = append(candidates, woffFileName(name))
+				for _, ext := range sidecarExts {
+					candidates = append(candidates, name+ext)
+				}
+			}
+			for _, c := range candidates {
+				if _, ok := seen[c]; ok || !fileExists(filepath.Join(dir, c)) {
+					continue
+				}
+				seen[c] = struct{}{}
+				files = append(files, c)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// removeEmptyDirs removes sub and its parents up to dir while they are
+// empty, as the hashed layout leaves directories behind
+func removeEmptyDirs(dir, sub string) {
+	// os.Remove fails, leaving the directory, unless it is empty
+	for sub != dir && strings.HasPrefix(sub, dir) && os.Remove(sub) == nil {
+		sub = filepath.Dir(sub)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	pruneCmd.Flags().BoolVar(&PruneDryRun, "dry-run", false, "Report what would be removed, without removing anything")
+	pruneCmd.Flags().BoolVar(&PruneJSON, "json", false, "Print what was removed as JSON, described by hermes schema --result prune")
+}
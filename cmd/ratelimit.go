@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flag variables
+var APIRate string
+
+// maxRateLimitRetries is how often a request answered with 429 is retried
+const maxRateLimitRetries = 3
+
+// maxRateLimitWait caps the time one request spends waiting out 429
+// responses, so a long Retry-After can't stall a run indefinitely
+const maxRateLimitWait = 2 * time.Minute
+
+// tokenBucket spreads requests out to rate per second, allowing bursts of
+// up to burst requests
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := max(rate, 1)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait takes a token, sleeping until one is available. Tokens are reserved
+// before sleeping, so concurrent callers queue up behind each other.
+func (b *tokenBucket) wait() {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	var delay time.Duration
+	if b.tokens < 1 {
+		delay = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens--
+	b.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// drain empties the bucket, so every caller slows down after the API
+// reports that the quota is used up
+func (b *tokenBucket) drain() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens, 0)
+}
+
+var (
+	apiBucket     *tokenBucket
+	apiBucketOnce sync.Once
+)
+
+// apiLimiter returns the --api-rate limiter, or nil when calls are unlimited
+func apiLimiter() *tokenBucket {
+	apiBucketOnce.Do(func() {
+		if APIRate == "" {
+			return
+		}
+		rate, err := parseRate(APIRate)
+		if err != nil {
+			printError("invalid --api-rate value %q: %v", APIRate, err)
+			exit(1)
+		}
+		apiBucket = newTokenBucket(rate)
+	})
+	return apiBucket
+}
+
+// parseRate reads a rate such as 10/s or 600/m as requests per second
+func parseRate(s string) (float64, error) {
+	count, unit, _ := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive number of requests, e.g. 10/s")
+	}
+	switch unit {
+	case "", "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	}
+	return 0, fmt.Errorf("unknown unit %q (expected s, m or h)", unit)
+}
+
+// apiGet makes a Developer API request under the --api-rate limit. A 429
+// response drains the limiter and the request is retried after the delay the
+// API asks for, each retry taking a token again.
+func apiGet(req *http.Request) (*http.Response, error) {
+	limiter := apiLimiter()
+	send := func() (*http.Response, error) {
+		if limiter != nil {
+			limiter.wait()
+		}
+		return httpClient.Do(req)
+	}
+	onLimit := func() {
+		if limiter != nil {
+			limiter.drain()
+		}
+	}
+	return retryRateLimited("the Google Fonts API", send, onLimit)
+}
+
+// downloadGet fetches a font file, retrying 429 responses
+func downloadGet(req *http.Request) (*http.Response, error) {
+	return retryRateLimited(req.URL.Host, func() (*http.Response, error) { return httpClient.Do(req) }, nil)
+}
+
+// retryRateLimited sends a request until it is answered with something
+// other than 429, waiting the delay the server asks for in between, and
+// running onLimit, when set, before each wait. Once the retries or the
+// maxRateLimitWait budget run out, the 429 response is returned.
+func retryRateLimited(server string, send func() (*http.Response, error), onLimit func()) (*http.Response, error) {
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		res, err := send()
+		if err != nil || res.StatusCode != http.StatusTooManyRequests || attempt == maxRateLimitRetries {
+			return res, err
+		}
+		delay := retryAfter(res, attempt)
+		if waited+delay > maxRateLimitWait {
+			printWarning("rate limited by %s, which asks to wait %s; giving up as that would pass the %s limit", server, delay, maxRateLimitWait)
+			return res, nil
+		}
+		res.Body.Close()
+		if onLimit != nil {
+			onLimit()
+		}
+		// only running out of retries is a problem, see above
+		printStatus(colorYellow, "Rate limited", "by %s, retrying in %s", server, delay)
+		time.Sleep(delay)
+		waited += delay
+	}
+}
+
+// retryAfter is the Retry-After delay of res, given in seconds or as a
+// date, or an exponential backoff
+func retryAfter(res *http.Response, attempt int) time.Duration {
+	header := res.Header.Get("Retry-After")
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at).Round(time.Second), 0)
+	}
+	return backoff(attempt)
+}
+
+// Retry jitter modes, after the AWS Architecture Blog's "Exponential
+// Backoff And Jitter": full picks a delay between 0 and the backoff, equal
+// between half of it and all of it
+const (
+	jitterFull  = "full"
+	jitterEqual = "equal"
+	jitterNone  = "none"
+)
+
+// defaultMaxBackoff caps the exponential backoff between retries
+const defaultMaxBackoff = 30 * time.Second
+
+func validateBackoff() error {
+	switch RetryJitter {
+	case jitterFull, jitterEqual, jitterNone:
+	default:
+		return fmt.Errorf("--retry-jitter must be full, equal or none, got %q", RetryJitter)
+	}
+	if RetryMaxBackoff <= 0 {
+		return fmt.Errorf("--retry-max-backoff must be positive")
+	}
+	if RetryBudget < 0 {
+		return fmt.Errorf("--retry-budget cannot be negative")
+	}
+	return nil
+}
+
+// retryBudget caps the download retries of a whole run, so one failing
+// file can't add up to a long delay and a provider that is down fails the
+// run quickly
+type retryBudget struct {
+	mu    sync.Mutex
+	limit int
+	left  int
+}
+
+// newRetryBudget returns a budget of limit retries, or nil, which allows
+// any number, for 0
+func newRetryBudget(limit int) *retryBudget {
+	if limit == 0 {
+		return nil
+	}
+	return &retryBudget{limit: limit, left: limit}
+}
+
+// take spends one retry, reporting whether any were left. The first
+// refused retry prints a warning.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.left > 0:
+		b.left--
+		return true
+	case b.left == 0:
+		printWarning("the retry budget of %d retries is exhausted, failed downloads are no longer retried", b.limit)
+		b.left = -1
+	}
+	return false
+}
+
+// backoff is the delay before retry attempt+1: one second doubling with
+// each attempt, capped at --retry-max-backoff and jittered by --retry-jitter
+func backoff(attempt int) time.Duration {
+	limit := RetryMaxBackoff
+	if limit <= 0 {
+		limit = defaultMaxBackoff
+	}
+	d := limit
+	if attempt < 32 {
+		d = min(time.Second<<attempt, limit)
+	}
+	switch RetryJitter {
+	case jitterNone:
+	case jitterEqual:
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	default:
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	return d.Round(time.Millisecond)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&APIRate, "api-rate", "", "Limit Google Fonts API lookups to this rate, e.g. 10/s or 600/m (default unlimited)")
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RemoteOptions sets the attributes hermes suggests for preloading a font
+// left on the provider's servers with self_host: false.
+// Example:
+//
+//	fonts:
+//	  - family: Roboto
+//	    self_host: false
+//	    remote:
+//	      referrerpolicy: no-referrer
+type RemoteOptions struct {
+	// Crossorigin is anonymous, the default, or use-credentials. Browsers
+	// always fetch the fonts of a stylesheet as anonymous CORS requests, so
+	// a use-credentials preload is not reused by the stylesheet
+	Crossorigin string `yaml:"crossorigin,omitempty"`
+	// ReferrerPolicy, e.g. no-referrer, keeps page urls from reaching the
+	// provider. It is left out by default, using the page's policy
+	ReferrerPolicy string `yaml:"referrerpolicy,omitempty"`
+}
+
+var crossoriginValues = []string{"anonymous", "use-credentials"}
+
+var referrerPolicies = []string{"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin", "same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url"}
+
+func (o *RemoteOptions) validate() error {
+	if o.Crossorigin != "" && !slices.Contains(crossoriginValues, o.Crossorigin) {
+		return fmt.Errorf("`remote.crossorigin` must be one of %s, got %q", strings.Join(crossoriginValues, ", "), o.Crossorigin)
+	}
+	if o.ReferrerPolicy != "" && !slices.Contains(referrerPolicies, o.ReferrerPolicy) {
+		return fmt.Errorf("`remote.referrerpolicy` must be one of %s, got %q", strings.Join(referrerPolicies, ", "), o.ReferrerPolicy)
+	}
+	return nil
+}
+
+// preloadNote is the comment placed above the rule of a remote font,
+// holding the <link> that preloads it under strict CORS and CSP setups,
+// with its integrity value when sri computed one
+func (e FontEntry) preloadNote(src fontSrc, integrity string) string {
+	opts := RemoteOptions{}
+	if e.Remote != nil {
+		opts = *e.Remote
+	}
+	link := fmt.Sprintf(`<link rel="preload" href="%s" as="font"`, src.URL)
+	if u, err := url.Parse(src.URL); err == nil && path.Ext(u.Path) != "" {
+		link += fmt.Sprintf(` type="font/%s"`, strings.TrimPrefix(path.Ext(u.Path), "."))
+	}
+	if opts.Crossorigin == "use-credentials" {
+		link += ` crossorigin="use-credentials"`
+	} else {
+		link += " crossorigin"
+	}
+	if opts.ReferrerPolicy != "" {
+		link += fmt.Sprintf(` referrerpolicy="%s"`, opts.ReferrerPolicy)
+	}
+	if integrity != "" {
+		link += fmt.Sprintf(` integrity="%s"`, integrity)
+	}
+	return "/* preload: " + link + "> */"
+}
+
+// remoteOrigins are the origins the fonts that aren't self-hosted are
+// fetched from, e.g. https://fonts.gstatic.com
+func remoteOrigins(fonts map[string]*LockedFont) []string {
+	seen := map[string]bool{}
+	origins := []string{}
+	for _, locked := range fonts {
+		lockedSources(locked, func(v *LockedVariant, u *url.URL) {
+			origin := u.Scheme + "://" + u.Host
+			if v.Remote && !seen[origin] {
+				seen[origin] = true
+				origins = append(origins, origin)
+			}
+		})
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// resourceHintsComment is the comment holding the preconnect and
+// dns-prefetch <link> tags of the remote origins, for resource_hints. The
+// preconnect is anonymous, like the font requests it speeds up.
+func resourceHintsComment(origins []string) string {
+	if len(origins) == 0 {
+		return ""
+	}
+	lines := []string{}
+	for _, origin := range origins {
+		lines = append(lines,
+			fmt.Sprintf(` * <link rel="preconnect" href="%s" crossorigin>`, origin),
+			fmt.Sprintf(` * <link rel="dns-prefetch" href="%s">`, origin))
+	}
+	return "/*\n * resource hints:\n" + strings.Join(lines, "\n") + "\n */"
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errFontNotFound is returned when no family matches a lookup
+var errFontNotFound = errors.New("font not found")
+
+// Resolver looks up a font family's metadata. The install flow resolves
+// families through it, so it can be mocked and other providers can plug in.
+type Resolver interface {
+	// Resolve returns the family's metadata, or an error wrapping
+	// errFontNotFound when no family matches
+	Resolve(family string) (*FontItem, error)
+}
+
+// URLSigner signs download urls before they are fetched, for providers and
+// mirrors behind a signed-url CDN. The Google Fonts urls need no signing.
+type URLSigner interface {
+	// SignURL returns the url to fetch for rawURL, which may be returned
+	// as is when it needs no signature
+	SignURL(rawURL string) (string, error)
+}
+
+// googleResolver resolves families with the Developer API, or from the
+// catalog snapshot with --offline
+type googleResolver struct {
+	// static asks for the static files of variable fonts
+	static bool
+}
+
+func (r googleResolver) Resolve(family string) (*FontItem, error) {
+	parsed := parseFontFamily(family)
+	capabilities := "&capability=WOFF2&capability=VF"
+	if r.static {
+		capabilities = "&capability=WOFF2"
+	}
+	var fontResponse Font
+	var err error
+	if Offline {
+		fontResponse, err = snapshotFont(parsed, capabilities)
+	} else {
+		fontResponse, err = fetchWebfonts("&family=" + parsed + capabilities)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(fontResponse.Items) < 1 {
+		return nil, fmt.Errorf("%w: %s", errFontNotFound, family)
+	}
+	return &fontResponse.Items[0], nil
+}
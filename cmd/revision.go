@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// catalogRevision is the newest catalog date among the locked fonts, which
+// pins the catalog revision the lock was resolved against
+func catalogRevision(fonts map[string]*LockedFont) string {
+	revision := ""
+	for _, font := range fonts {
+		// dates are YYYY-MM-DD, so they compare as strings
+		if font.LastModified > revision {
+			revision = font.LastModified
+		}
+	}
+	return revision
+}
+
+// catalogLastModified looks up the current catalog date of every family,
+// from the snapshot with --offline
+func catalogLastModified() (map[string]string, error) {
+	var items []FontItem
+	if Offline {
+		items = loadCatalogSnapshot().Items
+	} else {
+		// a partial response keeps the whole catalog down to two fields per family
+		fontResponse, err := fetchWebfonts("&fields=items(family,lastModified)")
+		if err != nil {
+			return nil, err
+		}
+		items = fontResponse.Items
+	}
+	dates := make(map[string]string, len(items))
+	for _, item := range items {
+		dates[item.Family] = item.LastModified
+	}
+	return dates, nil
+}
+
+// checkCatalogDrift warns about fonts installed from the lock whose catalog
+// entry has changed since they were resolved. Fonts that were looked up this
+// run are already current.
+func (in *installer) checkCatalogDrift() {
+	if len(in.lockedFamilies) == 0 {
+		return
+	}
+	dates := in.catalogDates
+	if dates == nil {
+		var err error
+		if dates, err = catalogLastModified(); err != nil {
+			printWarning("could not check the catalog for changes: %v", err)
+			return
+		}
+	}
+	changed := []string{}
+	for _, name := range in.lockedFamilies {
+		font := in.newLock.Fonts[name]
+		if font.LastModified == "" {
+			continue
+		}
+		if live, ok := dates[font.Family]; ok && live > font.LastModified {
+			changed = append(changed, fmt.Sprintf("%s (%s -> %s)", font.Family, font.LastModified, live))
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		printWarning("the catalog has changed since the lock was written for %s. Run: hermes update", strings.Join(changed, ", "))
+	}
+}
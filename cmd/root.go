@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"os"
 	"github.com/spf13/cobra"
 )
 
@@ -20,7 +19,7 @@ the downloaded fonts into your project.`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	if err != nil || failed() {
+		exit(1)
 	}
 }
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// flag variables
+var SampleVariant string
+var SampleText string
+var SampleSize float64
+var SampleColor string
+var SampleBackground string
+var SampleOutput string
+
+// samplePangram is the default sample text, using every letter
+const samplePangram = "The quick brown fox jumps over the lazy dog"
+
+var sampleCmd = &cobra.Command{
+	Use:   "sample <font>",
+	Short: "Render a PNG preview of a font",
+	Long: `Renders a line of sample text in the font to a PNG image, for previews in
+documentation. The file installed for the variant by the config, --config
+or fonts.yaml in the current directory, is used when there is one,
+otherwise the variant's static file is downloaded without being installed. Text, size and colors are flags;
+--background transparent leaves the background clear.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		family := args[0]
+		if SampleSize <= 0 {
+			printError("--size must be positive")
+			exit(1)
+		}
+		fg, err := parseSampleColor(SampleColor)
+		if err != nil {
+			printError("--color: %v", err)
+			exit(1)
+		}
+		bg, err := parseSampleColor(SampleBackground)
+		if err != nil {
+			printError("--background: %v", err)
+			exit(1)
+		}
+		// args holds the font, not a config
+		data, source := sampleFontData(configFile(nil), family, SampleVariant)
+		img, err := renderSample(data, SampleText, SampleSize, fg, bg)
+		if err != nil {
+			printError("could not render %s (%s): %v", family, SampleVariant, err)
+			exit(1)
+		}
+		output := SampleOutput
+		if output == "" {
+			output = strings.ReplaceAll(family, " ", "_") + "_" + SampleVariant + ".png"
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if dir := filepath.Dir(output); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				printError("failed to create directory for %s: %v", output, err)
+				exit(1)
+			}
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+			printError("failed to write %s: %v", output, err)
+			exit(1)
+		}
+		printSuccess("Wrote", "%s from %s", output, source)
+	},
+}
+
+// sampleFontData returns the font file of the variant, and where it came
+// from: the installed file when the lock of the config at configPath has
+// one, or a download. Static files are downloaded, as the variable file of
+// a variable font would render its default instance for every weight.
+func sampleFontData(configPath, family, variant string) ([]byte, string) {
+	if fileExists(configPath) {
+		if cfg, err := loadFontsYAML(configPath); err == nil {
+			if lock, err := readLock(lockPath(configPath)); err == nil {
+				for _, locked := range lock.Fonts {
+					v, ok := locked.Variants[variant]
+					if !ok || v.File == "" || !strings.EqualFold(locked.Family, family) {
+						continue
+					}
+					path := filepath.Join(cfg.Dir, filepath.FromSlash(v.File))
+					if data, err := os.ReadFile(path); err == nil {
+						return data, path
+					}
+				}
+			}
+		}
+	}
+
+	item, err := googleResolver{static: true}.Resolve(family)
+	if errors.Is(err, errFontNotFound) {
+		printError("no font found for %s", family)
+		exit(1)
+	}
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	url, ok := item.Files[variant]
+	if !ok {
+		printError("%s has no %s variant (available: %s)", item.Family, variant, strings.Join(item.Variants, ", "))
+		exit(1)
+	}
+	tmp, err := os.MkdirTemp("", "hermes-sample-")
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "font")
+	if _, err := downloadToFile(url, path, downloadOptions{}); err != nil {
+		printError("failed to download %s (%s): %v", item.Family, variant, err)
+		exit(1)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	return data, url
+}
+
+// renderSample draws text in the font on a single line, padded by a
+// quarter of the size, on an image just large enough to hold it
+func renderSample(data []byte, text string, size float64, fg, bg color.Color) (image.Image, error) {
+	data, err := sfntData(data)
+	if err != nil {
+		return nil, err
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	pad := int(size / 4)
+	width := font.MeasureString(face, text).Ceil() + 2*pad
+	height := (metrics.Ascent + metrics.Descent).Ceil() + 2*pad
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(fg),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.I(pad), Y: fixed.I(pad) + metrics.Ascent},
+	}
+	d.DrawString(text)
+	return img, nil
+}
+
+// parseSampleColor parses a #rgb, #rrggbb or #rrggbbaa color, or transparent
+func parseSampleColor(s string) (color.Color, error) {
+	if s == "transparent" {
+		return color.Transparent, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		return nil, fmt.Errorf("%q is not a color such as #1a1a1a or transparent", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+func init() {
+	rootCmd.AddCommand(sampleCmd)
+	sampleCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	sampleCmd.Flags().StringVar(&SampleVariant, "variant", "regular", "Variant to render, e.g. 700 or italic")
+	sampleCmd.Flags().StringVar(&SampleText, "text", samplePangram, "Text to render")
+	sampleCmd.Flags().Float64Var(&SampleSize, "size", 48, "Font size in pixels")
+	sampleCmd.Flags().StringVar(&SampleColor, "color", "#000000", "Text color, e.g. #1a1a1a")
+	sampleCmd.Flags().StringVar(&SampleBackground, "background", "#ffffff", "Background color, or transparent")
+	sampleCmd.Flags().StringVarP(&SampleOutput, "output", "o", "", "PNG file to write (default <font>_<variant>.png)")
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var SchemaOutput string
+var SchemaResult string
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema of fonts.yaml for editor completion and validation",
+	Long: `Prints a JSON Schema describing every field of fonts.yaml, generated from
+the types hermes decodes the config into, so it always matches this
+version. Point yaml-language-server at it with a comment at the top of the
+config:
+
+  # yaml-language-server: $schema=./fonts.schema.json
+
+With --result the schema of a command's --json result is printed instead,
+e.g. hermes schema --result verify. Each result names its schema in its
+schema field, such as hermes.verify.v1.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema := configSchema()
+		if SchemaResult != "" {
+			t, ok := resultTypes[SchemaResult]
+			if !ok {
+				printError("no --json result for %q, expected one of %s", SchemaResult, strings.Join(resultCommands(), ", "))
+				exit(1)
+			}
+			schema = resultSchemaOf(SchemaResult, t)
+		}
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			printError("could not encode the schema: %v", err)
+			exit(1)
+		}
+		data = append(data, '\n')
+		if SchemaOutput == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(SchemaOutput), 0755); err != nil {
+			printError("failed to create directory for %s: %v", SchemaOutput, err)
+			exit(1)
+		}
+		if err := writeOutput(SchemaOutput, data); err != nil {
+			printError("failed to write %s: %v", SchemaOutput, err)
+			exit(1)
+		}
+		printSuccess("Wrote", "%s", SchemaOutput)
+	},
+}
+
+// configSchema is the JSON Schema of FontsYAML. Each struct is described
+// once under $defs, as font entries appear both in fonts and in presets.
+func configSchema() map[string]any {
+	defs := map[string]any{}
+	schema := structSchema(reflect.TypeOf(FontsYAML{}), defs, "yaml")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "fonts.yaml"
+	if len(defs) > 0 {
+		schema["$defs"] = defs
+	}
+	return schema
+}
+
+// resultSchemaOf is the JSON Schema of the --json result of command
+func resultSchemaOf(command string, t reflect.Type) map[string]any {
+	defs := map[string]any{}
+	schema := structSchema(t, defs, "json")
+	schema["properties"].(map[string]any)["schema"] = map[string]any{"const": resultSchemaName(command)}
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = resultSchemaName(command)
+	if len(defs) > 0 {
+		schema["$defs"] = defs
+	}
+	return schema
+}
+
+// durationType is decoded from a duration such as 10m, or nanoseconds
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// timeType is encoded as an RFC 3339 time by encoding/json
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema describes t as yaml.v3 decodes it, or encoding/json encodes it
+// when tag is json. element is set for the items of lists and values of
+// maps, where unquoted yaml numbers such as 700 in variants: [400, 700]
+// decode into strings.
+func typeSchema(t reflect.Type, defs map[string]any, element bool, tag string) map[string]any {
+	switch t {
+	case durationType:
+		return map[string]any{"type": []string{"string", "integer"}}
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs, element, tag)
+	case reflect.String:
+		if element && tag == "yaml" {
+			return map[string]any{"type": []string{"string", "number"}}
+		}
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs, true, tag)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs, true, tag)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			// claim the name first, for types that contain themselves
+			defs[t.Name()] = nil
+			defs[t.Name()] = structSchema(t, defs, tag)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	panic(fmt.Sprintf("schema: unsupported config type %s", t))
+}
+
+// structSchema describes the fields of a struct by their names in tag,
+// yaml or json. Unknown keys are flagged, as they are most likely typos.
+func structSchema(t reflect.Type, defs map[string]any, tag string) map[string]any {
+	properties := map[string]any{}
+	addFields(t, defs, tag, properties)
+	return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+}
+
+// addFields adds the fields of t to properties, with those of the structs
+// encoding/json inlines, embedded without a name, among them
+func addFields(t reflect.Type, defs map[string]any, tag string, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if tag == "json" && f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(f.Type, defs, tag, properties)
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+			if tag == "yaml" {
+				name = strings.ToLower(f.Name)
+			}
+		}
+		properties[name] = typeSchema(f.Type, defs, false, tag)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.Flags().StringVarP(&SchemaOutput, "output", "o", "", "Write the schema to this path instead of stdout")
+	schemaCmd.Flags().StringVar(&SchemaResult, "result", "", "Print the schema of this command's --json result, e.g. verify, instead of the config's")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// ServerConfigOptions configures the generated web server snippet setting
+// the MIME types and Cache-Control headers of the installed font files.
+// Example:
+//
+//	server_config:
+//	  path: ./deploy/fonts.nginx.conf
+//	  server: nginx
+//	  location: /static/fonts/
+type ServerConfigOptions struct {
+	// Path is where the snippet is written
+	Path string `yaml:"path"`
+	// Server is nginx, apache or table, a plain table of the headers for
+	// any other server
+	Server string `yaml:"server"`
+	// Location is the url path the files are served under. It defaults to
+	// the path of base_url, or /<dir name>/ without one
+	Location string `yaml:"location,omitempty"`
+}
+
+func (o *ServerConfigOptions) validate() error {
+	if o.Path == "" {
+		return fmt.Errorf("server_config: `path` not specified")
+	}
+	switch o.Server {
+	case "nginx", "apache", "table":
+	default:
+		return fmt.Errorf("server_config: `server` must be nginx, apache or table, got %q", o.Server)
+	}
+	return nil
+}
+
+// fontMIMETypes are the registered media types of the font formats
+var fontMIMETypes = []struct{ ext, mime string }{
+	{"woff2", "font/woff2"},
+	{"woff", "font/woff"},
+	{"ttf", "font/ttf"},
+	{"otf", "font/otf"},
+}
+
+// Cache-Control values for font files. Files are only immutable when a
+// changed file gets a new url, otherwise browsers would keep the old one.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "public, max-age=604800, stale-while-revalidate=86400"
+)
+
+// serverCacheControl is the Cache-Control suggested for the font files
+func serverCacheControl(cfg *FontsYAML) string {
+	if cfg.Layout == layoutHashed || cfg.CacheBust {
+		return cacheImmutable
+	}
+	return cacheRevalidate
+}
+
+// serverLocation is the url path the font files are served under
+func serverLocation(cfg *FontsYAML) string {
+	loc := cfg.ServerConfig.Location
+	if loc == "" && cfg.BaseURL != "" {
+		if u, err := url.Parse(cfg.BaseURL); err == nil {
+			loc = u.Path
+		}
+	}
+	if loc == "" {
+		loc = "/" + filepath.Base(cfg.Dir)
+	}
+	return strings.TrimSuffix(path.Clean("/"+loc), "/") + "/"
+}
+
+// servedFormats are the extensions of the font formats among the wanted files
+func servedFormats(wanted map[string]struct{}) []struct{ ext, mime string } {
+	formats := []struct{ ext, mime string }{}
+	for _, format := range fontMIMETypes {
+		for name := range wanted {
+			if strings.HasSuffix(name, "."+format.ext) {
+				formats = append(formats, format)
+				break
+			}
+		}
+	}
+	return formats
+}
+
+// writeServerConfig writes the snippet for the installed files
+func writeServerConfig(cfg *FontsYAML, wanted map[string]struct{}) error {
+	opts := cfg.ServerConfig
+	formats := servedFormats(wanted)
+	exts := make([]string, len(formats))
+	for i, format := range formats {
+		exts[i] = format.ext
+	}
+	cache := serverCacheControl(cfg)
+	location := serverLocation(cfg)
+
+	var b strings.Builder
+	switch opts.Server {
+	case "nginx":
+		b.WriteString("# Generated by hermes install, do not edit\n")
+		fmt.Fprintf(&b, "location ^~ %s {\n", location)
+		b.WriteString("    types {\n")
+		for _, format := range formats {
+			fmt.Fprintf(&b, "        %s %s;\n", format.mime, format.ext)
+		}
+		b.WriteString("    }\n")
+		switch cfg.Precompress {
+		case "gzip":
+			b.WriteString("    gzip_static on;\n")
+		case "brotli":
+			b.WriteString("    # needs the ngx_brotli module\n    brotli_static on;\n")
+		}
+		fmt.Fprintf(&b, "    add_header Cache-Control %q;\n", cache)
+		b.WriteString("    add_header Access-Control-Allow-Origin \"*\";\n")
+		b.WriteString("}\n")
+	case "apache":
+		b.WriteString("# Generated by hermes install, do not edit\n")
+		fmt.Fprintf(&b, "# For the files served under %s, e.g. as the .htaccess of their directory\n", location)
+		b.WriteString("<IfModule mod_mime.c>\n")
+		for _, format := range formats {
+			fmt.Fprintf(&b, "  AddType %s .%s\n", format.mime, format.ext)
+		}
+		b.WriteString("</IfModule>\n")
+		if len(exts) > 0 {
+			b.WriteString("<IfModule mod_headers.c>\n")
+			fmt.Fprintf(&b, "  <FilesMatch \"\\.(%s)$\">\n", strings.Join(exts, "|"))
+			fmt.Fprintf(&b, "    Header set Cache-Control %q\n", cache)
+			b.WriteString("    Header set Access-Control-Allow-Origin \"*\"\n")
+			b.WriteString("  </FilesMatch>\n")
+			b.WriteString("</IfModule>\n")
+		}
+	default:
+		fmt.Fprintf(&b, "Headers for the font files under %s\n\n", location)
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "EXTENSION\tCONTENT-TYPE\tCACHE-CONTROL")
+		for _, format := range formats {
+			fmt.Fprintf(w, ".%s\t%s\t%s\n", format.ext, format.mime, cache)
+		}
+		w.Flush()
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0755); err != nil {
+		return err
+	}
+	return writeOutput(opts.Path, []byte(b.String()))
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// URLSigningOptions signs the download urls of a mirror behind a
+// signed-url CDN. Each url gets an expires query parameter, the unix time
+// the signature stops being valid, and a signature parameter, the hex
+// HMAC of the url path and its query with expires, the parameters sorted,
+// e.g. of /s/a.woff2?expires=1700000000. The secret is read from an
+// environment variable, so it stays out of the config and the logs.
+// Example:
+//
+//	url_signing:
+//	  secret_env: FONT_MIRROR_SECRET
+//	  algorithm: sha256
+//	  expires: 10m
+type URLSigningOptions struct {
+	// SecretEnv names the environment variable holding the HMAC secret
+	SecretEnv string `yaml:"secret_env"`
+	// Algorithm is the hash of the HMAC: sha256, the default, sha512 or sha1
+	Algorithm string `yaml:"algorithm,omitempty"`
+	// Expires is how long a signed url is valid, one hour by default
+	Expires time.Duration `yaml:"expires,omitempty"`
+	// Hosts are the hosts whose urls are signed, by default those of the
+	// mirrors. Urls of other hosts, such as the provider's, are fetched
+	// as they are.
+	Hosts []string `yaml:"hosts,omitempty"`
+}
+
+// signingHashes are the hashes url_signing supports
+var signingHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+}
+
+func (o *URLSigningOptions) validate(cfg *FontsYAML) error {
+	if o.SecretEnv == "" {
+		return fmt.Errorf("url_signing: `secret_env` not specified")
+	}
+	if _, ok := signingHashes[o.algorithm()]; !ok {
+		return fmt.Errorf("url_signing: `algorithm` must be sha256, sha512 or sha1, got %q", o.Algorithm)
+	}
+	if o.Expires < 0 {
+		return fmt.Errorf("url_signing: `expires` cannot be negative")
+	}
+	if len(o.Hosts) == 0 && len(cfg.Mirrors) == 0 {
+		return fmt.Errorf("url_signing: `hosts` not specified, and there are no mirrors to sign the urls of")
+	}
+	return nil
+}
+
+func (o *URLSigningOptions) algorithm() string {
+	if o.Algorithm == "" {
+		return "sha256"
+	}
+	return o.Algorithm
+}
+
+// hmacSigner signs urls as configured by url_signing
+type hmacSigner struct {
+	newHash func() hash.Hash
+	secret  []byte
+	expires time.Duration
+	hosts   []string
+}
+
+// urlSigner returns the signer of the config's download urls, or nil
+// without url_signing. The secret must be set in the environment.
+func (cfg *FontsYAML) urlSigner() (URLSigner, error) {
+	o := cfg.URLSigning
+	if o == nil {
+		return nil, nil
+	}
+	secret := os.Getenv(o.SecretEnv)
+	if secret == "" {
+		return nil, fmt.Errorf("url_signing: the environment variable %s holding the secret is not set", o.SecretEnv)
+	}
+	s := &hmacSigner{newHash: signingHashes[o.algorithm()], secret: []byte(secret), expires: o.Expires, hosts: o.Hosts}
+	if s.expires == 0 {
+		s.expires = time.Hour
+	}
+	if len(s.hosts) == 0 {
+		for _, m := range cfg.Mirrors {
+			if u, err := url.Parse(m); err == nil {
+				s.hosts = append(s.hosts, u.Host)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *hmacSigner) SignURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(s.hosts, u.Host) {
+		return rawURL, nil
+	}
+	q := u.Query()
+	q.Del("signature")
+	q.Set("expires", strconv.FormatInt(time.Now().Add(s.expires).Unix(), 10))
+	mac := hmac.New(s.newHash, s.secret)
+	mac.Write([]byte(u.EscapedPath() + "?" + q.Encode()))
+	q.Set("signature", hex.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
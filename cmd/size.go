@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var SizeJSON bool
+
+// roughFileSize stands in for a file whose size the server doesn't report
+// when no other file's size is known, about that of a static latin woff2
+const roughFileSize = 40 << 10
+
+// sizeHeadRequests is the number of HEAD requests in flight at once
+const sizeHeadRequests = 8
+
+// SizeEstimate is the --json representation of hermes size
+type SizeEstimate struct {
+	Families   []FamilySize `json:"families"`
+	Files      int          `json:"files"`
+	TotalBytes int64        `json:"total_bytes"`
+	// Estimated is set when a file's size had to be guessed
+	Estimated bool `json:"estimated"`
+}
+
+// FamilySize totals the files one family would download
+type FamilySize struct {
+	Family string `json:"family"`
+	Files  int    `json:"files"`
+	Bytes  int64  `json:"bytes"`
+	// Estimated counts the files whose size was guessed
+	Estimated int `json:"estimated"`
+}
+
+var sizeCmd = &cobra.Command{
+	Use:   "size [config]",
+	Short: "Estimate the download size of the config's fonts without downloading them",
+	Long: `Resolves every variant the config installs, as install would, and sends a
+HEAD request for each font file to read its size from Content-Length, so
+no font is downloaded. Prints the size of each family and the total, which
+is what a visitor downloading every font would fetch.
+
+A file whose server doesn't report its size is counted as the average of
+the others, marked with ~.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		if cfg.Fonts, err = mergeDuplicateFonts(cfg.Fonts, "merge"); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		cfg.Fonts = applyOnlyVariants(cfg.Fonts, cfg.OnlyVariants)
+		signer, err := cfg.urlSigner()
+		if err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		estimate := estimateSize(fontFileURLs(cfg), signer)
+		if SizeJSON {
+			printJSON(estimate)
+			return
+		}
+		if len(estimate.Families) == 0 {
+			fmt.Println("The config installs no font files")
+			return
+		}
+		printSizeEstimate(estimate)
+	},
+}
+
+// fontFileURLs lists, by family, the urls of the font files the config
+// downloads, each listed once. They are what a dry-run install without a
+// lock file would download, so size resolves variants, fallbacks and
+// subsets as install does.
+func fontFileURLs(cfg *FontsYAML) map[string][]string {
+	in := dryRunInstall(cfg, &FontsLock{Fonts: map[string]*LockedFont{}})
+	urls := map[string][]string{}
+	for _, font := range in.newLock.Fonts {
+		for _, v := range font.Variants {
+			for ; v != nil; v = v.Fallback {
+				u := v.URL
+				if v.Text != "" {
+					// the css2 url of a text subset, as install resolves it
+					var err error
+					if u, err = resolveCSS2FontURL(u); err != nil {
+						printError("failed to resolve the text subset of %s: %v", font.Family, err)
+						exit(1)
+					}
+				}
+				if u != "" && !slices.Contains(urls[font.Family], u) {
+					urls[font.Family] = append(urls[font.Family], u)
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// estimateSize sends a HEAD request for each url and totals the sizes by
+// family. Files without a size count as the average of the others, or
+// roughFileSize when none has one.
+func estimateSize(urls map[string][]string, signer URLSigner) SizeEstimate {
+	type file struct {
+		family, url string
+		size        int64
+	}
+	var files []*file
+	for family, list := range urls {
+		for _, u := range list {
+			files = append(files, &file{family: family, url: u, size: -1})
+		}
+	}
+	sem := make(chan struct{}, sizeHeadRequests)
+	var wg sync.WaitGroup
+	for _, f := range files {
+		wg.Add(1)
+		go func(f *file) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			size, err := contentLength(f.url, signer)
+			if err != nil {
+				printWarning("could not get the size of %s, estimating it: %v", f.url, err)
+				return
+			}
+			f.size = size
+		}(f)
+	}
+	wg.Wait()
+	var known, knownBytes int64
+	for _, f := range files {
+		if f.size >= 0 {
+			known++
+			knownBytes += f.size
+		}
+	}
+	rough := int64(roughFileSize)
+	if known > 0 {
+		rough = knownBytes / known
+	}
+	families := map[string]*FamilySize{}
+	estimate := SizeEstimate{Families: []FamilySize{}}
+	for _, f := range files {
+		fs := families[f.family]
+		if fs == nil {
+			fs = &FamilySize{Family: f.family}
+			families[f.family] = fs
+		}
+		if f.size < 0 {
+			f.size = rough
+			fs.Estimated++
+			estimate.Estimated = true
+		}
+		fs.Files++
+		fs.Bytes += f.size
+		estimate.Files++
+		estimate.TotalBytes += f.size
+	}
+	for _, fs := range families {
+		estimate.Families = append(estimate.Families, *fs)
+	}
+	sort.Slice(estimate.Families, func(i, j int) bool { return estimate.Families[i].Family < estimate.Families[j].Family })
+	return estimate
+}
+
+// contentLength reads the size of the file at u from a HEAD request, or
+// from a GET of its first byte for servers that don't answer HEAD with
+// one, without downloading the file. Like downloads, requests are signed
+// and wait out rate limits.
+func contentLength(u string, signer URLSigner) (int64, error) {
+	if signer != nil {
+		signed, err := signer.SignURL(u)
+		if err != nil {
+			return 0, fmt.Errorf("could not sign %s: %w", u, err)
+		}
+		u = signed
+	}
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := downloadGet(req)
+	if err == nil {
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK && res.ContentLength >= 0 {
+			return res.ContentLength, nil
+		}
+	}
+	req.Method = http.MethodGet
+	req.Header.Set("Range", "bytes=0-0")
+	res, err = downloadGet(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		_, total, _ := strings.Cut(res.Header.Get("Content-Range"), "/")
+		if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+			return size, nil
+		}
+	case res.StatusCode != http.StatusOK:
+		return 0, fmt.Errorf("bad status: %s", res.Status)
+	case res.ContentLength >= 0:
+		// the server ignored the range; closing the body stops the download
+		return res.ContentLength, nil
+	}
+	return 0, fmt.Errorf("the server reports no Content-Length")
+}
+
+func printSizeEstimate(estimate SizeEstimate) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FAMILY\tFILES\tSIZE")
+	for _, f := range estimate.Families {
+		size := formatBytes(f.Bytes)
+		if f.Estimated > 0 {
+			size = "~" + size
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", f.Family, f.Files, size)
+	}
+	total := formatBytes(estimate.TotalBytes)
+	if estimate.Estimated {
+		total = "~" + total
+	}
+	fmt.Fprintf(w, "Total\t%d\t%s\n", estimate.Files, total)
+	w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(sizeCmd)
+	sizeCmd.Flags().BoolVar(&SizeJSON, "json", false, "Print the estimate as JSON")
+}
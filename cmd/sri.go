@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// sriValue renders a SHA-384 digest as an integrity attribute value,
+// e.g. sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC
+func sriValue(h hash.Hash) string {
+	return "sha384-" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// fileIntegrity is the subresource integrity value of the file at path
+func fileIntegrity(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha512.New384()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return sriValue(h), nil
+}
+
+// hashPartial hashes the bytes a resumed download already has, for its
+// SHA-256 and its integrity value, in one read
+func hashPartial(path string) (hash.Hash, hash.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	sum, sri := sha256.New(), sha512.New384()
+	if _, err := io.Copy(io.MultiWriter(sum, sri), f); err != nil {
+		return nil, nil, err
+	}
+	return sum, sri, nil
+}
+
+// remoteIntegrity downloads the font at url, which isn't self-hosted, to
+// compute its subresource integrity value without saving it. Like font
+// downloads, the url is signed and a network or server error retried.
+func remoteIntegrity(url string, opts downloadOptions) (string, error) {
+	var err error
+	for attempt := 0; attempt <= opts.retries; attempt++ {
+		if attempt > 0 {
+			if !opts.budget.take() {
+				break
+			}
+			time.Sleep(backoff(attempt - 1))
+		}
+		var value string
+		var retry bool
+		if value, retry, err = remoteIntegrityOnce(url, opts); err == nil {
+			return value, nil
+		} else if !retry {
+			break
+		}
+	}
+	return "", err
+}
+
+// remoteIntegrityOnce makes one attempt at remoteIntegrity, reporting
+// whether a failure is worth retrying
+func remoteIntegrityOnce(url string, opts downloadOptions) (string, bool, error) {
+	if opts.signer != nil {
+		signed, err := opts.signer.SignURL(url)
+		if err != nil {
+			return "", false, fmt.Errorf("could not sign %s: %w", url, err)
+		}
+		url = signed
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	if opts.timeout > 0 {
+		req = req.WithContext(withReadTimeout(req.Context(), opts.timeout))
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	res, err := downloadGet(req)
+	if err != nil {
+		return "", true, redactError(err, req.URL)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", res.StatusCode >= 500, fmt.Errorf("bad status: %s", res.Status)
+	}
+	body, err := decodedBody(res)
+	if err != nil {
+		return "", false, err
+	}
+	h := sha512.New384()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", true, err
+	}
+	return sriValue(h), false, nil
+}
+
+// addIntegrity records the subresource integrity value of v's file with
+// sri, reusing prev's when it is the same file. Downloaded files are hashed
+// as they are written, so this is for files already on disk, such as when
+// sri is first turned on, and remote fonts, which are fetched once for it;
+// dry runs leave it out.
+func (in *installer) addIntegrity(entry FontEntry, variant string, v, prev *LockedVariant) {
+	if !in.cfg.SRI || DryRun {
+		return
+	}
+	if prev != nil && prev.Integrity != "" && prev.URL == v.URL && prev.SHA256 == v.SHA256 {
+		v.Integrity = prev.Integrity
+		return
+	}
+	var err error
+	if v.Remote {
+		v.Integrity, err = remoteIntegrity(v.URL, in.downloadOptions(entry))
+	} else {
+		v.Integrity, err = fileIntegrity(in.filePath(v.File))
+	}
+	if err != nil {
+		familyWarning(entry.name(), "could not compute the integrity of %s (%s), leaving it out: %v", entry.Family, variant, err)
+	}
+}
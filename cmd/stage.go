@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// flag variables
+var Staged bool
+
+// stagingDir is where a --staged install downloads to before moving its
+// files into dir. It sits beside dir so the final moves are renames on the
+// same filesystem.
+func stagingDir(dir string) string {
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".hermes-staging")
+}
+
+// prepareStaging creates an empty staging directory for dir. Failed runs
+// exit without cleaning up, so a previous run's leftovers are removed first.
+func prepareStaging(dir string) (string, error) {
+	stage := stagingDir(dir)
+	if err := os.RemoveAll(stage); err != nil {
+		return "", err
+	}
+	return stage, os.MkdirAll(stage, 0755)
+}
+
+// commitStaging moves every staged file into dir, keeping its path relative
+// to the staging directory, and removes the staging directory. With
+// --keep-timestamps a file already in dir with the same content is kept.
+func commitStaging(stage, dir string) error {
+	err := filepath.WalkDir(stage, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(stage, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+		if KeepTimestamps && sameContent(path, target) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(stage)
+}
+
+// writeFileAtomic writes data to a temporary file beside path and renames it
+// over path, so readers never see a half-written file. With
+// --keep-timestamps a file already holding data is left alone.
+func writeFileAtomic(path string, data []byte) error {
+	if KeepTimestamps && unchanged(path, data) {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeOutput writes a generated file, leaving it alone with
+// --keep-timestamps when it already holds data
+func writeOutput(path string, data []byte) error {
+	if KeepTimestamps && unchanged(path, data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// unchanged reports whether the file at path holds exactly data
+func unchanged(path string, data []byte) bool {
+	existing, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(existing, data)
+}
+
+// sameContent reports whether the files at a and b hold the same bytes
+func sameContent(a, b string) bool {
+	sumA, err := fileSHA256(a)
+	if err != nil {
+		return false
+	}
+	sumB, err := fileSHA256(b)
+	return err == nil && sumA == sumB
+}
+
+// writeDir is the directory downloads are written to: the staging directory
+// with --staged, otherwise dir
+func (in *installer) writeDir() string {
+	if in.stage != "" {
+		return in.stage
+	}
+	return in.cfg.Dir
+}
+
+// filePath is where a file of this run is on disk: staged when it was
+// downloaded this run with --staged, otherwise in dir
+func (in *installer) filePath(fileName string) string {
+	if in.stage != "" {
+		if path := filepath.Join(in.stage, fileName); fileExists(path) {
+			return path
+		}
+	}
+	return filepath.Join(in.cfg.Dir, fileName)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var StatsJSON bool
+
+// FontStats is the --json representation of hermes stats
+type FontStats struct {
+	Families int `json:"families"`
+	Variants int `json:"variants"`
+	// RemoteVariants are left on the provider and have no file in dir
+	RemoteVariants int   `json:"remote_variants"`
+	Files          int   `json:"files"`
+	TotalBytes     int64 `json:"total_bytes"`
+	AverageBytes   int64 `json:"average_bytes"`
+	// Formats is keyed by file extension, e.g. woff2
+	Formats map[string]*FormatStats `json:"formats"`
+	// Missing lists locked files that are no longer in dir
+	Missing []string `json:"missing"`
+}
+
+// FormatStats totals the files of one format
+type FormatStats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [config]",
+	Short: "Summarize the installed fonts and their size on disk",
+	Long: `Totals the fonts recorded in the lock file: families, variants, the number
+and size of their files in dir, and a breakdown by format. A file shared by
+several variants is counted once. No network requests are made.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		lockFile := lockPath(configPath)
+		lock, err := readLock(lockFile)
+		if err != nil {
+			printError("could not read lock file %s: %v", lockFile, err)
+			exit(1)
+		}
+		stats := fontStats(lock, cfg.Dir)
+		if StatsJSON {
+			printJSON(stats)
+			return
+		}
+		if stats.Families == 0 {
+			fmt.Println("Nothing is installed yet, run hermes install first")
+			return
+		}
+		printStats(stats)
+	},
+}
+
+// fontStats totals the locked fonts and the sizes of their files in dir
+func fontStats(lock *FontsLock, dir string) FontStats {
+	stats := FontStats{Formats: map[string]*FormatStats{}, Missing: []string{}}
+	seen := map[string]bool{}
+	for _, font := range lock.Fonts {
+		stats.Families++
+		for _, v := range font.Variants {
+			stats.Variants++
+			if v.Remote {
+				stats.RemoteVariants++
+			}
+			for ; v != nil; v = v.Fallback {
+				if v.Remote || seen[v.File] {
+					continue
+				}
+				seen[v.File] = true
+				info, err := os.Stat(filepath.Join(dir, v.File))
+				if err != nil {
+					stats.Missing = append(stats.Missing, v.File)
+					continue
+				}
+				format := strings.TrimPrefix(filepath.Ext(v.File), ".")
+				if stats.Formats[format] == nil {
+					stats.Formats[format] = &FormatStats{}
+				}
+				stats.Formats[format].Files++
+				stats.Formats[format].Bytes += info.Size()
+				stats.Files++
+				stats.TotalBytes += info.Size()
+			}
+		}
+	}
+	if stats.Files > 0 {
+		stats.AverageBytes = stats.TotalBytes / int64(stats.Files)
+	}
+	sort.Strings(stats.Missing)
+	return stats
+}
+
+func printStats(stats FontStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Families:\t%d\n", stats.Families)
+	if stats.RemoteVariants > 0 {
+		fmt.Fprintf(w, "Variants:\t%d (%d remote)\n", stats.Variants, stats.RemoteVariants)
+	} else {
+		fmt.Fprintf(w, "Variants:\t%d\n", stats.Variants)
+	}
+	fmt.Fprintf(w, "Files:\t%d\n", stats.Files)
+	fmt.Fprintf(w, "Total size:\t%s\n", formatBytes(stats.TotalBytes))
+	fmt.Fprintf(w, "Average size:\t%s\n", formatBytes(stats.AverageBytes))
+	w.Flush()
+	if len(stats.Formats) > 0 {
+		formats := make([]string, 0, len(stats.Formats))
+		for format := range stats.Formats {
+			formats = append(formats, format)
+		}
+		sort.Strings(formats)
+		fmt.Println("\nBy format:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, format := range formats {
+			f := stats.Formats[format]
+			fmt.Fprintf(w, "  %s\t%d files\t%s\n", format, f.Files, formatBytes(f.Bytes))
+		}
+		w.Flush()
+	}
+	if len(stats.Missing) > 0 {
+		printWarning("%d locked file(s) are missing from dir and not counted: %s", len(stats.Missing), strings.Join(stats.Missing, ", "))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	statsCmd.Flags().BoolVar(&StatsJSON, "json", false, "Print the stats as JSON")
+}
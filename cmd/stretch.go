@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// stretchKeywords are the font-stretch keywords of CSS Fonts Level 4
+var stretchKeywords = []string{
+	"normal", "ultra-condensed", "extra-condensed", "condensed", "semi-condensed",
+	"semi-expanded", "expanded", "extra-expanded", "ultra-expanded",
+}
+
+var stretchPercentage = regexp.MustCompile(`^\d+(\.\d+)?%$`)
+
+// validateStretch checks a font-stretch value: a keyword, a percentage
+// such as 75%, or a range of two percentages such as "75% 125%" for
+// variable fonts with a width axis
+func validateStretch(value string) error {
+	if slices.Contains(stretchKeywords, value) {
+		return nil
+	}
+	parts := strings.Fields(value)
+	if len(parts) == 0 || len(parts) > 2 {
+		return fmt.Errorf("invalid stretch %q (expected a keyword, a percentage or a range of two percentages)", value)
+	}
+	for _, p := range parts {
+		if !stretchPercentage.MatchString(p) {
+			return fmt.Errorf("invalid stretch %q (expected a keyword such as condensed, or percentages such as 75%%)", value)
+		}
+	}
+	return nil
+}
+
+// stretch is the font-stretch of one of the entry's variants, falling back
+// to the entry's "all" value
+func (e FontEntry) stretch(variant string) string {
+	if s, ok := e.Stretch[variant]; ok {
+		return s
+	}
+	return e.Stretch["all"]
+}
+
+// stretchRange reports whether any of the entry's stretch values is a range
+func (e FontEntry) stretchRange() bool {
+	for _, s := range e.Stretch {
+		if len(strings.Fields(s)) == 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAxis reports whether a variable font has the axis with this tag
+func hasAxis(item FontItem, tag string) bool {
+	for _, a := range item.Axes {
+		if a.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
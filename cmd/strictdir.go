@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxListedStrayFiles is how many unexpected files a strict_dir error names
+const maxListedStrayFiles = 5
+
+// checkStrictDir returns an error when dir holds anything besides what
+// hermes writes there: font files and their sidecars, licenses, the
+// .gitignore, leftovers of interrupted writes and the configured outputs,
+// such as a stylesheet kept in dir. Files matching the .hermesignore are
+// declared as belonging there, so they pass too, which lets a strict dir
+// hold hand-placed fonts. A dir that doesn't exist yet passes.
+func checkStrictDir(cfg *FontsYAML, configPath string) error {
+	if _, err := os.Stat(cfg.Dir); os.IsNotExist(err) {
+		return nil
+	}
+	outputs := map[string]struct{}{}
+	for _, p := range []string{configPath, lockPath(configPath), cfg.Stylesheet, cfg.CriticalStylesheet, cfg.Manifest, cfg.TSOutput, ReportPath, historyPath(cfg, configPath)} {
+		if p != "" {
+			outputs[absPath(p)] = struct{}{}
+		}
+	}
+	if cfg.GoEmbed != nil {
+		outputs[absPath(cfg.GoEmbed.Path)] = struct{}{}
+	}
+	if cfg.ServerConfig != nil {
+		outputs[absPath(cfg.ServerConfig.Path)] = struct{}{}
+	}
+
+	converted := map[string]struct{}{}
+	if lock, err := readLock(lockPath(configPath)); err == nil {
+		converted = convertedWOFFs(cfg, lock)
+	}
+	ig := readHermesIgnore(cfg.Dir)
+	stray := []string{}
+	err := filepath.WalkDir(cfg.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(cfg.Dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		f := filepath.ToSlash(rel)
+		if d.IsDir() && ig.ignored(f+"/") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && ig.ignored(f) {
+			return nil
+		}
+		if d.IsDir() {
+			if f == licensesDir || isFormatDir(f) || (strings.Count(f, "/") < 2 && hashedDirName.MatchString(d.Name())) {
+				return nil
+			}
+			stray = append(stray, f+"/")
+			return filepath.SkipDir
+		}
+		if _, ok := converted[f]; ok {
+			return nil
+		}
+		if _, ok := outputs[absPath(p)]; ok || expectedDirFile(f) {
+			return nil
+		}
+		stray = append(stray, f)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not list %s for strict_dir: %v", cfg.Dir, err)
+	}
+	if len(stray) == 0 {
+		return nil
+	}
+	sort.Strings(stray)
+	listed := strings.Join(stray[:min(len(stray), maxListedStrayFiles)], ", ")
+	if len(stray) > maxListedStrayFiles {
+		listed += fmt.Sprintf(" and %d more", len(stray)-maxListedStrayFiles)
+	}
+	return fmt.Errorf("strict_dir: %s holds files hermes doesn't manage (%s), point dir at a directory of its own", cfg.Dir, listed)
+}
+
+// expectedDirFile reports whether f, relative to dir, is a file hermes
+// writes there
+func expectedDirFile(f string) bool {
+	name := path.Base(f)
+	switch {
+	case f == ".gitignore":
+		return true
+	case strings.HasPrefix(f, licensesDir+"/"):
+		return isLicenseFile(name)
+	case strings.HasPrefix(name, ".") && (strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".part.validator") || strings.Contains(name, ".tmp-")):
+		// left behind by an interrupted download or write
+		return true
+	}
+	return isManagedFile(name)
+}
+
+// absPath is p made absolute for comparisons, or cleaned when the working
+// directory is unknown
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
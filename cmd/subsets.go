@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// subsetFile is one subset file of a variant to install
+type subsetFile struct {
+	variant  string
+	key      string
+	fileName string
+	face     cssFontFace
+}
+
+// installSubsets installs each variant of entry as one file per requested
+// subset. The Developer API only serves whole fonts, so the files come from
+// the per-subset @font-face rules of the CSS API.
+func (in *installer) installSubsets(entry FontEntry, item FontItem, prev, locked *LockedFont) entryResult {
+	var res entryResult
+	all := slices.Contains(entry.Subsets, "all")
+	for _, subset := range entry.Subsets {
+		if subset != "all" && !slices.Contains(item.Subsets, subset) {
+			familyWarning(entry.name(), "%s has no %s subset (available: %v)", entry.Family, subset, item.Subsets)
+		}
+	}
+	var files []subsetFile
+	for _, variant := range entry.Variants {
+		source := variant
+		if _, ok := item.Files[variant]; !ok {
+			sub, found := nearestVariant(variant, item.Files)
+			if !entry.NearestWeight || !found {
+				printError("variant %s not found for %s", variant, entry.Family)
+				fmt.Fprintln(os.Stderr, "Available variants:", item.Variants)
+				exit(1)
+			}
+			familyWarning(entry.name(), "%s has no %s variant, using %s instead", entry.Family, variant, sub)
+			source = sub
+		}
+		css, err := fetchCSS(css2VariantURL(item.Family, source))
+		if err != nil {
+			printError("failed to fetch the subsets of %s (%s): %v", entry.Family, variant, err)
+			exit(1)
+		}
+		for i, face := range parseFontFaces(string(css)) {
+			if !all && !slices.Contains(entry.Subsets, face.Subset) || slices.Contains(entry.SkipSubsets, face.Subset) {
+				continue
+			}
+			kind := subsetKind(face.Subset, i)
+			files = append(files, subsetFile{
+				variant:  variant,
+				key:      variant + " " + kind,
+				fileName: in.sourceFileName(face.URL, item.Family, source, kind),
+				face:     face,
+			})
+		}
+	}
+	keys, urls, fileNames := make([]string, len(files)), make([]string, len(files)), make([]string, len(files))
+	for i, f := range files {
+		keys[i], urls[i], fileNames[i] = f.key, f.face.URL, f.fileName
+	}
+	in.shareRepeatedURLs(entry, item.Family, keys, urls, fileNames)
+	for i := range files {
+		files[i].fileName = fileNames[i]
+	}
+	vs := make([]*LockedVariant, len(files))
+	res.rules = make([]string, len(files))
+	res.outcomes = make([]variantOutcome, len(files))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		var prevVariant *LockedVariant
+		if prev != nil {
+			prevVariant = prev.Variants[f.key]
+		}
+		wg.Add(1)
+		go func(i int, f subsetFile) {
+			defer wg.Done()
+			start := time.Now()
+			defer func() { res.outcomes[i].Duration = time.Since(start) }()
+			v, downloaded := in.fetch(entry, f.key, f.face.URL, f.fileName, prevVariant)
+			if v == nil {
+				res.outcomes[i] = in.outcome(item.Family, f.key, statusInvalidURL)
+				res.outcomes[i].Error = "the provider returned an invalid font URL"
+				return
+			}
+			vs[i] = v
+			rule := genSubsetCSS(in.cfg.cssFamily(item.Family), f.variant, in.orderSrcs(append(in.localSrcs(v), in.variantSrcs(v)...)), f.face.UnicodeRange)
+			rule = addDescriptor(rule, "font-stretch", entry.stretch(f.variant))
+			if w := entry.weight(f.variant); w != "" {
+				rule = setDescriptor(rule, "font-weight", w)
+			}
+			rule = in.addMetrics(rule, entry, v)
+			res.rules[i] = addDescriptor(rule, "font-feature-settings", entry.featureSettings())
+			if v.Remote {
+				res.rules[i] = entry.preloadNote(in.variantSrc(v), v.Integrity) + "\n" + res.rules[i]
+				res.outcomes[i] = in.outcome(item.Family, f.key, statusRemote)
+				return
+			}
+			status := statusUpToDate
+			if downloaded {
+				status = statusDownloaded
+			}
+			in.want(v.File)
+			res.outcomes[i] = in.outcome(item.Family, f.key, status, v.File)
+		}(i, f)
+	}
+	wg.Wait()
+	var rules, subsets, critical, criticalSubsets []string
+	for i, f := range files {
+		if vs[i] != nil {
+			locked.Variants[f.key] = vs[i]
+		}
+		if entry.critical(f.variant) {
+			critical = append(critical, res.rules[i])
+			criticalSubsets = append(criticalSubsets, f.face.Subset)
+		} else {
+			rules = append(rules, res.rules[i])
+			subsets = append(subsets, f.face.Subset)
+		}
+	}
+	res.rules = subsetRules(rules, subsets, entry.GroupSubsets)
+	res.critical = subsetRules(critical, criticalSubsets, entry.GroupSubsets)
+	return res
+}
+
+// shareRepeatedURLs points each file whose url an earlier file already has
+// at that earlier file, so a url the provider repeats across subsets is
+// downloaded once. Each subset still gets its own rule. Sharing is routine,
+// so it is only reported when verbose.
+func (in *installer) shareRepeatedURLs(entry FontEntry, family string, keys, urls, fileNames []string) {
+	first := map[string]int{}
+	for i, u := range urls {
+		j, ok := first[u]
+		if !ok {
+			first[u] = i
+			continue
+		}
+		if fileNames[i] != fileNames[j] {
+			if in.verbose {
+				familyStatus(entry.name(), colorCyan, "Sharing", "%s between %s (%s) and %s (%s), which have the same url", fileNames[j], family, keys[i], family, keys[j])
+			}
+			fileNames[i] = fileNames[j]
+		}
+	}
+}
+
+// subsetRules labels each rule with a comment naming its subset, dropping
+// the empty rules of skipped files. With group, rules are ordered by subset,
+// in the order subsets first appear, under one comment per subset.
+func subsetRules(rules, subsets []string, group bool) []string {
+	order := make([]int, 0, len(rules))
+	for i, rule := range rules {
+		if rule != "" {
+			order = append(order, i)
+		}
+	}
+	if group {
+		first := map[string]int{}
+		for _, i := range order {
+			if _, ok := first[subsets[i]]; !ok {
+				first[subsets[i]] = len(first)
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool { return first[subsets[order[a]]] < first[subsets[order[b]]] })
+	}
+	labeled := make([]string, 0, len(order))
+	for n, i := range order {
+		rule := rules[i]
+		if subsets[i] != "" && (!group || n == 0 || subsets[order[n-1]] != subsets[i]) {
+			rule = "/* " + subsets[i] + " */\n" + rule
+		}
+		labeled = append(labeled, rule)
+	}
+	return labeled
+}
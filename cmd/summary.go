@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// flag variables
+var Summary bool
+var ReportPath string
+
+// outcome statuses
+const (
+	statusDownloaded = "downloaded"
+	statusUpToDate   = "up to date"
+	statusNotFound   = "not found"
+	statusNoFiles    = "no files"
+	statusInvalidURL = "invalid URL"
+	statusRemote     = "remote"
+)
+
+// variantOutcome is the result of installing one requested variant
+type variantOutcome struct {
+	Family  string
+	Variant string
+	Status  string
+	// Size is the total size on disk of the variant's files
+	Size     int64
+	Duration time.Duration
+	// Error explains why a variant was not installed
+	Error string
+}
+
+// outcome is the result of a variant, summing the size of its files in dir
+func (in *installer) outcome(family, variant, status string, files ...string) variantOutcome {
+	o := variantOutcome{Family: family, Variant: variant, Status: status}
+	for _, f := range files {
+		if info, err := os.Stat(in.filePath(f)); err == nil {
+			o.Size += info.Size()
+		}
+	}
+	return o
+}
+
+// printSummary renders the outcomes as an aligned table, followed by the
+// families that ended up with no installed variant
+func printSummary(outcomes []variantOutcome, emptyFamilies []string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FAMILY\tVARIANT\tSTATUS\tSIZE")
+	for _, o := range outcomes {
+		size := "-"
+		if o.Size > 0 {
+			size = formatBytes(o.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.Family, o.Variant, o.Status, size)
+	}
+	w.Flush()
+	if len(emptyFamilies) > 0 {
+		fmt.Println()
+		fmt.Println("No variants installed:", strings.Join(emptyFamilies, ", "))
+	}
+	if WarningsAsErrors {
+		fmt.Println()
+		fmt.Println("Warnings are reported as errors (--warnings-as-errors)")
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// installReport is the machine-readable run report written by --report,
+// and printed by --json
+type installReport struct {
+	resultSchema
+	// OK is unset when the run fails, as with --warnings-as-errors
+	OK         bool      `json:"ok"`
+	Config     string    `json:"config"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Unchanged  bool      `json:"unchanged,omitempty"`
+	DryRun     bool      `json:"dry_run,omitempty"`
+	// EmptyFamilies are the fonts that ended up with no installed variant
+	EmptyFamilies []string        `json:"empty_families"`
+	TotalBytes    int64           `json:"total_bytes"`
+	Variants      []reportVariant `json:"variants"`
+	Errors        []string        `json:"errors"`
+}
+
+type reportVariant struct {
+	Family     string `json:"family"`
+	Variant    string `json:"variant"`
+	Status     string `json:"status"`
+	Bytes      int64  `json:"bytes"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// newInstallReport reports the outcomes of a run started at start
+func newInstallReport(configPath string, start time.Time, outcomes []variantOutcome, emptyFamilies []string, unchanged bool) *installReport {
+	report := &installReport{
+		OK:            !failed(),
+		Config:        configPath,
+		StartedAt:     start,
+		DurationMS:    time.Since(start).Milliseconds(),
+		Unchanged:     unchanged,
+		EmptyFamilies: append([]string{}, emptyFamilies...),
+		Variants:      []reportVariant{},
+		Errors:        []string{},
+	}
+	for _, o := range outcomes {
+		report.TotalBytes += o.Size
+		report.Variants = append(report.Variants, reportVariant{
+			Family:     o.Family,
+			Variant:    o.Variant,
+			Status:     o.Status,
+			Bytes:      o.Size,
+			DurationMS: o.Duration.Milliseconds(),
+			Error:      o.Error,
+		})
+		if o.Error != "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s (%s): %s", o.Family, o.Variant, o.Error))
+		}
+	}
+	return report
+}
+
+// finishReport writes the report of a run to --report and prints it for
+// --json. A dry run's report is only printed.
+func finishReport(report *installReport) {
+	exitHook = nil
+	if ReportPath != "" && !report.DryRun {
+		if err := writeReport(ReportPath, report); err != nil {
+			printError("failed to write report: %v", err)
+			exit(1)
+		}
+	}
+	if InstallJSON {
+		printResult("install", report)
+	}
+}
+
+// writeReport writes a report to path as JSON
+func writeReport(path string, report *installReport) error {
+	report.setSchema(resultSchemaName("install"))
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
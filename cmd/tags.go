@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// flag variables
+var Tags []string
+
+// selectTags splits the entries into those --tags selects and the rest.
+// Untagged entries are always selected, as is everything without --tags.
+func selectTags(fonts []FontEntry, tags []string) (selected, skipped []FontEntry) {
+	if len(tags) == 0 {
+		return fonts, nil
+	}
+	for _, entry := range fonts {
+		if len(entry.Tags) == 0 || slices.ContainsFunc(entry.Tags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+			selected = append(selected, entry)
+		} else {
+			skipped = append(skipped, entry)
+		}
+	}
+	return selected, skipped
+}
+
+// validateTags checks that every --tags tag is set on a font of the config
+func validateTags(fonts []FontEntry, tags []string) error {
+	unknown := []string{}
+	for _, tag := range tags {
+		if !slices.ContainsFunc(fonts, func(entry FontEntry) bool { return slices.Contains(entry.Tags, tag) }) {
+			unknown = append(unknown, tag)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("--tags %s match no font in the config", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// withSkipped adds the files and licenses the lock records for the entries
+// --tags left out to the sets cleanup keeps, so only the selected fonts'
+// stale files are removed
+func withSkipped(lock *FontsLock, skipped []FontEntry, files, licenses map[string]struct{}) (keptFiles, keptLicenses map[string]struct{}) {
+	keptFiles, keptLicenses = maps.Clone(files), maps.Clone(licenses)
+	for _, entry := range skipped {
+		locked, ok := lock.Fonts[entry.name()]
+		if !ok {
+			continue
+		}
+		for _, f := range locked.files() {
+			keptFiles[f] = struct{}{}
+		}
+		if locked.License != "" {
+			keptLicenses[filepath.Base(locked.License)] = struct{}{}
+		}
+	}
+	return keptFiles, keptLicenses
+}
+
+// carrySkipped copies the lock records of the entries --tags left out into
+// newLock, so installing their tags later finds them up to date
+func carrySkipped(lock, newLock *FontsLock, skipped []FontEntry) {
+	for _, entry := range skipped {
+		if locked, ok := lock.Fonts[entry.name()]; ok {
+			newLock.Fonts[entry.name()] = locked
+		}
+	}
+}
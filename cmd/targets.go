@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InstallTarget is an extra build of the installed fonts, rendered from
+// the files install downloaded to dir, so nothing is downloaded twice.
+// Example:
+//
+//	targets:
+//	  - name: cdn
+//	    dir: ./dist/fonts
+//	    stylesheet: ./dist/fonts.css
+//	    base_url: https://cdn.example.com/fonts
+//	  - name: inline
+//	    stylesheet: ./dist/fonts.inline.css
+//	    inline: true
+//	  - name: email
+//	    stylesheet: ./dist/fonts.email.css
+//	    email: true
+//	    format: ttf
+type InstallTarget struct {
+	// Name identifies the target in messages
+	Name string `yaml:"name"`
+	// Dir receives a copy of the font files. Files in it that are no
+	// longer installed are removed, like in dir.
+	Dir string `yaml:"dir,omitempty"`
+	// Stylesheet is the target's CSS file, holding the critical rules too
+	Stylesheet string `yaml:"stylesheet"`
+	// BaseURL is prepended to the file names in the src urls
+	BaseURL string `yaml:"base_url,omitempty"`
+	// Inline embeds each font file in the stylesheet as a data: url,
+	// instead of copying it to Dir
+	Inline bool `yaml:"inline,omitempty"`
+	// Email renders a stylesheet for HTML email: inlined like Inline, with
+	// one src per rule and only the descriptors email clients read, see
+	// emailRule
+	Email bool `yaml:"email,omitempty"`
+	// Format is the format of an email target's fonts, "woff2" (default)
+	// or "ttf", which more email clients can read
+	Format string `yaml:"format,omitempty"`
+}
+
+// inline reports whether the target embeds the files in its stylesheet
+func (t InstallTarget) inline() bool {
+	return t.Inline || t.Email
+}
+
+func validateTargets(cfg *FontsYAML) error {
+	names := map[string]bool{}
+	stylesheets := map[string]string{filepath.Clean(cfg.Stylesheet): "`stylesheet`"}
+	if cfg.CriticalStylesheet != "" {
+		stylesheets[filepath.Clean(cfg.CriticalStylesheet)] = "`critical_stylesheet`"
+	}
+	dirs := map[string]string{filepath.Clean(cfg.Dir): "`dir`"}
+	for i, t := range cfg.Targets {
+		if t.Name == "" {
+			return fmt.Errorf("targets[%d]: `name` not specified", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("target %s is listed twice", t.Name)
+		}
+		names[t.Name] = true
+		if t.Stylesheet == "" || t.Stylesheet == stdoutStylesheet {
+			return fmt.Errorf("target %s: `stylesheet` must be a file", t.Name)
+		}
+		if other, ok := stylesheets[filepath.Clean(t.Stylesheet)]; ok {
+			return fmt.Errorf("target %s: `stylesheet` is also the stylesheet of %s", t.Name, other)
+		}
+		stylesheets[filepath.Clean(t.Stylesheet)] = "target " + t.Name
+		switch {
+		case t.Format != "" && !t.Email:
+			return fmt.Errorf("target %s: `format` is only used with `email: true`", t.Name)
+		case t.Format != "" && t.Format != "woff2" && t.Format != "ttf":
+			return fmt.Errorf("target %s: `format` must be woff2 or ttf, got %q", t.Name, t.Format)
+		case t.inline() && (t.Dir != "" || t.BaseURL != ""):
+			return fmt.Errorf("target %s: `inline` and `email` embed the files and cannot be combined with dir or base_url", t.Name)
+		case !t.inline() && t.Dir == "":
+			return fmt.Errorf("target %s: `dir` not specified, set it or `inline: true`", t.Name)
+		case t.Dir != "":
+			if other, ok := dirs[filepath.Clean(t.Dir)]; ok {
+				return fmt.Errorf("target %s: `dir` is also the dir of %s", t.Name, other)
+			}
+			dirs[filepath.Clean(t.Dir)] = "target " + t.Name
+		}
+	}
+	return nil
+}
+
+// writeTargets renders every target from the rules written for dir and
+// the installed files, copying the files to the target dirs and removing
+// the ones no longer installed, except when --only-family or --tags leave
+// the rest of the fonts out of wanted
+func writeTargets(cfg *FontsYAML, header string, rules []string, wanted map[string]struct{}, verbose bool) error {
+	for _, t := range cfg.Targets {
+		if DryRun {
+			printStatus(colorCyan, "Would write", "target %s: %s", t.Name, t.Stylesheet)
+			if t.Dir != "" && cfg.clean() && OnlyFamily == "" && len(Tags) == 0 {
+				removeUnreferencedFiles(t.Dir, wanted, nil, verbose)
+			}
+			continue
+		}
+		if verbose {
+			fmt.Printf("Writing target %s to %s\n", t.Name, t.Stylesheet)
+		}
+		if t.Dir != "" {
+			if err := copyTargetFiles(cfg.Dir, t.Dir, wanted); err != nil {
+				return fmt.Errorf("target %s: %w", t.Name, err)
+			}
+			if cfg.clean() && OnlyFamily == "" && len(Tags) == 0 {
+				removeUnreferencedFiles(t.Dir, wanted, nil, verbose)
+			}
+		}
+		targetRules := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			var err error
+			if t.Email {
+				rule, err = emailRule(cfg, t, rule)
+			} else {
+				rule, err = targetRule(cfg, t, rule)
+			}
+			if err != nil {
+				return fmt.Errorf("target %s: %w", t.Name, err)
+			}
+			if rule != "" {
+				targetRules = append(targetRules, rule)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(t.Stylesheet), 0755); err != nil {
+			return fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		layer := cfg.CSSLayer
+		if t.Email {
+			// email clients drop the stylesheet at an @layer they can't parse
+			layer = ""
+		}
+		css := renderCSS(header, layer, targetRules)
+		if err := writeCSS(t.Stylesheet, cfg.cssCharset(), css); err != nil {
+			return fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		if t.Email && len(css) > emailClipSize {
+			printWarning("target %s: %s is %s, email clients such as Gmail clip messages over %s, inline fewer variants or use text", t.Name, t.Stylesheet, formatBytes(int64(len(css))), formatBytes(emailClipSize))
+		}
+	}
+	return nil
+}
+
+// targetRule rewrites the src urls of a rule that name files in dir for
+// the target: as data: urls when it is inline, otherwise under its
+// base_url. Urls of fonts that aren't self-hosted are kept.
+func targetRule(cfg *FontsYAML, t InstallTarget, rule string) (string, error) {
+	var err error
+	rule = cssURL.ReplaceAllStringFunc(rule, func(ref string) string {
+		m := cssURL.FindStringSubmatch(ref)
+		raw := m[1] + m[2] + m[3]
+		name, local := localFontFile(raw, cfg.BaseURL)
+		if !local {
+			return ref
+		}
+		if !t.inline() {
+			// keep the cache_bust query
+			query := ""
+			if i := strings.Index(raw, "?"); i >= 0 {
+				query = raw[i:]
+			}
+			u := name + query
+			if t.BaseURL != "" {
+				u = strings.TrimSuffix(t.BaseURL, "/") + "/" + u
+			}
+			return "url('" + u + "')"
+		}
+		data, readErr := os.ReadFile(filepath.Join(cfg.Dir, filepath.FromSlash(name)))
+		if readErr != nil {
+			err = readErr
+			return ref
+		}
+		return "url('" + dataURL(name, data) + "')"
+	})
+	return rule, err
+}
+
+// dataURL embeds the font file name holds as a data: url
+func dataURL(name string, data []byte) string {
+	mime := "application/octet-stream"
+	for _, format := range fontMIMETypes {
+		if path.Ext(name) == "."+format.ext {
+			mime = format.mime
+		}
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// copyTargetFiles copies the wanted files from dir to a target's dir,
+// leaving copies that are already up to date
+func copyTargetFiles(dir, targetDir string, wanted map[string]struct{}) error {
+	names := make([]string, 0, len(wanted))
+	for name := range wanted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(targetDir, filepath.FromSlash(name))
+		if unchanged(target, data) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(target, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// writeTSModule writes a TypeScript module exporting the installed family
+// names, as the stylesheet declares them, and their weights, so frontends
+// can import them instead of typing family names as strings
+func writeTSModule(cfg *FontsYAML, fonts map[string]*LockedFont) error {
+	path := cfg.TSOutput
+	weights := map[string][]int{}
+	// identifiers are derived from the upstream family
+	upstream := map[string]string{}
+	for _, font := range fonts {
+		family := cfg.cssFamily(font.Family)
+		upstream[family] = font.Family
+		if _, ok := weights[family]; !ok {
+			weights[family] = []int{}
+		}
+		for key := range font.Variants {
+			// css_url variants are keyed by variant and subset
+			variant, _, _ := strings.Cut(key, " ")
+			_, weight := variantStyleWeight(variant)
+			// weight ranges of variable fonts, e.g. 100-900, give both ends
+			for _, w := range strings.Split(weight, "-") {
+				if n, err := strconv.Atoi(w); err == nil && !slices.Contains(weights[family], n) {
+					weights[family] = append(weights[family], n)
+				}
+			}
+		}
+	}
+	families := make([]string, 0, len(weights))
+	for family := range weights {
+		families = append(families, family)
+		sort.Ints(weights[family])
+	}
+	sort.Strings(families)
+
+	var b strings.Builder
+	b.WriteString("// Code generated by hermes install. DO NOT EDIT.\n\n")
+	b.WriteString("export const fontFamilies = {\n")
+	for _, family := range families {
+		fmt.Fprintf(&b, "  %s: %q,\n", tsIdentifier(upstream[family]), family)
+	}
+	b.WriteString("} as const;\n\n")
+	b.WriteString("export type FontFamily = (typeof fontFamilies)[keyof typeof fontFamilies];\n\n")
+	b.WriteString("export const fontWeights = {\n")
+	for _, family := range families {
+		ws := make([]string, len(weights[family]))
+		for i, w := range weights[family] {
+			ws[i] = strconv.Itoa(w)
+		}
+		fmt.Fprintf(&b, "  %q: [%s],\n", family, strings.Join(ws, ", "))
+	}
+	b.WriteString("} as const satisfies Record<FontFamily, readonly number[]>;\n\n")
+	b.WriteString("export type FontWeight<F extends FontFamily> = (typeof fontWeights)[F][number];\n")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeOutput(path, []byte(b.String()))
+}
+
+// tsIdentifier turns a family name into a camelCase identifier, e.g.
+// "Open Sans" into openSans
+func tsIdentifier(family string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range family {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = b.Len() > 0
+			continue
+		}
+		switch {
+		case b.Len() == 0:
+			r = unicode.ToLower(r)
+		case upper:
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		upper = false
+	}
+	id := b.String()
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "_" + id
+	}
+	return id
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Refresh looks up every font's metadata instead of installing unchanged
+// entries straight from the lock
+var Refresh bool
+
+// flag variables
+var UpdateSince string
+
+// sinceLastLock is the --since value that compares each family against the
+// catalog date recorded in its lock entry
+const sinceLastLock = "last-lock"
+
+var updateCmd = &cobra.Command{
+	Use:   "update [config]",
+	Short: "Re-resolve every font against the current catalog and update the lock",
+	Long: `Looks up the current catalog entry of every font in the config, even those
+the lock file shows as up to date, and downloads the files whose URLs changed.
+Files that did not change upstream are kept, unlike install --force.
+
+--since limits the refresh to families the catalog changed after a date, e.g.
+2024-05-01, or after their lock entry was written with last-lock. Other
+families keep their locked files.
+
+Each update that changes the fonts appends the families and variants it
+added, removed and updated, and the size change, to the history file,
+fonts.history.md beside fonts.yaml unless the config sets history.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateSince(UpdateSince); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		Refresh = true
+		installCmd.Run(cmd, args)
+	},
+}
+
+func validateSince(since string) error {
+	if since == "" || since == sinceLastLock {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, since); err != nil {
+		return fmt.Errorf("invalid --since %q (expected a date such as 2024-05-01, or %s)", since, sinceLastLock)
+	}
+	return nil
+}
+
+// refresh reports whether entry's metadata is looked up again rather than
+// installed from the lock. Without --since every entry is refreshed.
+func (in *installer) refresh(entry FontEntry) bool {
+	if !Refresh {
+		return false
+	}
+	if UpdateSince == "" {
+		return true
+	}
+	locked, ok := in.lock.Fonts[entry.Family]
+	if !ok || locked.LastModified == "" {
+		return true
+	}
+	live := in.catalogDates[locked.Family]
+	if UpdateSince == sinceLastLock {
+		return live > locked.LastModified
+	}
+	// dates are YYYY-MM-DD, so they compare as strings
+	return live > UpdateSince
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	// update takes the same flags as install, whose init runs first
+	updateCmd.Flags().AddFlagSet(installCmd.Flags())
+	updateCmd.Flags().StringVar(&UpdateSince, "since", "", "Only refresh families the catalog changed after this date (YYYY-MM-DD), or after their lock entry with last-lock")
+}
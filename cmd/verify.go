@@ -0,0 +1,536 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// flag variables
+var VerifyCSS bool
+var VerifyPaths bool
+var PruneStylesheet bool
+var VerifyJSON bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [config]",
+	Short: "Check the installed files against the lock file",
+	Long: `Checks that every file recorded in the lock file exists in dir with its
+recorded checksum. With --css the stylesheet is parsed too: each @font-face
+rule must be well-formed, declare font-family and src, and every local src
+url must name a file in dir. Problems are reported with their line number.
+With --prune-stylesheet the @font-face rules whose files are all missing from
+dir are removed from the stylesheet instead; everything else in it is kept
+as it is. With --paths each relative src url is resolved against the
+stylesheet's directory, as a browser does, and must name an existing file,
+which catches a stylesheet kept outside dir without a matching base_url.
+--json prints the problems as JSON for scripts.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if VerifyJSON {
+			startJSON()
+			// a run ended by an error still prints a result
+			exitHook = func() {
+				printResult("verify", &verifyResult{Problems: []string{}, Pruned: map[string]int{}, Errors: errorMessages()})
+			}
+		}
+		configPath := configFile(args)
+		cfg, err := loadFontsYAML(configPath)
+		if err != nil {
+			printError("could not read YAML: %v", err)
+			exit(1)
+		}
+		if err := validateFontsYAML(cfg); err != nil {
+			printError("%v", err)
+			exit(1)
+		}
+		lockFile := lockPath(configPath)
+		lock, err := readLock(lockFile)
+		if err != nil {
+			printError("could not read lock file %s: %v", lockFile, err)
+			exit(1)
+		}
+		problems := verifyLockedFiles(lock, cfg.Dir)
+		prunedRules := map[string]int{}
+		if VerifyCSS || PruneStylesheet || VerifyPaths {
+			stylesheets := []string{cfg.Stylesheet}
+			if cfg.CriticalStylesheet != "" {
+				stylesheets = append(stylesheets, cfg.CriticalStylesheet)
+			}
+			for _, path := range stylesheets {
+				css, err := cfg.readStylesheet(path)
+				if err != nil {
+					printError("could not read stylesheet: %v", err)
+					exit(1)
+				}
+				if PruneStylesheet {
+					pruned, n, err := pruneStylesheet(css, cfg.Dir, cfg.BaseURL)
+					if err != nil {
+						printError("could not parse %s: %v", path, err)
+						exit(1)
+					}
+					if n > 0 {
+						if err := cfg.writeStylesheet(path, pruned); err != nil {
+							printError("failed to write %s: %v", path, err)
+							exit(1)
+						}
+						printSuccess("Pruned", "%d rule(s) without files from %s", n, path)
+						prunedRules[path] = n
+					}
+					css = pruned
+				}
+				if VerifyCSS || PruneStylesheet {
+					for _, p := range verifyStylesheet(css, cfg.Dir, cfg.BaseURL) {
+						problems = append(problems, path+": "+p)
+					}
+				}
+				if VerifyPaths {
+					for _, p := range verifySrcPaths(css, path, cfg.Dir, cfg.BaseURL) {
+						problems = append(problems, path+": "+p)
+					}
+				}
+			}
+		}
+		if VerifyJSON {
+			exitHook = nil
+			printResult("verify", &verifyResult{OK: len(problems) == 0, Problems: problems, Pruned: prunedRules})
+			if len(problems) > 0 {
+				exit(1)
+			}
+			return
+		}
+		for _, p := range problems {
+			fmt.Printf("%s %s\n", colorize(os.Stdout, colorRed, "[x]"), p)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("\n%d problem(s) found\n", len(problems))
+			exit(1)
+		}
+		fmt.Println("No problems found")
+	},
+}
+
+// verifyLockedFiles reports locked files that are missing from dir or
+// whose contents no longer match the recorded checksum
+func verifyLockedFiles(lock *FontsLock, dir string) []string {
+	problems := []string{}
+	for _, font := range lock.Fonts {
+		for key, v := range font.Variants {
+			for ; v != nil; v = v.Fallback {
+				if v.Remote {
+					continue
+				}
+				path := filepath.Join(dir, v.File)
+				if _, err := os.Stat(path); err != nil {
+					problems = append(problems, fmt.Sprintf("%s (%s): %s is missing", font.Family, key, path))
+				} else if !v.upToDate(dir) {
+					problems = append(problems, fmt.Sprintf("%s (%s): %s does not match its checksum in the lock file", font.Family, key, path))
+				}
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// fontFaceBlock is the body of one @font-face rule and the line it starts on
+type fontFaceBlock struct {
+	line int
+	body string
+	// gap is where the whitespace and comments before the rule begin,
+	// start where the rule does and end just past its closing brace
+	gap, start, end int
+}
+
+// verifyStylesheet parses a generated stylesheet and reports malformed
+// rules, and src urls that don't name a file in dir
+func verifyStylesheet(css, dir, baseURL string) []string {
+	css, err := blankCSSComments(css)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	rules, err := parseFontFaceRules(css, 0, len(css))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	problems := []string{}
+	for _, r := range rules {
+		problems = append(problems, checkFontFaceRule(r, dir, baseURL)...)
+	}
+	return problems
+}
+
+// blankCSSComments replaces comments with spaces, keeping newlines so
+// line numbers still match the file
+func blankCSSComments(css string) (string, error) {
+	b := []byte(css)
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] != '/' || b[i+1] != '*' {
+			continue
+		}
+		end := strings.Index(css[i+2:], "*/")
+		if end < 0 {
+			return "", fmt.Errorf("line %d: comment is never closed", lineAt(css, i))
+		}
+		end += i + 4
+		for j := i; j < end; j++ {
+			if b[j] != '\n' {
+				b[j] = ' '
+			}
+		}
+		i = end - 1
+	}
+	return string(b), nil
+}
+
+// parseFontFaceRules collects the @font-face rules of css[start:end],
+// descending into the @layer, @supports and @media blocks hermes may wrap
+// them in. Apart from a :root rule, anything else is an error.
+func parseFontFaceRules(css string, start, end int) ([]fontFaceBlock, error) {
+	rules := []fontFaceBlock{}
+	i := start
+	for {
+		gap := i
+		for i < end && strings.ContainsRune(" \t\r\n", rune(css[i])) {
+			i++
+		}
+		if i >= end {
+			return rules, nil
+		}
+		open := strings.IndexAny(css[i:end], "{};")
+		if open < 0 {
+			return nil, fmt.Errorf("line %d: unexpected %q", lineAt(css, i), strings.TrimSpace(css[i:end]))
+		}
+		open += i
+		prelude := strings.TrimSpace(css[i:open])
+		switch {
+		case css[open] == '}':
+			return nil, fmt.Errorf("line %d: unmatched }", lineAt(css, open))
+		case css[open] == ';' && strings.HasPrefix(prelude, "@layer"):
+			// a layer order statement, e.g. @layer fonts;
+			i = open + 1
+			continue
+		case css[open] == ';' && strings.HasPrefix(prelude, "@charset") && start == 0 && i == 0:
+			// the stylesheet's encoding, only valid as its very first rule
+			i = open + 1
+			continue
+		case css[open] == ';':
+			return nil, fmt.Errorf("line %d: unexpected %q outside a rule", lineAt(css, i), prelude)
+		}
+		close, err := matchingBrace(css, open, end)
+		if err != nil {
+			return nil, err
+		}
+		at, _, _ := strings.Cut(prelude, " ")
+		switch at {
+		case "@font-face":
+			if prelude != "@font-face" {
+				return nil, fmt.Errorf("line %d: malformed rule %q", lineAt(css, i), prelude)
+			}
+			rules = append(rules, fontFaceBlock{line: lineAt(css, i), body: css[open+1 : close], gap: gap, start: i, end: close + 1})
+		case ":root":
+			// the custom properties of css_variables
+		case "@font-feature-values":
+			// the alternates of features, with their @styleset, @swash
+			// and other blocks
+		case "@layer", "@supports", "@media":
+			inner, err := parseFontFaceRules(css, open+1, close)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, inner...)
+		default:
+			return nil, fmt.Errorf("line %d: unexpected rule %q, the stylesheet should only hold @font-face rules", lineAt(css, i), prelude)
+		}
+		i = close + 1
+	}
+}
+
+// matchingBrace returns the index of the } closing the block opened at
+// css[open], skipping quoted strings
+func matchingBrace(css string, open, end int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := open; i < end; i++ {
+		c := css[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("line %d: block is never closed", lineAt(css, open))
+}
+
+var cssURL = regexp.MustCompile(`url\(\s*(?:'([^']*)'|"([^"]*)"|([^)'"\s]*))\s*\)`)
+
+// checkFontFaceRule reports malformed declarations, missing required
+// descriptors and local src urls without a file in dir
+func checkFontFaceRule(r fontFaceBlock, dir, baseURL string) []string {
+	problems := []string{}
+	report := func(format string, a ...any) {
+		problems = append(problems, fmt.Sprintf("line %d: @font-face ", r.line)+fmt.Sprintf(format, a...))
+	}
+	if strings.ContainsAny(r.body, "{}") {
+		report("has a nested block")
+		return problems
+	}
+	decls := map[string]string{}
+	for _, decl := range splitDeclarations(r.body) {
+		decl = strings.TrimSpace(decl)
+		if decl == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(decl, ":")
+		name, value = strings.TrimSpace(strings.ToLower(name)), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			report("has a malformed declaration %q", decl)
+			continue
+		}
+		decls[name] = value
+	}
+	for _, required := range []string{"font-family", "src"} {
+		if _, ok := decls[required]; !ok {
+			report("has no %s", required)
+		}
+	}
+	src, ok := decls["src"]
+	if !ok {
+		return problems
+	}
+	urls := cssURL.FindAllStringSubmatch(src, -1)
+	// a fallback rule's src is a local() font
+	if len(urls) == 0 && !strings.HasPrefix(src, "local(") {
+		report("src has no url()")
+	}
+	for _, m := range urls {
+		ref := m[1] + m[2] + m[3]
+		if name, local := localFontFile(ref, baseURL); local {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				report("src %s has no file in %s", ref, dir)
+			}
+		}
+	}
+	return problems
+}
+
+// verifySrcPaths resolves the src urls of the stylesheet at path the way
+// a browser does and reports those naming no file. Relative urls resolve
+// against the stylesheet's directory. Root-relative urls under base_url
+// are taken to be served from dir; other absolute urls can't be mapped to
+// a file and are skipped.
+func verifySrcPaths(css, path, dir, baseURL string) []string {
+	css, err := blankCSSComments(css)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	rules, err := parseFontFaceRules(css, 0, len(css))
+	if err != nil {
+		return []string{err.Error()}
+	}
+	problems := []string{}
+	for _, r := range rules {
+		for _, decl := range splitDeclarations(r.body) {
+			name, value, ok := strings.Cut(decl, ":")
+			if !ok || strings.TrimSpace(strings.ToLower(name)) != "src" {
+				continue
+			}
+			for _, m := range cssURL.FindAllStringSubmatch(value, -1) {
+				ref := m[1] + m[2] + m[3]
+				if p := checkSrcPath(ref, path, dir, baseURL); p != "" {
+					problems = append(problems, fmt.Sprintf("line %d: %s", r.line, p))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// checkSrcPath reports a src url of the stylesheet at path that resolves
+// to no file, suggesting the base_url that would fix it when the file is
+// in dir
+func checkSrcPath(ref, path, dir, baseURL string) string {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return ""
+	}
+	name, err := url.PathUnescape(u.Path)
+	if err != nil {
+		name = u.Path
+	}
+	if strings.HasPrefix(name, "/") {
+		file, ok := localFontFile(ref, baseURL)
+		if !ok || baseURL == "" {
+			return ""
+		}
+		if target := filepath.Join(dir, file); !fileExists(target) {
+			return fmt.Sprintf("src %s is served from %s, which doesn't exist", ref, target)
+		}
+		return ""
+	}
+	target := filepath.Join(filepath.Dir(path), filepath.FromSlash(name))
+	if fileExists(target) {
+		return ""
+	}
+	problem := fmt.Sprintf("src %s resolves to %s, which doesn't exist", ref, target)
+	inDir := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(filepath.Dir(path), dir); err == nil && fileExists(inDir) {
+		problem += fmt.Sprintf("; the file is in %s, set base_url to %s", dir, filepath.ToSlash(rel))
+	}
+	return problem
+}
+
+// pruneStylesheet removes the @font-face rules whose src files are all
+// missing from dir, returning the stylesheet and the number removed. Rules
+// with a remote or local() src and anything that isn't an @font-face rule
+// are left alone.
+func pruneStylesheet(css, dir, baseURL string) (string, int, error) {
+	return removeRules(css, func(r fontFaceBlock) bool {
+		files, ok := ruleFiles(r, baseURL, false)
+		if !ok {
+			return false
+		}
+		for _, name := range files {
+			if fileExists(filepath.Join(dir, name)) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// removeRules removes the @font-face rules drop reports, returning the
+// stylesheet and the number removed, or an error when it doesn't parse
+func removeRules(css string, drop func(fontFaceBlock) bool) (string, int, error) {
+	blanked, err := blankCSSComments(css)
+	if err != nil {
+		return "", 0, err
+	}
+	rules, err := parseFontFaceRules(blanked, 0, len(blanked))
+	if err != nil {
+		return "", 0, err
+	}
+	var b strings.Builder
+	last, n := 0, 0
+	for _, r := range rules {
+		if !drop(r) {
+			continue
+		}
+		// take the comments directly above the rule, such as its subset
+		// name, along with it, but not a banner set off by a blank line
+		cut := r.gap
+		if i := strings.LastIndex(css[r.gap:r.start], "\n\n"); i >= 0 {
+			cut = r.gap + i
+		}
+		b.WriteString(css[last:cut])
+		last = r.end
+		n++
+	}
+	b.WriteString(css[last:])
+	return b.String(), n, nil
+}
+
+// ruleFiles returns the files in dir named by a rule's src urls, reporting
+// false when the src has a url that isn't local or no url at all. With
+// localFonts false a src naming a local() font reports false too.
+func ruleFiles(r fontFaceBlock, baseURL string, localFonts bool) ([]string, bool) {
+	for _, decl := range splitDeclarations(r.body) {
+		name, value, ok := strings.Cut(decl, ":")
+		if !ok || strings.TrimSpace(strings.ToLower(name)) != "src" {
+			continue
+		}
+		urls := cssURL.FindAllStringSubmatch(value, -1)
+		if len(urls) == 0 || (!localFonts && strings.Contains(value, "local(")) {
+			return nil, false
+		}
+		files := make([]string, len(urls))
+		for i, m := range urls {
+			name, local := localFontFile(m[1]+m[2]+m[3], baseURL)
+			if !local {
+				return nil, false
+			}
+			files[i] = name
+		}
+		return files, true
+	}
+	return nil, false
+}
+
+// localFontFile maps a src url to the file in dir it refers to. Urls on
+// other hosts, such as fonts that aren't self-hosted, are not local.
+func localFontFile(ref, baseURL string) (string, bool) {
+	if baseURL != "" {
+		prefix := strings.TrimSuffix(baseURL, "/") + "/"
+		if !strings.HasPrefix(ref, prefix) {
+			return "", false
+		}
+		ref = strings.TrimPrefix(ref, prefix)
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	name, err := url.PathUnescape(u.Path)
+	if err != nil {
+		name = u.Path
+	}
+	return name, true
+}
+
+// splitDeclarations splits a rule body on the semicolons outside quotes
+// and parentheses
+func splitDeclarations(body string) []string {
+	decls := []string{}
+	depth, last := 0, 0
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ';' && depth == 0:
+			decls = append(decls, body[last:i])
+			last = i + 1
+		}
+	}
+	return append(decls, body[last:])
+}
+
+// lineAt is the 1-based line number of offset i in s
+func lineAt(s string, i int) int {
+	return strings.Count(s[:i], "\n") + 1
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVar(&ConfigFlag, "config", "", "Path to the config file (default $HERMES_CONFIG or fonts.yaml)")
+	verifyCmd.Flags().BoolVar(&VerifyCSS, "css", false, "Also parse the stylesheet and check that each @font-face rule is well-formed and its src files exist")
+	verifyCmd.Flags().BoolVar(&VerifyPaths, "paths", false, "Resolve each src url of the stylesheet against its location, as a browser does, and check that it names an existing file")
+	verifyCmd.Flags().BoolVar(&PruneStylesheet, "prune-stylesheet", false, "Remove the @font-face rules whose files are all missing from dir from the stylesheet, then check it as with --css")
+	verifyCmd.Flags().BoolVar(&VerifyJSON, "json", false, "Print the result as JSON, described by hermes schema --result verify")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// build information, set at build time with
+// -ldflags "-X github.com/cadensstudio/hermes/cmd.Version=v1.2.3 -X ..."
+var (
+	Version = "dev"
+	Commit  = "none"
+	Date    = "unknown"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version and build information",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(versionInfo())
+	},
+}
+
+// versionInfo describes the build. Builds made without ldflags, such as with
+// go install, fall back to the module version and vcs info Go embeds.
+func versionInfo() string {
+	version, commit, date := Version, Commit, Date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && commit == "none":
+				commit = s.Value
+			case s.Key == "vcs.time" && date == "unknown":
+				date = s.Value
+			}
+		}
+	}
+	return fmt.Sprintf("hermes %s\ncommit: %s\nbuilt: %s\ngo: %s %s/%s\n", version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	// --version prints the same details as the version command
+	rootCmd.Version = Version
+	cobra.AddTemplateFunc("versionInfo", versionInfo)
+	rootCmd.SetVersionTemplate("{{versionInfo}}")
+}
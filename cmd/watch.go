@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the config must stay quiet before a reinstall,
+// as editors often write a file in several steps
+const watchDebounce = 300 * time.Millisecond
+
+// watchInstall installs, then reinstalls whenever the config changes, until
+// interrupted. Each run is a separate hermes process so a failed install
+// reports its errors without ending the watch. The lock file keeps runs
+// incremental: only new or changed variants are downloaded.
+func watchInstall(configPath string) {
+	exe, err := os.Executable()
+	if err != nil {
+		printError("cannot find the hermes executable: %v", err)
+		exit(1)
+	}
+	args := []string{}
+	for _, arg := range os.Args[1:] {
+		if arg != "--watch" && !strings.HasPrefix(arg, "--watch=") {
+			args = append(args, arg)
+		}
+	}
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		printError("%v", err)
+		exit(1)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		printError("cannot watch %s: %v", configPath, err)
+		exit(1)
+	}
+	defer watcher.Close()
+	// watch the directory, as editors that save by renaming replace the file
+	if err := watcher.Add(filepath.Dir(absConfig)); err != nil {
+		printError("cannot watch %s: %v", configPath, err)
+		exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runWatchedInstall(exe, args, configPath)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == absConfig && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			printWarning("watching %s: %v", configPath, err)
+		case <-debounce:
+			debounce = nil
+			if ctx.Err() == nil {
+				runWatchedInstall(exe, args, configPath)
+			}
+		}
+	}
+}
+
+// runWatchedInstall runs one install and prints a one-line result
+func runWatchedInstall(exe string, args []string, configPath string) {
+	start := time.Now()
+	install := exec.Command(exe, args...)
+	install.Stdin, install.Stdout, install.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := install.Run()
+	took := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		printStatus(colorRed, "Failed:", "install of %s after %s: %v", configPath, took, err)
+	} else {
+		printSuccess("Installed", "%s in %s", configPath, took)
+	}
+	printStatus(colorCyan, "Watching", "%s for changes, Ctrl-C to stop", configPath)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// validateWeight checks a font-weight value: normal, bold, a number from 1
+// to 1000 such as 450, or a range of two numbers such as "100 900" for
+// variable fonts with a weight axis
+func validateWeight(value string) error {
+	if value == "normal" || value == "bold" {
+		return nil
+	}
+	parts := strings.Fields(value)
+	if len(parts) == 0 || len(parts) > 2 {
+		return fmt.Errorf("invalid weight %q (expected normal, bold, a number or a range of two numbers)", value)
+	}
+	for _, p := range parts {
+		if n, err := strconv.ParseFloat(p, 64); err != nil || n < 1 || n > 1000 {
+			return fmt.Errorf("invalid weight %q (expected numbers from 1 to 1000, such as 450)", value)
+		}
+	}
+	return nil
+}
+
+// weight is the font-weight override of one of the entry's variants, if any
+func (e FontEntry) weight(variant string) string {
+	return e.Weight[variant]
+}
@@ -0,0 +1,804 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// woff2KnownTags are the tables a WOFF2 directory entry can name by index
+// https://www.w3.org/TR/WOFF2/#table_dir_format
+var woff2KnownTags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ",
+	"fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT", "EBLC", "gasp",
+	"hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE", "GDEF",
+	"GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL",
+	"SVG ", "sbix", "acnt", "avar", "bdat", "bloc", "bsln", "cvar", "fdsc",
+	"feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar", "mort", "morx",
+	"opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+var errWOFF2Truncated = errors.New("font data is truncated")
+
+// sfntTable is one table of a decoded font
+type sfntTable struct {
+	tag  string
+	data []byte
+}
+
+// woff2ToWOFF converts a WOFF2 file to WOFF 1.0 for browsers that support
+// woff but not woff2: the font is decoded to its sfnt tables, which are
+// then zlib-compressed one by one. Metadata and private data are dropped.
+func woff2ToWOFF(data []byte) ([]byte, error) {
+	flavor, tables, err := decodeWOFF2(data)
+	if err != nil {
+		return nil, err
+	}
+	return sfntToWOFF(encodeSFNT(flavor, tables))
+}
+
+// sfntData decodes a WOFF2 or WOFF font to the sfnt it was compressed
+// from, and returns other fonts, such as ttf files, as they are
+func sfntData(data []byte) ([]byte, error) {
+	decode := decodeWOFF2
+	switch {
+	case bytes.HasPrefix(data, woff2Signature):
+	case bytes.HasPrefix(data, []byte("wOFF")):
+		decode = decodeWOFF
+	default:
+		return data, nil
+	}
+	flavor, tables, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSFNT(flavor, tables), nil
+}
+
+// woffFileName names the woff converted from a woff2 file, moving it from
+// the woff2 to the woff directory with split_by_format
+func woffFileName(fileName string) string {
+	if rest, ok := strings.CutPrefix(fileName, "woff2/"); ok {
+		fileName = "woff/" + rest
+	}
+	return strings.TrimSuffix(fileName, ".woff2") + ".woff"
+}
+
+// convertedWOFFs are the woffs lock records as converted with convert_woff,
+// which cleanup removes once unreferenced. Without convert_woff, and for
+// woffs hermes didn't convert, such as ones placed in dir by hand, a woff
+// is left alone.
+func convertedWOFFs(cfg *FontsYAML, lock *FontsLock) map[string]struct{} {
+	files := map[string]struct{}{}
+	if !cfg.ConvertWOFF {
+		return files
+	}
+	for _, font := range lock.Fonts {
+		for _, v := range font.Variants {
+			for ; v != nil; v = v.Fallback {
+				if v.WOFF != "" {
+					files[v.WOFF] = struct{}{}
+				}
+			}
+		}
+	}
+	return files
+}
+
+// convertWOFFFile writes the woff converted from the woff2 at src to dst.
+// It reports false, converting nothing, when existing, an earlier converted
+// woff, is at least as new as src.
+func convertWOFFFile(src, dst, existing string) (bool, error) {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if info, err := os.Stat(existing); err == nil && !info.ModTime().Before(srcInfo.ModTime()) {
+		return false, nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	if data, err = woff2ToWOFF(data); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(dst, data)
+}
+
+// woff2Entry is a table directory entry of a WOFF2 file
+type woff2Entry struct {
+	tag     string
+	version byte
+	// origLength is the table's size once decoded, length its size in the
+	// compressed stream
+	origLength, length uint32
+}
+
+// transformed reports whether the table is stored with a WOFF2 transform.
+// glyf and loca are transformed unless their version is 3, other tables
+// only with a non-zero version.
+func (e woff2Entry) transformed() bool {
+	if e.tag == "glyf" || e.tag == "loca" {
+		return e.version != 3
+	}
+	return e.version != 0
+}
+
+// decodeWOFF2 returns the sfnt flavor and tables of a WOFF2 file, undoing
+// the glyf, loca and hmtx transforms
+// https://www.w3.org/TR/WOFF2/#table_format
+func decodeWOFF2(data []byte) (uint32, []sfntTable, error) {
+	if err := checkWOFF2(data); err != nil {
+		return 0, nil, err
+	}
+	var h woff2Header
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &h); err != nil {
+		return 0, nil, err
+	}
+	if h.Flavor == 0x74746366 { // 'ttcf'
+		return 0, nil, errors.New("font collections are not supported")
+	}
+	entries := make([]woff2Entry, h.NumTables)
+	off := woff2HeaderSize
+	for i := range entries {
+		flags := data[off]
+		off++
+		e := woff2Entry{version: flags >> 6}
+		if flags&0x3f == 0x3f {
+			e.tag = string(data[off : off+4])
+			off += 4
+		} else {
+			e.tag = woff2KnownTags[flags&0x3f]
+		}
+		var err error
+		if e.origLength, off, err = readBase128(data, off); err != nil {
+			return 0, nil, err
+		}
+		e.length = e.origLength
+		if e.transformed() {
+			if e.length, off, err = readBase128(data, off); err != nil {
+				return 0, nil, err
+			}
+		}
+		entries[i] = e
+	}
+	stream, err := io.ReadAll(brotli.NewReader(bytes.NewReader(data[off : off+int(h.TotalCompressedSize)])))
+	if err != nil {
+		return 0, nil, fmt.Errorf("could not decompress the font data: %v", err)
+	}
+	raw := map[string][]byte{}
+	pos := 0
+	for _, e := range entries {
+		if uint64(pos)+uint64(e.length) > uint64(len(stream)) {
+			return 0, nil, fmt.Errorf("table %s: %w", e.tag, errWOFF2Truncated)
+		}
+		raw[e.tag] = stream[pos : pos+int(e.length)]
+		pos += int(e.length)
+	}
+
+	tables := make([]sfntTable, 0, len(entries))
+	var xMins []int16
+	for _, e := range entries {
+		switch {
+		case e.tag == "glyf" && e.transformed():
+			glyf, loca, mins, err := reconstructGlyf(raw["glyf"])
+			if err != nil {
+				return 0, nil, fmt.Errorf("table glyf: %v", err)
+			}
+			xMins = mins
+			for _, l := range entries {
+				if l.tag == "loca" && l.origLength != uint32(len(loca)) {
+					return 0, nil, fmt.Errorf("table loca: rebuilt %d bytes, expected %d", len(loca), l.origLength)
+				}
+			}
+			tables = append(tables, sfntTable{"glyf", glyf}, sfntTable{"loca", loca})
+		case e.tag == "loca" && e.transformed():
+			// rebuilt along with glyf
+		case e.tag == "hmtx" && e.transformed():
+			// rebuilt once glyf has given the glyphs' xMin
+		case e.transformed():
+			return 0, nil, fmt.Errorf("table %s uses unknown transform %d", e.tag, e.version)
+		default:
+			tables = append(tables, sfntTable{e.tag, raw[e.tag]})
+		}
+	}
+	for _, e := range entries {
+		if e.tag != "hmtx" || !e.transformed() {
+			continue
+		}
+		hhea := raw["hhea"]
+		if xMins == nil || len(hhea) < 36 {
+			return 0, nil, errors.New("table hmtx: transformed without a transformed glyf and hhea")
+		}
+		hmtx, err := reconstructHmtx(raw["hmtx"], int(binary.BigEndian.Uint16(hhea[34:])), xMins)
+		if err != nil {
+			return 0, nil, fmt.Errorf("table hmtx: %v", err)
+		}
+		tables = append(tables, sfntTable{"hmtx", hmtx})
+	}
+	return h.Flavor, tables, nil
+}
+
+// readBase128 reads the UIntBase128 value at off and returns the offset
+// just past it
+func readBase128(data []byte, off int) (uint32, int, error) {
+	var v uint32
+	for i := 0; i < 5; i++ {
+		if off >= len(data) {
+			return 0, 0, errors.New("value is truncated")
+		}
+		b := data[off]
+		off++
+		if i == 0 && b == 0x80 {
+			return 0, 0, errors.New("value has leading zeros")
+		}
+		if v&0xfe000000 != 0 {
+			return 0, 0, errors.New("value overflows 32 bits")
+		}
+		v = v<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return v, off, nil
+		}
+	}
+	return 0, 0, errors.New("value is longer than 5 bytes")
+}
+
+// woff2Stream reads one of the substreams of a transformed table. Reading
+// past its end records errWOFF2Truncated and yields zeros.
+type woff2Stream struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (s *woff2Stream) bytes(n int) []byte {
+	if s.err != nil || n > len(s.data)-s.off {
+		s.err = errWOFF2Truncated
+		return make([]byte, n)
+	}
+	b := s.data[s.off : s.off+n]
+	s.off += n
+	return b
+}
+
+func (s *woff2Stream) u8() byte {
+	return s.bytes(1)[0]
+}
+
+func (s *woff2Stream) u16() uint16 {
+	return binary.BigEndian.Uint16(s.bytes(2))
+}
+
+// u255 reads a 255UInt16
+// https://www.w3.org/TR/WOFF2/#glyf_table_format
+func (s *woff2Stream) u255() uint16 {
+	switch code := s.u8(); code {
+	case 253:
+		return s.u16()
+	case 254:
+		return 253*2 + uint16(s.u8())
+	case 255:
+		return 253 + uint16(s.u8())
+	default:
+		return uint16(code)
+	}
+}
+
+// glyphPoint is a point of a simple glyph, in absolute coordinates
+type glyphPoint struct {
+	x, y    int
+	onCurve bool
+}
+
+// reconstructGlyf rebuilds the glyf and loca tables from a transformed
+// glyf table, also returning each glyph's xMin for the hmtx transform
+// https://www.w3.org/TR/WOFF2/#glyf_table_format
+func reconstructGlyf(t []byte) (glyf, loca []byte, xMins []int16, err error) {
+	if len(t) < 36 {
+		return nil, nil, nil, errWOFF2Truncated
+	}
+	optionFlags := binary.BigEndian.Uint16(t[2:])
+	numGlyphs := int(binary.BigEndian.Uint16(t[4:]))
+	indexFormat := binary.BigEndian.Uint16(t[6:])
+	streams := make([]*woff2Stream, 7)
+	for i := range streams {
+		size := int(binary.BigEndian.Uint32(t[8+4*i:]))
+		start := 36 + sumStreamSizes(t, i)
+		if start+size > len(t) {
+			return nil, nil, nil, errWOFF2Truncated
+		}
+		streams[i] = &woff2Stream{data: t[start : start+size]}
+	}
+	overlap := []byte{}
+	if optionFlags&1 != 0 {
+		start := 36 + sumStreamSizes(t, 7)
+		n := (numGlyphs + 7) / 8
+		if start+n > len(t) {
+			return nil, nil, nil, errWOFF2Truncated
+		}
+		overlap = t[start : start+n]
+	}
+	nContours, nPoints, flags, glyphs, composites, bboxes, instructions := streams[0], streams[1], streams[2], streams[3], streams[4], streams[5], streams[6]
+	bboxBitmap := bboxes.bytes(((numGlyphs + 31) >> 5) << 2)
+
+	var out bytes.Buffer
+	offsets := make([]uint32, 0, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	for i := 0; i < numGlyphs; i++ {
+		offsets = append(offsets, uint32(out.Len()))
+		hasBBox := bboxBitmap[i>>3]&(0x80>>(i&7)) != 0
+		n := int16(nContours.u16())
+		var bbox [4]int16
+		if hasBBox {
+			for j := range bbox {
+				bbox[j] = int16(bboxes.u16())
+			}
+		}
+		switch {
+		case n == 0:
+			if hasBBox {
+				return nil, nil, nil, fmt.Errorf("glyph %d is empty but has a bounding box", i)
+			}
+		case n > 0:
+			endPts := make([]uint16, n)
+			total := 0
+			for j := range endPts {
+				total += int(nPoints.u255())
+				endPts[j] = uint16(total - 1)
+			}
+			points := decodeTriplets(flags, glyphs, total)
+			if !hasBBox {
+				bbox = pointsBBox(points)
+			}
+			instructionLength := glyphs.u255()
+			writeSimpleGlyph(&out, bbox, endPts, instructions.bytes(int(instructionLength)), points, i < len(overlap)*8 && overlap[i>>3]&(0x80>>(i&7)) != 0)
+		case n == -1:
+			if !hasBBox {
+				return nil, nil, nil, fmt.Errorf("composite glyph %d has no bounding box", i)
+			}
+			component, hasInstructions := readComposite(composites)
+			binary.Write(&out, binary.BigEndian, n)
+			binary.Write(&out, binary.BigEndian, bbox)
+			out.Write(component)
+			if hasInstructions {
+				instructionLength := glyphs.u255()
+				binary.Write(&out, binary.BigEndian, instructionLength)
+				out.Write(instructions.bytes(int(instructionLength)))
+			}
+		default:
+			return nil, nil, nil, fmt.Errorf("glyph %d has an invalid contour count %d", i, n)
+		}
+		for _, s := range streams {
+			if s.err != nil {
+				return nil, nil, nil, fmt.Errorf("glyph %d: %v", i, s.err)
+			}
+		}
+		xMins[i] = bbox[0]
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	offsets = append(offsets, uint32(out.Len()))
+
+	var l bytes.Buffer
+	for _, o := range offsets {
+		if indexFormat == 0 {
+			if o/2 > 0xffff {
+				return nil, nil, nil, errors.New("glyph data is too large for short loca offsets")
+			}
+			binary.Write(&l, binary.BigEndian, uint16(o/2))
+		} else {
+			binary.Write(&l, binary.BigEndian, o)
+		}
+	}
+	return out.Bytes(), l.Bytes(), xMins, nil
+}
+
+// sumStreamSizes adds up the sizes of the first n substreams of a
+// transformed glyf table
+func sumStreamSizes(t []byte, n int) int {
+	sum := 0
+	for i := 0; i < n; i++ {
+		sum += int(binary.BigEndian.Uint32(t[8+4*i:]))
+	}
+	return sum
+}
+
+// decodeTriplets reads the coordinates of n points, one flag byte each
+// from flags and their deltas from glyphs
+// https://www.w3.org/TR/WOFF2/#triplet_decoding
+func decodeTriplets(flags, glyphs *woff2Stream, n int) []glyphPoint {
+	withSign := func(flag byte, v int) int {
+		if flag&1 != 0 {
+			return v
+		}
+		return -v
+	}
+	points := make([]glyphPoint, n)
+	x, y := 0, 0
+	for i := range points {
+		flag := flags.u8()
+		onCurve := flag&0x80 == 0
+		flag &= 0x7f
+		var dx, dy int
+		switch {
+		case flag < 10:
+			dy = withSign(flag, int(flag&14)<<7+int(glyphs.u8()))
+		case flag < 20:
+			dx = withSign(flag, int((flag-10)&14)<<7+int(glyphs.u8()))
+		case flag < 84:
+			b0, b1 := int(flag-20), int(glyphs.u8())
+			dx = withSign(flag, 1+b0&0x30+b1>>4)
+			dy = withSign(flag>>1, 1+(b0&0x0c)<<2+b1&0x0f)
+		case flag < 120:
+			b0, in := int(flag-84), glyphs.bytes(2)
+			dx = withSign(flag, 1+(b0/12)<<8+int(in[0]))
+			dy = withSign(flag>>1, 1+((b0%12)>>2)<<8+int(in[1]))
+		case flag < 124:
+			in := glyphs.bytes(3)
+			dx = withSign(flag, int(in[0])<<4+int(in[1])>>4)
+			dy = withSign(flag>>1, int(in[1]&0x0f)<<8+int(in[2]))
+		default:
+			in := glyphs.bytes(4)
+			dx = withSign(flag, int(in[0])<<8+int(in[1]))
+			dy = withSign(flag>>1, int(in[2])<<8+int(in[3]))
+		}
+		x, y = x+dx, y+dy
+		points[i] = glyphPoint{x: x, y: y, onCurve: onCurve}
+	}
+	return points
+}
+
+// pointsBBox is the bounding box of a simple glyph without an explicit one
+func pointsBBox(points []glyphPoint) [4]int16 {
+	if len(points) == 0 {
+		return [4]int16{}
+	}
+	xMin, yMin, xMax, yMax := points[0].x, points[0].y, points[0].x, points[0].y
+	for _, p := range points[1:] {
+		xMin, xMax = min(xMin, p.x), max(xMax, p.x)
+		yMin, yMax = min(yMin, p.y), max(yMax, p.y)
+	}
+	return [4]int16{int16(xMin), int16(yMin), int16(xMax), int16(yMax)}
+}
+
+// simple glyph flags of the glyf table
+const (
+	glyfOnCurve       = 0x01
+	glyfXShort        = 0x02
+	glyfYShort        = 0x04
+	glyfRepeat        = 0x08
+	glyfXSame         = 0x10
+	glyfYSame         = 0x20
+	glyfOverlapSimple = 0x40
+)
+
+// writeSimpleGlyph encodes a simple glyph in the glyf table format,
+// storing each coordinate delta in as few bytes as it fits
+func writeSimpleGlyph(out *bytes.Buffer, bbox [4]int16, endPts []uint16, instructions []byte, points []glyphPoint, overlap bool) {
+	binary.Write(out, binary.BigEndian, int16(len(endPts)))
+	binary.Write(out, binary.BigEndian, bbox)
+	binary.Write(out, binary.BigEndian, endPts)
+	binary.Write(out, binary.BigEndian, uint16(len(instructions)))
+	out.Write(instructions)
+
+	var flags, xs, ys []byte
+	lastFlag, repeat := -1, 0
+	px, py := 0, 0
+	for i, p := range points {
+		var flag byte
+		if p.onCurve {
+			flag = glyfOnCurve
+		}
+		if i == 0 && overlap {
+			flag |= glyfOverlapSimple
+		}
+		dx, dy := p.x-px, p.y-py
+		px, py = p.x, p.y
+		switch {
+		case dx == 0:
+			flag |= glyfXSame
+		case dx > -256 && dx < 256:
+			flag |= glyfXShort
+			if dx > 0 {
+				flag |= glyfXSame
+			}
+			xs = append(xs, byte(abs(dx)))
+		default:
+			xs = binary.BigEndian.AppendUint16(xs, uint16(int16(dx)))
+		}
+		switch {
+		case dy == 0:
+			flag |= glyfYSame
+		case dy > -256 && dy < 256:
+			flag |= glyfYShort
+			if dy > 0 {
+				flag |= glyfYSame
+			}
+			ys = append(ys, byte(abs(dy)))
+		default:
+			ys = binary.BigEndian.AppendUint16(ys, uint16(int16(dy)))
+		}
+		if int(flag) == lastFlag && repeat < 255 {
+			flags[len(flags)-1] |= glyfRepeat
+			repeat++
+		} else {
+			if repeat > 0 {
+				flags = append(flags, byte(repeat))
+			}
+			flags = append(flags, flag)
+			repeat = 0
+		}
+		lastFlag = int(flag)
+	}
+	if repeat > 0 {
+		flags = append(flags, byte(repeat))
+	}
+	out.Write(flags)
+	out.Write(xs)
+	out.Write(ys)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// readComposite reads the components of a composite glyph, which are
+// stored as in the glyf table, reporting whether it has instructions
+func readComposite(s *woff2Stream) ([]byte, bool) {
+	const (
+		argsAreWords    = 0x0001
+		haveScale       = 0x0008
+		moreComponents  = 0x0020
+		haveXYScale     = 0x0040
+		haveTwoByTwo    = 0x0080
+		haveInstruction = 0x0100
+	)
+	start := s.off
+	hasInstructions := false
+	for {
+		flags := s.u16()
+		s.u16() // glyph index
+		n := 2
+		if flags&argsAreWords != 0 {
+			n = 4
+		}
+		switch {
+		case flags&haveScale != 0:
+			n += 2
+		case flags&haveXYScale != 0:
+			n += 4
+		case flags&haveTwoByTwo != 0:
+			n += 8
+		}
+		s.bytes(n)
+		hasInstructions = hasInstructions || flags&haveInstruction != 0
+		if s.err != nil || flags&moreComponents == 0 {
+			break
+		}
+	}
+	if s.err != nil {
+		return nil, false
+	}
+	return s.data[start:s.off], hasInstructions
+}
+
+// reconstructHmtx rebuilds the hmtx table from its transformed form, in
+// which left side bearings equal to the glyph's xMin are left out
+// https://www.w3.org/TR/WOFF2/#hmtx_table_format
+func reconstructHmtx(t []byte, numHMetrics int, xMins []int16) ([]byte, error) {
+	numGlyphs := len(xMins)
+	if len(t) < 1 || numHMetrics < 1 || numHMetrics > numGlyphs {
+		return nil, errors.New("invalid transformed table")
+	}
+	flags := t[0]
+	if flags&0xfc != 0 {
+		return nil, fmt.Errorf("reserved flags %#x are set", flags)
+	}
+	s := &woff2Stream{data: t[1:]}
+	advances := make([]uint16, numHMetrics)
+	for i := range advances {
+		advances[i] = s.u16()
+	}
+	lsbs := append([]int16{}, xMins...)
+	if flags&1 == 0 {
+		for i := 0; i < numHMetrics; i++ {
+			lsbs[i] = int16(s.u16())
+		}
+	}
+	if flags&2 == 0 {
+		for i := numHMetrics; i < numGlyphs; i++ {
+			lsbs[i] = int16(s.u16())
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	var out bytes.Buffer
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			binary.Write(&out, binary.BigEndian, advances[i])
+		}
+		binary.Write(&out, binary.BigEndian, lsb)
+	}
+	return out.Bytes(), nil
+}
+
+// tableChecksum is the sfnt checksum of a table, the sum of its big-endian
+// 32-bit words
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// encodeSFNT lays out tables as an sfnt font, setting the head table's
+// checkSumAdjustment
+func encodeSFNT(flavor uint32, tables []sfntTable) []byte {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].tag < tables[j].tag })
+	for i, t := range tables {
+		if t.tag == "head" && len(t.data) >= 12 {
+			// the adjustment is computed with the field zeroed
+			tables[i].data = append([]byte{}, t.data...)
+			binary.BigEndian.PutUint32(tables[i].data[8:], 0)
+		}
+	}
+	n := len(tables)
+	entrySelector := bits.Len(uint(n)) - 1
+	searchRange := 16 << entrySelector
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, flavor)
+	binary.Write(&out, binary.BigEndian, []uint16{uint16(n), uint16(searchRange), uint16(entrySelector), uint16(n*16 - searchRange)})
+	offset := 12 + 16*n
+	headOffset := -1
+	for _, t := range tables {
+		if t.tag == "head" && len(t.data) >= 12 {
+			headOffset = offset
+		}
+		out.WriteString(t.tag)
+		binary.Write(&out, binary.BigEndian, []uint32{tableChecksum(t.data), uint32(offset), uint32(len(t.data))})
+		offset += (len(t.data) + 3) &^ 3
+	}
+	for _, t := range tables {
+		out.Write(t.data)
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	sfnt := out.Bytes()
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(sfnt[headOffset+8:], 0xB1B0AFBA-tableChecksum(sfnt))
+	}
+	return sfnt
+}
+
+// sfntToWOFF packages an sfnt font as WOFF 1.0, compressing each table
+// that zlib makes smaller
+// https://www.w3.org/TR/WOFF/
+func sfntToWOFF(sfnt []byte) ([]byte, error) {
+	if len(sfnt) < 12 {
+		return nil, errWOFF2Truncated
+	}
+	flavor := binary.BigEndian.Uint32(sfnt)
+	n := int(binary.BigEndian.Uint16(sfnt[4:]))
+	if len(sfnt) < 12+16*n {
+		return nil, errWOFF2Truncated
+	}
+	type table struct {
+		tag                string
+		checksum, origLen  uint32
+		data               []byte
+		offset, compLength uint32
+	}
+	tables := make([]table, n)
+	var major, minor uint16
+	totalSfntSize := uint32(12 + 16*n)
+	for i := range tables {
+		rec := sfnt[12+16*i:]
+		t := table{tag: string(rec[:4]), checksum: binary.BigEndian.Uint32(rec[4:])}
+		offset, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		if uint64(offset)+uint64(length) > uint64(len(sfnt)) {
+			return nil, fmt.Errorf("table %s: %w", t.tag, errWOFF2Truncated)
+		}
+		orig := sfnt[offset : offset+length]
+		t.origLen = length
+		totalSfntSize += (length + 3) &^ 3
+		if t.tag == "head" && len(orig) >= 8 {
+			// WOFF carries the font revision as its version
+			major, minor = binary.BigEndian.Uint16(orig[4:]), binary.BigEndian.Uint16(orig[6:])
+		}
+		var compressed bytes.Buffer
+		w, _ := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+		w.Write(orig)
+		w.Close()
+		t.data = orig
+		if compressed.Len() < len(orig) {
+			t.data = compressed.Bytes()
+		}
+		t.compLength = uint32(len(t.data))
+		tables[i] = t
+	}
+	offset := uint32(44 + 20*n)
+	for i := range tables {
+		tables[i].offset = offset
+		offset += (tables[i].compLength + 3) &^ 3
+	}
+	var out bytes.Buffer
+	out.WriteString("wOFF")
+	binary.Write(&out, binary.BigEndian, []uint32{flavor, offset})
+	binary.Write(&out, binary.BigEndian, []uint16{uint16(n), 0})
+	binary.Write(&out, binary.BigEndian, totalSfntSize)
+	binary.Write(&out, binary.BigEndian, []uint16{major, minor})
+	// no metadata or private data
+	binary.Write(&out, binary.BigEndian, make([]uint32, 5))
+	for _, t := range tables {
+		out.WriteString(t.tag)
+		binary.Write(&out, binary.BigEndian, []uint32{t.offset, t.compLength, t.origLen, t.checksum})
+	}
+	for _, t := range tables {
+		out.Write(t.data)
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// decodeWOFF reads the flavor and tables of a WOFF 1.0 font, inflating the
+// tables zlib compressed
+// https://www.w3.org/TR/WOFF/
+func decodeWOFF(data []byte) (uint32, []sfntTable, error) {
+	if len(data) < 44 {
+		return 0, nil, errWOFF2Truncated
+	}
+	flavor := binary.BigEndian.Uint32(data[4:])
+	n := int(binary.BigEndian.Uint16(data[12:]))
+	if len(data) < 44+20*n {
+		return 0, nil, errWOFF2Truncated
+	}
+	tables := make([]sfntTable, n)
+	for i := range tables {
+		rec := data[44+20*i:]
+		tag := string(rec[:4])
+		offset, compLength, origLength := binary.BigEndian.Uint32(rec[4:]), binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		if uint64(offset)+uint64(compLength) > uint64(len(data)) || compLength > origLength {
+			return 0, nil, fmt.Errorf("table %s: %w", tag, errWOFF2Truncated)
+		}
+		table := data[offset : offset+compLength]
+		if compLength < origLength {
+			r, err := zlib.NewReader(bytes.NewReader(table))
+			if err != nil {
+				return 0, nil, fmt.Errorf("table %s: %w", tag, err)
+			}
+			if table, err = io.ReadAll(io.LimitReader(r, int64(origLength))); err != nil {
+				return 0, nil, fmt.Errorf("table %s: %w", tag, err)
+			}
+			if len(table) != int(origLength) {
+				return 0, nil, fmt.Errorf("table %s: %w", tag, errWOFF2Truncated)
+			}
+		}
+		tables[i] = sfntTable{tag: tag, data: table}
+	}
+	return flavor, tables, nil
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// woff2Signature is the magic number every WOFF2 file starts with
+var woff2Signature = []byte("wOF2")
+
+const woff2HeaderSize = 48
+
+// woff2Header is the fixed-size WOFF2 file header
+// https://www.w3.org/TR/WOFF2/#woff20Header
+type woff2Header struct {
+	Signature           [4]byte
+	Flavor              uint32
+	Length              uint32
+	NumTables           uint16
+	Reserved            uint16
+	TotalSfntSize       uint32
+	TotalCompressedSize uint32
+	MajorVersion        uint16
+	MinorVersion        uint16
+	MetaOffset          uint32
+	MetaLength          uint32
+	MetaOrigLength      uint32
+	PrivOffset          uint32
+	PrivLength          uint32
+}
+
+// verifyWOFF2 checks that path holds a WOFF2 file. The deep check also parses
+// the header and table directory to catch truncated or corrupt files that
+// still start with the right signature.
+func verifyWOFF2(path string, deep bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !deep {
+		sig := make([]byte, len(woff2Signature))
+		if _, err := io.ReadFull(f, sig); err != nil || !bytes.Equal(sig, woff2Signature) {
+			return fmt.Errorf("%s is not a WOFF2 file (missing wOF2 signature)", path)
+		}
+		return nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if err := checkWOFF2(data); err != nil {
+		return fmt.Errorf("%s is not a valid WOFF2 file: %v", path, err)
+	}
+	return nil
+}
+
+// checkMinSize rejects a file under minSize bytes, as truncated downloads
+// and error pages tend to be tiny. A minSize of 0 disables the check.
+func checkMinSize(path string, minSize int64) error {
+	if minSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() < minSize {
+		return fmt.Errorf("%s is %d bytes, below the --min-file-size of %d bytes, and is likely corrupt", path, info.Size(), minSize)
+	}
+	return nil
+}
+
+// parseByteSize reads a size such as 512, 512B, 1KB or 1.5MB, counting
+// 1024 bytes to the KB as formatBytes does
+func parseByteSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"B", 1}} {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, unit = strings.TrimSuffix(upper, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a size such as 1KB")
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// checkWOFF2 validates the header and table directory of a WOFF2 file
+func checkWOFF2(data []byte) error {
+	if len(data) < woff2HeaderSize {
+		return fmt.Errorf("file is %d bytes, shorter than the %d byte header", len(data), woff2HeaderSize)
+	}
+	var h woff2Header
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &h); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Signature[:], woff2Signature) {
+		return errors.New("missing wOF2 signature")
+	}
+	if int(h.Length) != len(data) {
+		return fmt.Errorf("header declares a length of %d bytes but the file is %d bytes", h.Length, len(data))
+	}
+	if h.NumTables == 0 {
+		return errors.New("header declares no font tables")
+	}
+	if h.Reserved != 0 {
+		return fmt.Errorf("reserved header field is %d, expected 0", h.Reserved)
+	}
+	if h.MetaLength > 0 && uint64(h.MetaOffset)+uint64(h.MetaLength) > uint64(h.Length) {
+		return fmt.Errorf("metadata block (offset %d, length %d) extends past the end of the file", h.MetaOffset, h.MetaLength)
+	}
+	if h.PrivLength > 0 && uint64(h.PrivOffset)+uint64(h.PrivLength) > uint64(h.Length) {
+		return fmt.Errorf("private data block (offset %d, length %d) extends past the end of the file", h.PrivOffset, h.PrivLength)
+	}
+	end, err := skipTableDirectory(data, woff2HeaderSize, int(h.NumTables))
+	if err != nil {
+		return err
+	}
+	// font collections have a collection directory before the compressed data
+	if h.Flavor == 0x74746366 { // 'ttcf'
+		return nil
+	}
+	if uint64(end)+uint64(h.TotalCompressedSize) > uint64(h.Length) {
+		return fmt.Errorf("compressed font data (%d bytes at offset %d) extends past the end of the file", h.TotalCompressedSize, end)
+	}
+	return nil
+}
+
+// skipTableDirectory walks the variable-length table directory entries
+// starting at off and returns the offset just past them
+// https://www.w3.org/TR/WOFF2/#table_dir_format
+func skipTableDirectory(data []byte, off, numTables int) (int, error) {
+	const glyf, loca = 10, 11
+	for i := 0; i < numTables; i++ {
+		if off >= len(data) {
+			return 0, fmt.Errorf("table directory is truncated at entry %d of %d", i+1, numTables)
+		}
+		flags := data[off]
+		off++
+		tag := int(flags & 0x3f)
+		if tag == 0x3f {
+			// an explicit 4-byte tag follows
+			off += 4
+		}
+		var err error
+		if _, off, err = readBase128(data, off); err != nil {
+			return 0, fmt.Errorf("table directory entry %d: origLength: %v", i+1, err)
+		}
+		// glyf and loca are transformed unless their version is 3, other
+		// tables are only transformed with a non-zero version
+		version := flags >> 6
+		transformed := version != 0
+		if tag == glyf || tag == loca {
+			transformed = version != 3
+		}
+		if transformed {
+			if _, off, err = readBase128(data, off); err != nil {
+				return 0, fmt.Errorf("table directory entry %d: transformLength: %v", i+1, err)
+			}
+		}
+	}
+	if off > len(data) {
+		return 0, errors.New("table directory extends past the end of the file")
+	}
+	return off, nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxWriteRetries is how often a stylesheet write that failed transiently is retried
+const maxWriteRetries = 2
+
+// writeTimeout bounds each write attempt, as writes to a stalled network
+// mount can hang instead of failing
+const writeTimeout = 10 * time.Second
+
+var errWriteTimeout = errors.New("write timed out")
+
+// stdoutStylesheet as the stylesheet path writes the CSS to stdout
+const stdoutStylesheet = "-"
+
+// stylesheetStdout is the process's real stdout, kept for the stylesheet
+// while install points os.Stdout at stderr
+var stylesheetStdout io.Writer = os.Stdout
+
+// charsetName matches an IANA character set name such as UTF-8
+var charsetName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:+-]*$`)
+
+// cssCharset is the encoding declared by the stylesheet's @charset rule:
+// UTF-8 for `charset: true`, the configured name, or "" for none
+func (cfg *FontsYAML) cssCharset() string {
+	switch strings.ToLower(cfg.Charset) {
+	case "", "false":
+		return ""
+	case "true":
+		return "UTF-8"
+	}
+	return cfg.Charset
+}
+
+func validateCharset(cfg *FontsYAML) error {
+	if charset := cfg.cssCharset(); charset != "" && !charsetName.MatchString(charset) {
+		return fmt.Errorf("charset %q is not a character set name, e.g. UTF-8, or true for UTF-8", charset)
+	}
+	return nil
+}
+
+// withCharset puts the @charset rule first in css, before any banner, as
+// a rule anywhere else is invalid. A stylesheet read back from disk may
+// already start with one, which is replaced.
+func withCharset(css, charset string) string {
+	css = stripCharset(css)
+	if charset == "" {
+		return css
+	}
+	return "@charset \"" + charset + "\";\n" + css
+}
+
+// stripCharset removes a leading @charset rule from css
+func stripCharset(css string) string {
+	if !strings.HasPrefix(css, "@charset ") {
+		return css
+	}
+	if i := strings.Index(css, ";\n"); i >= 0 {
+		return css[i+2:]
+	}
+	return css
+}
+
+// writeCSS writes the stylesheet atomically, retrying transient failures
+// such as those of NFS or SMB mounts. Permission errors are not retried.
+// The parent directory is created again before each attempt, as another
+// process may have removed it since install created it.
+// charset, when set, is declared by an @charset rule on the first line.
+func writeCSS(path, charset, css string) error {
+	css = withCharset(css, charset)
+	if path == stdoutStylesheet {
+		_, err := io.WriteString(stylesheetStdout, css)
+		return err
+	}
+	var err error
+	for attempt := 0; attempt <= maxWriteRetries; attempt++ {
+		if attempt > 0 {
+			printWarning("writing %s failed (%v), retrying", path, err)
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		if err := ensureParentDir(path); err != nil {
+			return err
+		}
+		// a missing directory was removed after ensureParentDir
+		if err = writeWithTimeout(path, []byte(css)); err == nil || !(transientWriteError(err) || errors.Is(err, fs.ErrNotExist)) {
+			break
+		}
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w (check that %s is writable)", err, filepath.Dir(path))
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w (%s keeps disappearing, is another process removing it?)", err, filepath.Dir(path))
+	case transientWriteError(err):
+		return fmt.Errorf("%w (still failing after %d attempts)", err, maxWriteRetries+1)
+	}
+	return err
+}
+
+// ensureParentDir creates the directory of path when it is missing
+func ensureParentDir(path string) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("could not create the directory of %s: %w (check that %s is writable)", path, err, filepath.Dir(dir))
+	case errors.Is(err, syscall.ENOTDIR) || errors.Is(err, fs.ErrExist):
+		return fmt.Errorf("could not create the directory of %s: %w (a file is in the way of %s)", path, err, dir)
+	}
+	return fmt.Errorf("could not create the directory of %s: %w", path, err)
+}
+
+// writeWithTimeout gives up on an atomic write that takes longer than
+// writeTimeout. The abandoned write only ever renames a complete file into
+// place, so a late finish leaves the same content behind.
+func writeWithTimeout(path string, data []byte) error {
+	done := make(chan error, 1)
+	go func() { done <- writeFileAtomic(path, data) }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(writeTimeout):
+		return errWriteTimeout
+	}
+}
+
+// transientWriteError reports whether a write error is worth retrying
+func transientWriteError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EIO, syscall.ESTALE, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT, syscall.EBUSY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return errors.Is(err, errWriteTimeout)
+}